@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
 	"syscall"
 
 	"github.com/ChaosHour/go-gtids/pkg/gtids"
@@ -19,23 +23,48 @@ var (
 )
 
 var (
-	source            = flag.String("s", "", "Source Host")
-	target            = flag.String("t", "", "Target Host")
+	source            = flag.String("s", "", "Source Host, or channel=host[:port],... for a multi-source replica (optional with a single -t: read from the replica's replication status)")
+	target            = flag.String("t", "", "Target Host, or a comma-separated list of replicas (host or host:port) to check concurrently")
 	sourcePort        = flag.String("source-port", "3306", "Source MySQL port")
+	hostMap           = flag.String("host-map", "", "without -s, comma-separated from=to[:port] overrides for source hosts that only resolve from the replica")
 	targetPort        = flag.String("target-port", "3306", "Target MySQL port")
+	discover          = flag.Bool("discover", false, "find the source's replicas (SHOW REPLICAS, else Binlog Dump threads) and check each instead of -t")
+	topology          = flag.Bool("topology", false, "crawl the replicas below -s recursively and show the tree, each replica compared with its own source")
+	parallel          = flag.Int("parallel", 4, "number of replicas checked at once when -t lists several")
 	fix               = flag.Bool("fix", false, "fix the GTID set subset issue by applying to source")
 	fixReplica        = flag.Bool("fix-replica", false, "fix the GTID set subset issue by applying to replica")
 	fixMissingReplica = flag.Bool("fix-missing-replica", false, "fix missing GTIDs by applying dummy transactions to replica (WARNING: skips the transactions' data)")
+	fixReplay         = flag.Bool("fix-replay", false, "replay the errant transactions' row changes on the source under their GTIDs (needs -binlog-dir)")
+	binlogDir         = flag.String("binlog-dir", "", "directory holding the target's binary log files, for errant commit timestamps, row counts, -fix-replay and -flashback")
+	flashback         = flag.String("flashback", "", "write SQL that reverts the errant transactions' row changes on the replica to this file (needs -binlog-dir)")
+	flashbackApply    = flag.Bool("flashback-apply", false, "also run the -flashback script on the replica, with replication stopped and binary logging off")
+	size              = flag.Bool("size", false, "print the binlog size, events and rows per table of the errant and missing transactions")
+	sourceBinlogDir   = flag.String("source-binlog-dir", "", "directory holding the source's binary log files, for -size row counts of missing transactions")
 	dryRun            = flag.Bool("dry-run", false, "print the statements a fix would execute without running them")
 	assumeYes         = flag.Bool("yes", false, "skip the confirmation prompt before applying fixes")
+	inspect           = flag.Bool("inspect", false, "show what each errant transaction did (statements, tables, operations) from the target's binlogs")
+	offline           = flag.Bool("offline", false, "compare two GTID sets given as arguments (text, @file, or - for stdin) without connecting to MySQL")
+	binlogFiles       = flag.Bool("binlog-files", false, "report the GTID sets preceding and contained in each binary log file given as an argument")
+	gtidPosition      = flag.String("gtid-position", "", "print the binlog file:position where this GTID starts on the -s server")
+	gtidSetAt         = flag.String("gtid-set-at", "", "print the GTID set executed up to this binlog file:position on the -s server")
 	showVersion       = flag.Bool("version", false, "Print version and exit")
 	help              = flag.Bool("h", false, "Print help")
 )
 
 func printHelp() {
-	fmt.Println("Usage: go-gtids -s <source> -t <target> [-source-port <port>] [-target-port <port>] [-fix] [-fix-replica] [-fix-missing-replica] [-dry-run] [-yes]")
+	fmt.Println("Usage: go-gtids -s <source> -t <target>[,<target>...] [-parallel <n>] [-source-port <port>] [-target-port <port>] [-fix] [-fix-replica] [-fix-replay -binlog-dir <dir>] [-flashback <file.sql> [-flashback-apply] -binlog-dir <dir>] [-fix-missing-replica] [-dry-run] [-yes] [-inspect] [-size [-source-binlog-dir <dir>]] [-binlog-dir <dir>]")
+	fmt.Println("       go-gtids -s <channel>=<source>[:<port>],... -t <replica> [-fix-missing-replica] [-dry-run] [-yes] [-inspect] [-size]   (multi-source)")
+	fmt.Println("       go-gtids -t <replica> [-host-map <from>=<to>[:<port>],...] [flags]   (source read from the replica)")
+	fmt.Println("       go-gtids -s <source> -discover [-parallel <n>] [-fix | -fix-replica | -fix-missing-replica] [-dry-run] [-yes] [-inspect] [-size]")
+	fmt.Println("       go-gtids -s <source> -topology [-target-port <port>]")
+	fmt.Println("       go-gtids -offline <source-gtid-set> <target-gtid-set>   (each: a GTID set, @file, or - for stdin)")
+	fmt.Println("       go-gtids -binlog-files <binlog-file>...")
+	fmt.Println("       go-gtids -s <host> [-source-port <port>] -gtid-position <uuid:gno> | -gtid-set-at <file:pos>")
 	flag.PrintDefaults()
-	fmt.Println("Exit codes: 0 = in sync (or fix applied), 1 = error, 2 = errant/missing transactions remain")
+	fmt.Println("Exit codes: 0 = in sync (or fix applied), 1 = error, 2 = errant/missing transactions remain,")
+	fmt.Println("            3 = only errant transactions without data changes (empty or administrative) remain,")
+	fmt.Println("            4 = errant transactions already purged from the target's binary logs remain")
+	fmt.Println("            With several -t replicas, -discover or -topology: 1 if any could not be checked, else the worst replica's code")
 }
 
 func main() {
@@ -51,39 +80,359 @@ func main() {
 		os.Exit(0)
 	}
 
-	if *source == "" || *target == "" {
+	if *offline {
+		os.Exit(runOffline(flag.Args()))
+	}
+
+	if *binlogFiles {
+		os.Exit(runBinlogFiles(flag.Args()))
+	}
+
+	if *gtidPosition != "" || *gtidSetAt != "" {
+		os.Exit(runPositionLookup())
+	}
+
+	if *topology {
+		os.Exit(runTopology())
+	}
+
+	if *discover && *target != "" {
+		fmt.Fprintln(os.Stderr, "-discover finds the targets on the source; it cannot be combined with -t")
+		os.Exit(1)
+	}
+	if *target == "" && (*source == "" || !*discover) {
 		printHelp()
 		os.Exit(1)
 	}
 
-	if *dryRun && !*fix && !*fixReplica && !*fixMissingReplica {
-		fmt.Fprintln(os.Stderr, "Note: -dry-run has no effect without -fix, -fix-replica, or -fix-missing-replica")
+	if *fixReplay && (*fix || *fixReplica) {
+		fmt.Fprintln(os.Stderr, "-fix-replay cannot be combined with -fix or -fix-replica")
+		os.Exit(1)
+	}
+	if *flashback != "" && (*fixReplica || *fixReplay) {
+		fmt.Fprintln(os.Stderr, "-flashback cannot be combined with -fix-replica or -fix-replay")
+		os.Exit(1)
+	}
+	if *flashbackApply && *flashback == "" {
+		fmt.Fprintln(os.Stderr, "-flashback-apply needs -flashback <file.sql>")
+		os.Exit(1)
+	}
+	if (*fixReplay || *flashback != "") && *binlogDir == "" {
+		fmt.Fprintln(os.Stderr, "-fix-replay and -flashback need -binlog-dir <dir> with the target's binary log files")
+		os.Exit(1)
+	}
+
+	if *sourceBinlogDir != "" && !*size {
+		fmt.Fprintln(os.Stderr, "-source-binlog-dir is only used with -size")
+		os.Exit(1)
+	}
+
+	if *dryRun && !*fix && !*fixReplica && !*fixReplay && !*flashbackApply && !*fixMissingReplica {
+		fmt.Fprintln(os.Stderr, "Note: -dry-run has no effect without -fix, -fix-replica, -fix-replay, -flashback-apply, or -fix-missing-replica")
+	}
+
+	var targets []replicaTarget
+	if !*discover {
+		var err error
+		if targets, err = parseTargets(*target, *targetPort); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *source == "" && len(targets) > 1 {
+		fmt.Fprintln(os.Stderr, "Without -s, -t takes a single replica, whose source is read from its replication status")
+		os.Exit(1)
+	}
+	var channels []sourceChannel
+	if strings.Contains(*source, "=") {
+		if len(targets) != 1 {
+			fmt.Fprintln(os.Stderr, "-s with channel=host entries describes the sources of a single -t replica")
+			os.Exit(1)
+		}
+		var err error
+		if channels, err = parseSourceChannels(*source, *sourcePort); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if len(channels) > 1 && (*fix || *fixReplica || *fixReplay || *flashback != "") {
+			fmt.Fprintln(os.Stderr, "With several sources, errant transactions are only reported: -fix, -fix-replica, -fix-replay and -flashback would each have to pick one source")
+			os.Exit(1)
+		}
+	}
+	var sourceMap map[string]string
+	if *hostMap != "" {
+		if *source != "" {
+			fmt.Fprintln(os.Stderr, "-host-map maps the source read from the replica; it cannot be combined with -s")
+			os.Exit(1)
+		}
+		var err error
+		if sourceMap, err = parseHostMap(*hostMap); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if (len(targets) > 1 || *discover) && (*fixReplay || *flashback != "" || *binlogDir != "") {
+		fmt.Fprintln(os.Stderr, "-fix-replay, -flashback and -binlog-dir read one target's binary log files and need a single -t")
+		os.Exit(1)
+	}
+	if *parallel < 1 {
+		fmt.Fprintln(os.Stderr, "-parallel must be at least 1")
+		os.Exit(1)
+	}
+
+	opts := gtids.Options{
+		Fix:               *fix,
+		FixReplica:        *fixReplica,
+		FixMissingReplica: *fixMissingReplica,
+		DryRun:            *dryRun,
+		AssumeYes:         *assumeYes,
+		Inspect:           *inspect,
+		Size:              *size,
+		SourceBinlogDir:   *sourceBinlogDir,
+		Replay:            *fixReplay,
+		BinlogDir:         *binlogDir,
+		FlashbackScript:   *flashback,
+		FlashbackApply:    *flashbackApply,
 	}
 
 	// Ctrl-C / SIGTERM cancels in-flight work; fix cleanup still runs to completion.
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	db1, db2, err := gtids.ConnectToDatabases(ctx, *source, *sourcePort, *target, *targetPort)
+	if *discover || len(targets) > 1 {
+		db1, err := gtids.ConnectToDatabase(ctx, *source, *sourcePort)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error connecting to source: %v\n", err)
+			os.Exit(1)
+		}
+		defer db1.Close()
+		if *discover {
+			if targets, err = discoverTargets(ctx, db1); err != nil {
+				fmt.Fprintf(os.Stderr, "Error discovering replicas: %v\n", err)
+				os.Exit(1)
+			}
+			if len(targets) == 0 {
+				fmt.Println("No replicas found on", *source)
+				return
+			}
+		}
+		os.Exit(runReplicas(ctx, db1, targets, opts))
+	}
+
+	var db2 *sql.DB
+	var sources []gtids.ChannelSource
+	var err error
+	switch {
+	case channels != nil:
+		db2, sources, err = connectToChannelSources(ctx, targets[0], channels)
+	case *source == "":
+		db2, sources, err = connectToInferredSources(ctx, targets[0], sourceMap)
+	default:
+		var db1 *sql.DB
+		db1, db2, err = gtids.ConnectToDatabases(ctx, *source, *sourcePort, targets[0].host, targets[0].port)
+		sources = []gtids.ChannelSource{{Host: *source, DB: db1}}
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error connecting to databases: %v\n", err)
 		os.Exit(1)
 	}
-	defer db1.Close()
+	for _, s := range sources {
+		defer s.DB.Close()
+	}
 	defer db2.Close()
 
-	unresolved, err := gtids.CheckGtidSetSubset(ctx, db1, db2, *source, *target, gtids.Options{
-		Fix:               *fix,
-		FixReplica:        *fixReplica,
-		FixMissingReplica: *fixMissingReplica,
-		DryRun:            *dryRun,
-		AssumeYes:         *assumeYes,
-	})
+	var outcome gtids.Outcome
+	if len(sources) == 1 {
+		// Fixes on the replica stop and start only this source's channel.
+		opts.Channel = sources[0].Channel
+		outcome, err = gtids.CheckGtidSetOutcome(ctx, sources[0].DB, db2, sources[0].Host, targets[0].host, opts)
+	} else {
+		outcome, err = gtids.CheckMultiSourceGtidSubset(ctx, sources, db2, targets[0].host, opts)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error checking GTID set subset: %v\n", err)
 		os.Exit(1)
 	}
-	if unresolved {
-		os.Exit(2)
+	if code := outcome.ExitCode(); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// gtidSetLabel matches a leading "Executed_Gtid_Set:"-style label, so values
+// pasted from SHOW REPLICA STATUS\G output can be used as-is.
+var gtidSetLabel = regexp.MustCompile(`^\s*[A-Za-z_]+:\s`)
+
+// readGtidSetArg resolves an offline argument: "-" reads stdin, "@path" reads
+// a file, anything else is the GTID set itself.
+func readGtidSetArg(arg string) (string, error) {
+	var text string
+	switch {
+	case arg == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		text = string(data)
+	case strings.HasPrefix(arg, "@"):
+		data, err := os.ReadFile(arg[1:])
+		if err != nil {
+			return "", err
+		}
+		text = string(data)
+	default:
+		text = arg
+	}
+	return gtidSetLabel.ReplaceAllString(text, ""), nil
+}
+
+// runOffline compares two GTID sets without a database and returns the exit code.
+func runOffline(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "-offline needs exactly two arguments: <source-gtid-set> <target-gtid-set>")
+		return 1
+	}
+	if args[0] == "-" && args[1] == "-" {
+		fmt.Fprintln(os.Stderr, "-offline can read only one of the two GTID sets from stdin")
+		return 1
+	}
+	if *fix || *fixReplica || *fixReplay || *fixMissingReplica || *flashback != "" {
+		fmt.Fprintln(os.Stderr, "-offline cannot be combined with -fix, -fix-replica, -fix-replay, -fix-missing-replica, or -flashback")
+		return 1
+	}
+	if *inspect || *size {
+		fmt.Fprintln(os.Stderr, "-offline cannot be combined with -inspect or -size, which read the servers' binary logs")
+		return 1
+	}
+
+	sets := make([]string, len(args))
+	for i, arg := range args {
+		set, err := readGtidSetArg(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading GTID set %q: %v\n", arg, err)
+			return 1
+		}
+		sets[i] = set
+	}
+
+	sourceLabel, targetLabel := "source", "target"
+	if *source != "" {
+		sourceLabel = *source
+	}
+	if *target != "" {
+		targetLabel = *target
+	}
+	outcome, err := gtids.CheckGtidSetsOffline(os.Stdout, sourceLabel, targetLabel, sets[0], sets[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error comparing GTID sets: %v\n", err)
+		return 1
+	}
+	return outcome.ExitCode()
+}
+
+// runBinlogFiles reports on each binary log file and returns the exit code:
+// 1 if any file could not be read.
+func runBinlogFiles(paths []string) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "-binlog-files needs at least one binary log file")
+		return 1
+	}
+	if *fix || *fixReplica || *fixReplay || *fixMissingReplica || *flashback != "" || *inspect || *size {
+		fmt.Fprintln(os.Stderr, "-binlog-files cannot be combined with -fix, -fix-replica, -fix-replay, -fix-missing-replica, -flashback, -inspect, or -size")
+		return 1
+	}
+
+	code := 0
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading binary log: %v\n", err)
+			code = 1
+			continue
+		}
+		err = gtids.ReportBinlogFile(os.Stdout, path, f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading binary log: %v\n", err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// runPositionLookup translates between a GTID and binlog coordinates on the
+// -s server and returns the exit code.
+func runPositionLookup() int {
+	if *gtidPosition != "" && *gtidSetAt != "" {
+		fmt.Fprintln(os.Stderr, "-gtid-position and -gtid-set-at cannot be combined")
+		return 1
+	}
+	if *source == "" || *target != "" {
+		fmt.Fprintln(os.Stderr, "-gtid-position and -gtid-set-at look up one server: give it with -s (and not -t)")
+		return 1
+	}
+	if *fix || *fixReplica || *fixReplay || *fixMissingReplica || *flashback != "" || *inspect || *size {
+		fmt.Fprintln(os.Stderr, "-gtid-position and -gtid-set-at cannot be combined with -fix, -fix-replica, -fix-replay, -fix-missing-replica, -flashback, -inspect, or -size")
+		return 1
+	}
+	var pos gtids.BinlogPosition
+	if *gtidSetAt != "" {
+		var err error
+		if pos, err = gtids.ParseBinlogPosition(*gtidSetAt); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := gtids.ConnectToDatabase(ctx, *source, *sourcePort)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	if *gtidPosition != "" {
+		pos, err := gtids.GtidPosition(ctx, db, *gtidPosition)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error locating %s: %v\n", *gtidPosition, err)
+			return 1
+		}
+		fmt.Printf("%s starts at %s on %s\n", *gtidPosition, pos, *source)
+		fmt.Printf("SOURCE_LOG_FILE='%s', SOURCE_LOG_POS=%d\n", pos.File, pos.Pos)
+		return 0
+	}
+	set, err := gtids.GtidSetAtPosition(ctx, db, pos)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the GTID set at %s: %v\n", pos, err)
+		return 1
+	}
+	fmt.Printf("GTID set executed up to %s on %s:\n", pos, *source)
+	fmt.Println(set)
+	return 0
+}
+
+// runTopology crawls the replication tree below -s, prints it and returns the
+// exit code: 1 if any server could not be checked, else the worst replica's.
+func runTopology() int {
+	if *source == "" || *target != "" || *discover {
+		fmt.Fprintln(os.Stderr, "-topology starts at the -s server and finds the replicas itself: give -s (and not -t or -discover)")
+		return 1
+	}
+	if *fix || *fixReplica || *fixReplay || *fixMissingReplica || *flashback != "" || *inspect || *size || *binlogDir != "" {
+		fmt.Fprintln(os.Stderr, "-topology cannot be combined with -fix, -fix-replica, -fix-replay, -fix-missing-replica, -flashback, -inspect, -size, or -binlog-dir")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	root := gtids.CrawlTopology(ctx, *source, *sourcePort, *targetPort, gtids.ConnectToDatabase)
+	gtids.RenderTopology(os.Stdout, root)
+	outcome, failed := root.Worst()
+	if failed {
+		return 1
 	}
+	return outcome.ExitCode()
 }
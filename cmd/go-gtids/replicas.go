@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ChaosHour/go-gtids/pkg/gtids"
+)
+
+// replicaTarget is one entry of -t.
+type replicaTarget struct {
+	host, port string
+}
+
+func (t replicaTarget) String() string {
+	return net.JoinHostPort(t.host, t.port)
+}
+
+// splitTarget parses "host", "host:port", "[ipv6]" or "[ipv6]:port", with
+// defaultPort when no port is given.
+func splitTarget(entry, defaultPort string) replicaTarget {
+	if host, port, err := net.SplitHostPort(entry); err == nil {
+		return replicaTarget{host: host, port: port}
+	}
+	if strings.HasPrefix(entry, "[") && strings.HasSuffix(entry, "]") {
+		entry = entry[1 : len(entry)-1]
+	}
+	return replicaTarget{host: entry, port: defaultPort}
+}
+
+// parseTargets splits -t into replicas: "host" uses defaultPort, "host:port"
+// (or "[ipv6]:port") its own.
+func parseTargets(list, defaultPort string) ([]replicaTarget, error) {
+	var targets []replicaTarget
+	seen := map[replicaTarget]bool{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		t := splitTarget(entry, defaultPort)
+		if seen[t] {
+			return nil, fmt.Errorf("-t lists %s twice", t)
+		}
+		seen[t] = true
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("-t lists no target")
+	}
+	return targets, nil
+}
+
+// parseHostMap parses -host-map: comma-separated "from=to" entries, each side
+// "host" or "host:port". A "from" with a port only matches that port; a "to"
+// without one keeps the port being mapped.
+func parseHostMap(list string) (map[string]string, error) {
+	hostMap := map[string]string{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		from, to, found := strings.Cut(entry, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !found || from == "" || to == "" {
+			return nil, fmt.Errorf("-host-map entry %q is not from=to", entry)
+		}
+		if host, port, err := net.SplitHostPort(from); err == nil {
+			from = net.JoinHostPort(host, port)
+		} else {
+			from = splitTarget(from, "").host
+		}
+		if _, ok := hostMap[from]; ok {
+			return nil, fmt.Errorf("-host-map maps %s twice", from)
+		}
+		hostMap[from] = to
+	}
+	return hostMap, nil
+}
+
+// mapHost applies -host-map to t, preferring an entry for its host:port over
+// one for its host alone.
+func mapHost(hostMap map[string]string, t replicaTarget) replicaTarget {
+	to, ok := hostMap[t.String()]
+	if !ok {
+		to, ok = hostMap[t.host]
+	}
+	if !ok {
+		return t
+	}
+	return splitTarget(to, t.port)
+}
+
+// sourceChannel is a source of the target and the replication channel the
+// target receives its transactions on.
+type sourceChannel struct {
+	channel string
+	source  replicaTarget
+}
+
+// parseSourceChannels splits a multi-source -s, "channel=host[:port],...",
+// with defaultPort for hosts given without one.
+func parseSourceChannels(list, defaultPort string) ([]sourceChannel, error) {
+	var channels []sourceChannel
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		channel, host, found := strings.Cut(entry, "=")
+		channel, host = strings.TrimSpace(channel), strings.TrimSpace(host)
+		if !found || channel == "" || host == "" {
+			return nil, fmt.Errorf("-s entry %q is not channel=host[:port]", entry)
+		}
+		if seen[channel] {
+			return nil, fmt.Errorf("-s names channel '%s' twice", channel)
+		}
+		seen[channel] = true
+		channels = append(channels, sourceChannel{channel: channel, source: splitTarget(host, defaultPort)})
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("-s lists no source")
+	}
+	return channels, nil
+}
+
+// connectToInferredSources connects to the replica target, reads the source of
+// each of its replication channels, maps those through hostMap and connects to
+// them too.
+func connectToInferredSources(ctx context.Context, target replicaTarget, hostMap map[string]string) (db2 *sql.DB, sources []gtids.ChannelSource, err error) {
+	db2, err = gtids.ConnectToDatabase(ctx, target.host, target.port)
+	if err != nil {
+		return nil, nil, err
+	}
+	replicated, err := gtids.ReplicationSources(ctx, db2)
+	if err != nil {
+		db2.Close()
+		return nil, nil, fmt.Errorf("failed to read the source of %s (give it with -s): %w", target, err)
+	}
+	var channels []sourceChannel
+	for _, r := range replicated {
+		reported := replicaTarget{host: r.Host, port: r.Port}
+		c := sourceChannel{channel: r.Channel, source: mapHost(hostMap, reported)}
+		via := ""
+		if r.Channel != "" {
+			via = fmt.Sprintf(" on channel '%s'", r.Channel)
+		}
+		if c.source != reported {
+			fmt.Printf("%s replicates from %s%s, mapped to %s by -host-map\n", target, reported, via, c.source)
+		} else {
+			fmt.Printf("%s replicates from %s%s\n", target, c.source, via)
+		}
+		channels = append(channels, c)
+	}
+
+	sources, err = connectToSources(ctx, channels)
+	if err != nil {
+		db2.Close()
+		return nil, nil, fmt.Errorf("%w (if a source only resolves from the replica, map it with -host-map <from>=<to>[:<port>])", err)
+	}
+	return db2, sources, nil
+}
+
+// connectToChannelSources connects to the replica target and to each source of
+// a multi-source -s.
+func connectToChannelSources(ctx context.Context, target replicaTarget, channels []sourceChannel) (db2 *sql.DB, sources []gtids.ChannelSource, err error) {
+	db2, err = gtids.ConnectToDatabase(ctx, target.host, target.port)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources, err = connectToSources(ctx, channels)
+	if err != nil {
+		db2.Close()
+		return nil, nil, err
+	}
+	return db2, sources, nil
+}
+
+// connectToSources connects to the source of each channel, closing those
+// already open if one fails.
+func connectToSources(ctx context.Context, channels []sourceChannel) ([]gtids.ChannelSource, error) {
+	var sources []gtids.ChannelSource
+	for _, c := range channels {
+		db, err := gtids.ConnectToDatabase(ctx, c.source.host, c.source.port)
+		if err != nil {
+			for _, s := range sources {
+				s.DB.Close()
+			}
+			return nil, err
+		}
+		sources = append(sources, gtids.ChannelSource{Channel: c.channel, Host: c.source.host, DB: db})
+	}
+	return sources, nil
+}
+
+// replicaResult is what checking one replica found.
+type replicaResult struct {
+	target  replicaTarget
+	db      *sql.DB // nil if the connection failed
+	report  bytes.Buffer
+	outcome gtids.Outcome
+	err     error
+}
+
+// runReplicas checks the source db1 against each replica, *parallel at a time,
+// prints the reports in -t order, then runs any fix on each replica in turn,
+// and returns the combined exit code: 1 if any replica could not be checked or
+// fixed, else the worst outcome's.
+//
+// The concurrent checks only report. Fixes re-check each replica first and
+// run one at a time: they may prompt, and a -fix on the source changes what
+// the next replica's check finds.
+func runReplicas(ctx context.Context, db1 *sql.DB, targets []replicaTarget, opts gtids.Options) int {
+	checkOpts := gtids.Options{Inspect: opts.Inspect, Size: opts.Size, SourceBinlogDir: opts.SourceBinlogDir}
+	results := make([]*replicaResult, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(*parallel, len(targets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = checkReplica(ctx, db1, targets[i], checkOpts)
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	defer func() {
+		for _, r := range results {
+			if r.db != nil {
+				r.db.Close()
+			}
+		}
+	}()
+
+	for _, r := range results {
+		fmt.Printf("=== %s ===\n", r.target)
+		os.Stdout.Write(r.report.Bytes())
+		if r.err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking %s: %v\n", r.target, r.err)
+		}
+		fmt.Println()
+	}
+
+	fixing := opts.Fix || opts.FixReplica || opts.FixMissingReplica
+	if fixing {
+		for _, r := range results {
+			// Missing transactions leave the outcome in sync, so
+			// -fix-missing-replica looks at every replica.
+			if r.err != nil || (r.outcome == gtids.InSync && !opts.FixMissingReplica) {
+				continue
+			}
+			fmt.Printf("=== Fixing %s ===\n", r.target)
+			r.outcome, r.err = gtids.CheckGtidSetOutcome(ctx, db1, r.db, *source, r.target.String(), opts)
+			if r.err != nil {
+				fmt.Fprintf(os.Stderr, "Error fixing %s: %v\n", r.target, r.err)
+			}
+			fmt.Println()
+		}
+	}
+
+	fmt.Println("Summary:")
+	code, worst := 0, gtids.InSync
+	for _, r := range results {
+		if r.err != nil {
+			code = 1
+			fmt.Printf("  %-30s error: %v\n", r.target, r.err)
+			continue
+		}
+		worst = max(worst, r.outcome)
+		fmt.Printf("  %-30s %s (exit %d)\n", r.target, r.outcome, r.outcome.ExitCode())
+	}
+	if code == 0 {
+		code = worst.ExitCode()
+	}
+	return code
+}
+
+// checkReplica connects to target and checks it against the source, with the
+// report captured for printing in order.
+func checkReplica(ctx context.Context, db1 *sql.DB, target replicaTarget, opts gtids.Options) *replicaResult {
+	r := &replicaResult{target: target}
+	r.db, r.err = gtids.ConnectToDatabase(ctx, target.host, target.port)
+	if r.err != nil {
+		return r
+	}
+	opts.Output = &r.report
+	r.outcome, r.err = gtids.CheckGtidSetOutcome(ctx, db1, r.db, *source, target.String(), opts)
+	return r
+}
+
+// discoverTargets lists the replicas of the source db1 as targets. A replica
+// that does not report its port is assumed to listen on -target-port.
+func discoverTargets(ctx context.Context, db1 *sql.DB) ([]replicaTarget, error) {
+	replicas, fromProcesslist, err := gtids.DiscoverReplicas(ctx, db1)
+	if err != nil {
+		return nil, err
+	}
+	if fromProcesslist {
+		fmt.Println("Some replicas do not set report_host; using the client addresses of the source's Binlog Dump threads.")
+	}
+	var targets []replicaTarget
+	seen := map[replicaTarget]bool{}
+	for _, replica := range replicas {
+		t := replicaTarget{host: replica.Host, port: replica.Port}
+		if t.port == "" {
+			t.port = *targetPort
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		targets = append(targets, t)
+		if replica.ServerID != 0 {
+			fmt.Printf("Discovered replica %s (server_id %d)\n", t, replica.ServerID)
+		} else {
+			fmt.Printf("Discovered replica %s\n", t)
+		}
+	}
+	fmt.Println()
+	return targets, nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []replicaTarget
+		hasError bool
+	}{
+		{
+			name:     "host uses the default port",
+			input:    "replica1",
+			expected: []replicaTarget{{host: "replica1", port: "3306"}},
+		},
+		{
+			name:     "host:port overrides the default port",
+			input:    "replica1:3307, replica2",
+			expected: []replicaTarget{{host: "replica1", port: "3307"}, {host: "replica2", port: "3306"}},
+		},
+		{
+			name:     "bracketed IPv6 with port",
+			input:    "[2001:db8::1]:3310",
+			expected: []replicaTarget{{host: "2001:db8::1", port: "3310"}},
+		},
+		{
+			name:     "bracketed IPv6 without port",
+			input:    "[::1]",
+			expected: []replicaTarget{{host: "::1", port: "3306"}},
+		},
+		{
+			name:     "bare IPv6",
+			input:    "::1",
+			expected: []replicaTarget{{host: "::1", port: "3306"}},
+		},
+		{
+			name:     "empty entries are skipped",
+			input:    ",replica1,,",
+			expected: []replicaTarget{{host: "replica1", port: "3306"}},
+		},
+		{
+			name:     "duplicate host",
+			input:    "replica1,replica1",
+			hasError: true,
+		},
+		{
+			name:     "duplicate once the default port is applied",
+			input:    "replica1,replica1:3306",
+			hasError: true,
+		},
+		{
+			name:     "same host on another port is not a duplicate",
+			input:    "replica1,replica1:3307",
+			expected: []replicaTarget{{host: "replica1", port: "3306"}, {host: "replica1", port: "3307"}},
+		},
+		{
+			name:     "no target",
+			input:    " , ",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseTargets(tt.input, "3306")
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("expected error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseHostMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]string
+		hasError bool
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: map[string]string{},
+		},
+		{
+			name:     "host and host:port keys",
+			input:    "db1=db1.example.com, db2:3307 = 10.0.0.2:3310",
+			expected: map[string]string{"db1": "db1.example.com", "db2:3307": "10.0.0.2:3310"},
+		},
+		{
+			name:     "IPv6 keys are normalized",
+			input:    "[fd00::1]:3306=primary,[fd00::2]=secondary",
+			expected: map[string]string{"[fd00::1]:3306": "primary", "fd00::2": "secondary"},
+		},
+		{
+			name:     "host and host:port of the same host are both kept",
+			input:    "db1=a,db1:3307=b",
+			expected: map[string]string{"db1": "a", "db1:3307": "b"},
+		},
+		{
+			name:     "duplicate from",
+			input:    "db1=a,db1=b",
+			hasError: true,
+		},
+		{
+			name:     "missing =",
+			input:    "db1",
+			hasError: true,
+		},
+		{
+			name:     "empty to",
+			input:    "db1=",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseHostMap(tt.input)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("expected error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMapHost(t *testing.T) {
+	hostMap, err := parseHostMap("db1=db1.example.com,db1:3307=db1-alt.example.com:3310,db2=10.0.0.2:3320,[fd00::1]=[fd00::9]")
+	if err != nil {
+		t.Fatalf("parseHostMap failed: %v", err)
+	}
+	tests := []struct {
+		name     string
+		target   replicaTarget
+		expected replicaTarget
+	}{
+		{
+			name:     "host entry keeps the port",
+			target:   replicaTarget{host: "db1", port: "3306"},
+			expected: replicaTarget{host: "db1.example.com", port: "3306"},
+		},
+		{
+			name:     "port-specific entry is preferred",
+			target:   replicaTarget{host: "db1", port: "3307"},
+			expected: replicaTarget{host: "db1-alt.example.com", port: "3310"},
+		},
+		{
+			name:     "host entry with its own port",
+			target:   replicaTarget{host: "db2", port: "3306"},
+			expected: replicaTarget{host: "10.0.0.2", port: "3320"},
+		},
+		{
+			name:     "IPv6 host",
+			target:   replicaTarget{host: "fd00::1", port: "3306"},
+			expected: replicaTarget{host: "fd00::9", port: "3306"},
+		},
+		{
+			name:     "unmapped host is unchanged",
+			target:   replicaTarget{host: "db3", port: "3306"},
+			expected: replicaTarget{host: "db3", port: "3306"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := mapHost(hostMap, tt.target); result != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseSourceChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []sourceChannel
+		hasError bool
+	}{
+		{
+			name:  "hosts with and without port",
+			input: "orders=primary1, billing = primary2:3307",
+			expected: []sourceChannel{
+				{channel: "orders", source: replicaTarget{host: "primary1", port: "3306"}},
+				{channel: "billing", source: replicaTarget{host: "primary2", port: "3307"}},
+			},
+		},
+		{
+			name:  "IPv6 hosts",
+			input: "orders=[fd00::1]:3310,billing=[fd00::2]",
+			expected: []sourceChannel{
+				{channel: "orders", source: replicaTarget{host: "fd00::1", port: "3310"}},
+				{channel: "billing", source: replicaTarget{host: "fd00::2", port: "3306"}},
+			},
+		},
+		{
+			name:  "same host on two channels",
+			input: "orders=primary1,billing=primary1",
+			expected: []sourceChannel{
+				{channel: "orders", source: replicaTarget{host: "primary1", port: "3306"}},
+				{channel: "billing", source: replicaTarget{host: "primary1", port: "3306"}},
+			},
+		},
+		{
+			name:     "duplicate channel",
+			input:    "orders=primary1,orders=primary2",
+			hasError: true,
+		},
+		{
+			name:     "missing channel",
+			input:    "=primary1",
+			hasError: true,
+		},
+		{
+			name:     "plain host",
+			input:    "primary1",
+			hasError: true,
+		},
+		{
+			name:     "no source",
+			input:    ",",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseSourceChannels(tt.input, "3306")
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("expected error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
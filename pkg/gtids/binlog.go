@@ -0,0 +1,386 @@
+package gtids
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// binlogFilePattern matches a binary log file name. SHOW BINLOG EVENTS IN
+	// cannot be parameterized, so names are validated against this before being
+	// interpolated into the statement.
+	binlogFilePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	// gtidNextPattern extracts the GTID from a Gtid event's Info column,
+	// e.g. "SET @@SESSION.GTID_NEXT= 'uuid:42'".
+	gtidNextPattern = regexp.MustCompile(`GTID_NEXT\s*=\s*'([^']*)'`)
+)
+
+// BinlogEvent is one row of SHOW BINLOG EVENTS.
+type BinlogEvent struct {
+	LogName   string
+	Pos       uint64
+	EventType string
+	ServerID  uint32
+	EndLogPos uint64
+	Info      string
+}
+
+// BinlogTransaction groups the events of one transaction in a binary log: its
+// Gtid event and everything up to the next one. GTID is "ANONYMOUS" for
+// transactions written without GTIDs.
+type BinlogTransaction struct {
+	GTID     string
+	File     string
+	StartPos uint64
+	EndPos   uint64
+	Events   []BinlogEvent
+}
+
+// Location renders where the transaction lives as file:start_pos-end_pos.
+func (tx *BinlogTransaction) Location() string {
+	return fmt.Sprintf("%s:%d-%d", tx.File, tx.StartPos, tx.EndPos)
+}
+
+// isGtidEvent reports whether an event starts a new transaction.
+// MariaDB's Gtid_list is a header event, not a transaction.
+func isGtidEvent(eventType string) bool {
+	return (strings.HasPrefix(eventType, "Gtid") || strings.HasPrefix(eventType, "Anonymous_Gtid")) && eventType != "Gtid_list"
+}
+
+// listBinaryLogs returns the server's binary log files, oldest first.
+func listBinaryLogs(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SHOW BINARY LOGS")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list binary logs: %w", err)
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		columns, err := scanRowAsMap(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, columns["Log_name"])
+	}
+	return files, rows.Err()
+}
+
+// scanBinlogEvents runs SHOW BINLOG EVENTS on file (limit <= 0 for all events)
+// and calls fn for each event until fn returns false.
+func scanBinlogEvents(ctx context.Context, db *sql.DB, file string, limit int, fn func(BinlogEvent) bool) error {
+	if !binlogFilePattern.MatchString(file) {
+		return fmt.Errorf("invalid binary log file name %q", file)
+	}
+	query := fmt.Sprintf("SHOW BINLOG EVENTS IN '%s'", file)
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to read events of %s: %w", file, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		columns, err := scanRowAsMap(rows)
+		if err != nil {
+			return err
+		}
+		pos, _ := strconv.ParseUint(columns["Pos"], 10, 64)
+		endLogPos, _ := strconv.ParseUint(columns["End_log_pos"], 10, 64)
+		serverID, _ := strconv.ParseUint(columns["Server_id"], 10, 32)
+		event := BinlogEvent{
+			LogName:   columns["Log_name"],
+			Pos:       pos,
+			EventType: columns["Event_type"],
+			ServerID:  uint32(serverID),
+			EndLogPos: endLogPos,
+			Info:      columns["Info"],
+		}
+		if !fn(event) {
+			return nil
+		}
+	}
+	return rows.Err()
+}
+
+// scanBinlogTransactions groups the events of file into transactions and
+// calls fn for each one until fn returns false. Events before the first Gtid
+// event (Format_description, Previous_gtids) belong to no transaction.
+func scanBinlogTransactions(ctx context.Context, db *sql.DB, file string, fn func(*BinlogTransaction) bool) error {
+	var current *BinlogTransaction
+	stopped := false
+	err := scanBinlogEvents(ctx, db, file, 0, func(event BinlogEvent) bool {
+		if isGtidEvent(event.EventType) {
+			if current != nil && !fn(current) {
+				stopped = true
+				return false
+			}
+			gtid := "ANONYMOUS"
+			if m := gtidNextPattern.FindStringSubmatch(event.Info); m != nil {
+				gtid = m[1]
+			}
+			current = &BinlogTransaction{GTID: gtid, File: file, StartPos: event.Pos}
+		}
+		if current == nil {
+			return true
+		}
+		switch event.EventType {
+		case "Rotate", "Stop":
+			// Closes the file rather than belonging to the last transaction.
+			return true
+		}
+		current.Events = append(current.Events, event)
+		current.EndPos = event.EndLogPos
+		return true
+	})
+	if err != nil || stopped || current == nil {
+		return err
+	}
+	fn(current)
+	return nil
+}
+
+// previousGtids returns the GTID set executed before file, read from its
+// Previous_gtids event. A file without one (written with GTID_MODE=OFF)
+// yields an empty set.
+func previousGtids(ctx context.Context, db *sql.DB, file string) (*GtidSet, error) {
+	info := ""
+	err := scanBinlogEvents(ctx, db, file, 3, func(event BinlogEvent) bool {
+		if event.EventType == "Previous_gtids" {
+			info = event.Info
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	set, err := NewGtidSet(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Previous_gtids of %s: %w", file, err)
+	}
+	return set, nil
+}
+
+// splitGtid splits "uuid[:tag]:gno" into its GtidSet key and transaction number.
+func splitGtid(gtid string) (key string, gno int64, ok bool) {
+	i := strings.LastIndex(gtid, ":")
+	if i < 0 {
+		return "", 0, false
+	}
+	gno, err := strconv.ParseInt(gtid[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return strings.ToLower(gtid[:i]), gno, true
+}
+
+// GtidLocations is where the transactions of a GTID set live in a server's
+// binary logs.
+type GtidLocations struct {
+	// Transactions found, in binary log order.
+	Transactions []*BinlogTransaction
+	// Purged holds GTIDs older than the oldest binary log still on the server.
+	Purged *GtidSet
+	// NotFound holds GTIDs the binary logs should contain but do not, e.g.
+	// ones added to gtid_executed with SET GLOBAL gtid_purged.
+	NotFound *GtidSet
+}
+
+// LocateGtids finds the binary log file and positions of every transaction in
+// set. Each file's Previous_gtids event says what was executed before it, so
+// the files holding set are found by bisecting SHOW BINARY LOGS, and only
+// those files are scanned with SHOW BINLOG EVENTS.
+func LocateGtids(ctx context.Context, db *sql.DB, set *GtidSet) (*GtidLocations, error) {
+	files, err := listBinaryLogs(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no binary logs found (is log_bin enabled?)")
+	}
+
+	previous := make([]*GtidSet, len(files))
+	previousOf := func(i int) (*GtidSet, error) {
+		if previous[i] == nil {
+			set, err := previousGtids(ctx, db, files[i])
+			if err != nil {
+				return nil, err
+			}
+			previous[i] = set
+		}
+		return previous[i], nil
+	}
+
+	first, err := previousOf(0)
+	if err != nil {
+		return nil, err
+	}
+	res := &GtidLocations{Purged: set.Intersect(first)}
+
+	// Everything in files lo..hi and not before lo is in s; split it at the
+	// Previous_gtids of the middle file until each part maps to one file.
+	perFile := make([]*GtidSet, len(files))
+	var bisect func(lo, hi int, s *GtidSet) error
+	bisect = func(lo, hi int, s *GtidSet) error {
+		if s.IsEmpty() {
+			return nil
+		}
+		if lo == hi {
+			perFile[lo] = s
+			return nil
+		}
+		mid := (lo + hi + 1) / 2
+		before, err := previousOf(mid)
+		if err != nil {
+			return err
+		}
+		if err := bisect(lo, mid-1, s.Intersect(before)); err != nil {
+			return err
+		}
+		return bisect(mid, hi, s.Subtract(before))
+	}
+	if err := bisect(0, len(files)-1, set.Subtract(first)); err != nil {
+		return nil, err
+	}
+
+	foundIntervals := map[string][]Interval{}
+	for i, wanted := range perFile {
+		if wanted == nil {
+			continue
+		}
+		remaining := wanted.Count()
+		err := scanBinlogTransactions(ctx, db, files[i], func(tx *BinlogTransaction) bool {
+			if key, gno, ok := splitGtid(tx.GTID); ok && wanted.Contains(key, gno) {
+				res.Transactions = append(res.Transactions, tx)
+				foundIntervals[key] = append(foundIntervals[key], Interval{Start: gno, End: gno})
+				remaining--
+			}
+			return remaining > 0
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	found := &GtidSet{intervals: map[string][]Interval{}}
+	for key, intervals := range foundIntervals {
+		found.add(key, normalizeIntervals(intervals))
+	}
+	res.NotFound = set.Subtract(res.Purged).Subtract(found)
+	return res, nil
+}
+
+// commitTimeFormat renders commit timestamps, which have microsecond precision.
+const commitTimeFormat = "2006-01-02 15:04:05.000000 MST"
+
+// maxDefaultLocatedErrant is the most errant transactions a plain check
+// locates in the target's binary logs. Beyond it, finding them can mean
+// scanning many files with SHOW BINLOG EVENTS and printing a line each, so
+// only the options that need the locations (-inspect, -binlog-dir, -size and
+// the replay and flashback fixes) locate them.
+const maxDefaultLocatedErrant = 20
+
+// readGtidEvent reads tx's Gtid event from its file in binlogDir, for the
+// commit timestamps SHOW BINLOG EVENTS does not show.
+func readGtidEvent(binlogDir string, tx *BinlogTransaction) (*GtidEvent, error) {
+	events, err := readBinlogRange(filepath.Join(binlogDir, tx.File), tx.StartPos, tx.StartPos+1)
+	if err != nil {
+		return nil, err
+	}
+	gtidEvent, ok := events[0].Data.(*GtidEvent)
+	if !ok || gtidEvent.GTID() != tx.GTID {
+		return nil, fmt.Errorf("%s:%d is not the Gtid event of %s (is the file from another server?)", tx.File, tx.StartPos, tx.GTID)
+	}
+	return gtidEvent, nil
+}
+
+// describeOrigin says which server wrote tx and, when its Gtid event could be
+// read from binlogDir, when it was committed: originally, and here if it was
+// replicated in later.
+func describeOrigin(binlogDir string, tx *BinlogTransaction) (origin string, committed time.Time) {
+	origin = fmt.Sprintf("server_id %d", tx.Events[0].ServerID)
+	if binlogDir == "" {
+		return origin, time.Time{}
+	}
+	gtidEvent, err := readGtidEvent(binlogDir, tx)
+	switch {
+	case err != nil:
+		return origin + fmt.Sprintf(" (commit time unknown: %v)", err), time.Time{}
+	case gtidEvent.OriginalCommitTimestamp.IsZero():
+		return origin + " (commit time not recorded before MySQL 8.0.1)", time.Time{}
+	}
+	original, immediate := gtidEvent.OriginalCommitTimestamp, gtidEvent.ImmediateCommitTimestamp
+	origin += ", committed " + original.Format(commitTimeFormat)
+	if !immediate.Equal(original) {
+		origin += ", applied here " + immediate.Format(commitTimeFormat)
+	}
+	return origin, original
+}
+
+// printErrantLocations reports where each errant transaction lives in the
+// target's binary logs, which server wrote it and how it is classified, and
+// with inspect what each one did. With binlogDir, the target's binary log
+// files, it also reports when each one was committed. It returns the most
+// harmful impact found, and the locations (nil if locating failed); classified
+// is false unless every errant transaction was found and classified.
+func printErrantLocations(ctx context.Context, w io.Writer, db *sql.DB, errantSet *GtidSet, inspect bool, binlogDir string) (impact Impact, classified bool, locations *GtidLocations) {
+	locations, err := LocateGtids(ctx, db, errantSet)
+	if err != nil {
+		// Locating is informational (and needs REPLICATION SLAVE for SHOW
+		// BINLOG EVENTS), so a failure must not hide the check's result.
+		fmt.Fprintln(w, yellow("[!]"), "Could not locate errant transactions in the binary logs:", err)
+		return ImpactDML, false, nil
+	}
+	counts := map[Impact]int{}
+	var first, last time.Time
+	for _, tx := range locations.Transactions {
+		contents := transactionContents(tx)
+		counts[contents.Impact]++
+		impact = max(impact, contents.Impact)
+		fmt.Fprintln(w, yellow("[-]"), "Errant transaction", tx.GTID, "at", tx.Location()+":", contents.Describe())
+		origin, committed := describeOrigin(binlogDir, tx)
+		fmt.Fprintln(w, "    from", origin)
+		if !committed.IsZero() {
+			if first.IsZero() || committed.Before(first) {
+				first = committed
+			}
+			if committed.After(last) {
+				last = committed
+			}
+		}
+		if inspect {
+			if binlogDir != "" && len(contents.Changes) > 0 {
+				if err := countChangeRows(binlogDir, tx, contents); err != nil {
+					fmt.Fprintln(w, yellow("[!]"), "Could not count the rows of", tx.GTID+":", err)
+				}
+			}
+			printTransactionContents(w, contents)
+		}
+	}
+	if len(locations.Transactions) > 0 {
+		fmt.Fprintf(w, "%s Errant transactions by impact: %d empty, %d administrative, %d DDL, %d DML\n",
+			yellow("[i]"), counts[ImpactEmpty], counts[ImpactAdmin], counts[ImpactDDL], counts[ImpactDML])
+	}
+	if !first.IsZero() && last.After(first) {
+		fmt.Fprintln(w, yellow("[i]"), "Errant transactions committed between", first.Format(commitTimeFormat), "and", last.Format(commitTimeFormat))
+	}
+	classified = true
+	if !locations.Purged.IsEmpty() {
+		classified = false
+		fmt.Fprintln(w, red("[!]"), "Errant transactions already purged from the binary logs:", locations.Purged)
+	}
+	if !locations.NotFound.IsEmpty() {
+		classified = false
+		fmt.Fprintln(w, yellow("[!]"), "Errant transactions not found in any binary log:", locations.NotFound)
+	}
+	return impact, classified, locations
+}
@@ -0,0 +1,241 @@
+package gtids
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var binlogEventColumns = []string{"Log_name", "Pos", "Event_type", "Server_id", "End_log_pos", "Info"}
+
+// expectBinlogEvents mocks SHOW BINLOG EVENTS for file; rows are
+// {pos, type, end_log_pos, info}.
+func expectBinlogEvents(mock sqlmock.Sqlmock, file, limit string, events ...[]any) {
+	rows := sqlmock.NewRows(binlogEventColumns)
+	for _, event := range events {
+		rows.AddRow(file, event[0], event[1], 1, event[2], event[3])
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SHOW BINLOG EVENTS IN '" + file + "'" + limit)).WillReturnRows(rows)
+}
+
+func headerEvents(previous string) [][]any {
+	return [][]any{
+		{4, "Format_desc", 126, "Server ver: 8.0.36, Binlog ver: 4"},
+		{126, "Previous_gtids", 197, previous},
+	}
+}
+
+func gtidEvents(pos int, gtid string) [][]any {
+	return [][]any{
+		{pos, "Gtid", pos + 80, "SET @@SESSION.GTID_NEXT= '" + gtid + "'"},
+		{pos + 80, "Query", pos + 160, "BEGIN"},
+		{pos + 160, "Write_rows", pos + 240, "table_id: 90 flags: STMT_END_F"},
+		{pos + 240, "Xid", pos + 271, "COMMIT /* xid=12 */"},
+	}
+}
+
+func TestLocateGtids(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SHOW BINARY LOGS").WillReturnRows(sqlmock.NewRows([]string{"Log_name", "File_size", "Encrypted"}).
+		AddRow("binlog.000001", 1000, "No").
+		AddRow("binlog.000002", 2000, "No").
+		AddRow("binlog.000003", 3000, "No"))
+	// The oldest file, then the bisection: the middle file, then the last one.
+	expectBinlogEvents(mock, "binlog.000001", " LIMIT 3", headerEvents(uuidA+":1-3")...)
+	expectBinlogEvents(mock, "binlog.000002", " LIMIT 3", headerEvents(uuidA+":1-10")...)
+	expectBinlogEvents(mock, "binlog.000003", " LIMIT 3", headerEvents(uuidA+":1-20")...)
+	// Only the files holding errant transactions are scanned.
+	file2 := append(headerEvents(uuidA+":1-10"), gtidEvents(197, uuidA+":14")...)
+	file2 = append(file2, gtidEvents(468, uuidA+":15")...)
+	file2 = append(file2, gtidEvents(739, uuidA+":16")...)
+	expectBinlogEvents(mock, "binlog.000002", "", file2...)
+	file3 := append(headerEvents(uuidA+":1-20"), gtidEvents(197, uuidA+":25")...)
+	file3 = append(file3, []any{468, "Rotate", 512, "binlog.000004;pos=4"})
+	expectBinlogEvents(mock, "binlog.000003", "", file3...)
+
+	errant := mustGtidSet(t, uuidA+":2:15:25:40")
+	locations, err := LocateGtids(context.Background(), db, errant)
+	if err != nil {
+		t.Fatalf("LocateGtids failed: %v", err)
+	}
+
+	var got []string
+	for _, tx := range locations.Transactions {
+		got = append(got, tx.GTID+" "+tx.Location())
+	}
+	expected := []string{uuidA + ":15 binlog.000002:468-739", uuidA + ":25 binlog.000003:197-468"}
+	if len(got) != len(expected) || got[0] != expected[0] || got[1] != expected[1] {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if locations.Purged.String() != uuidA+":2" {
+		t.Errorf("unexpected purged set %q", locations.Purged)
+	}
+	if locations.NotFound.String() != uuidA+":40" {
+		t.Errorf("unexpected not-found set %q", locations.NotFound)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestScanBinlogTransactions_GroupsEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	events := append(headerEvents(""), gtidEvents(197, uuidA+":mytag:1")...)
+	events = append(events, []any{468, "Anonymous_Gtid", 533, "SET @@SESSION.GTID_NEXT= 'ANONYMOUS'"}, []any{533, "Query", 600, "FLUSH TABLES"})
+	events = append(events, []any{600, "Stop", 623, ""})
+	expectBinlogEvents(mock, "binlog.000001", "", events...)
+
+	var transactions []*BinlogTransaction
+	err = scanBinlogTransactions(context.Background(), db, "binlog.000001", func(tx *BinlogTransaction) bool {
+		transactions = append(transactions, tx)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("scanBinlogTransactions failed: %v", err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(transactions))
+	}
+	if tx := transactions[0]; tx.GTID != uuidA+":mytag:1" || len(tx.Events) != 4 || tx.Location() != "binlog.000001:197-468" {
+		t.Errorf("unexpected first transaction %+v", tx)
+	}
+	if tx := transactions[1]; tx.GTID != "ANONYMOUS" || len(tx.Events) != 2 || tx.EndPos != 600 {
+		t.Errorf("unexpected second transaction %+v", tx)
+	}
+}
+
+func TestScanBinlogEvents_RejectsUnsafeFileName(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	err = scanBinlogEvents(context.Background(), db, "binlog.000001' OR '1", 0, func(BinlogEvent) bool { return true })
+	if err == nil {
+		t.Error("expected an unsafe file name to be rejected before querying")
+	}
+}
+
+func TestCheckGtidSetOutcome_ClassifiesErrantTransactions(t *testing.T) {
+	tests := []struct {
+		name      string
+		errant    [][]any
+		errantSet string // the target's own transactions; uuidB:1 if empty
+		purged    string // the target's gtid_purged
+		expected  Outcome
+	}{
+		{name: "empty errant transaction", errant: [][]any{
+			{197, "Gtid", 276, "SET @@SESSION.GTID_NEXT= '" + uuidB + ":1'"},
+			{276, "Query", 351, "BEGIN"},
+			{351, "Query", 420, "COMMIT"},
+		}, expected: ErrantWithoutDataChanges},
+		{name: "errant row change", errant: gtidEvents(197, uuidB+":1"), expected: Unresolved},
+		// A purged errant transaction is not looked up in the binary logs.
+		{name: "purged errant transaction", purged: uuidA + ":1-10," + uuidB + ":1", expected: ErrantPurged},
+		// Too many to locate without an option that needs them.
+		{name: "more errant transactions than are located by default", errantSet: uuidB + ":1-21", expected: Unresolved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db1, mock1, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db1.Close()
+			db2, mock2, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db2.Close()
+			errantSet := tt.errantSet
+			if errantSet == "" {
+				errantSet = uuidB + ":1"
+			}
+
+			mock1.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+			mock2.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+			mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@server_uuid")).WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(uuidA))
+			mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(uuidA + ":1-10"))
+			mock2.ExpectQuery(regexp.QuoteMeta("SELECT @@server_uuid")).WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(uuidB))
+			mock2.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(uuidA + ":1-10," + errantSet))
+			mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_PURGED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(""))
+			mock2.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_PURGED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(tt.purged))
+			if tt.errant != nil {
+				mock2.ExpectQuery("SHOW BINARY LOGS").WillReturnRows(sqlmock.NewRows([]string{"Log_name", "File_size"}).AddRow("binlog.000001", 1000))
+				expectBinlogEvents(mock2, "binlog.000001", " LIMIT 3", headerEvents("")...)
+				expectBinlogEvents(mock2, "binlog.000001", "", append(headerEvents(""), tt.errant...)...)
+			}
+
+			var out bytes.Buffer
+			outcome, err := CheckGtidSetOutcome(context.Background(), db1, db2, "source", "target", Options{Output: &out})
+			if err != nil {
+				t.Fatalf("CheckGtidSetOutcome failed: %v", err)
+			}
+			if outcome != tt.expected {
+				t.Errorf("expected outcome %q, got %q", tt.expected, outcome)
+			}
+			if strings.Contains(out.String(), "Could not locate") {
+				t.Errorf("unexpected location failure:\n%s", out.String())
+			}
+			if err := mock2.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet target expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestDescribeOrigin(t *testing.T) {
+	data := errantTransactionBinlog(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "binlog.000001"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	gtidPos := decodeTestBinlog(t, data)[2].Pos
+
+	tests := []struct {
+		name      string
+		binlogDir string
+		gtid      string
+		expected  string
+		committed time.Time
+	}{
+		{name: "without binlog files", gtid: uuidB + ":1", expected: "server_id 12"},
+		{
+			name: "with binlog files", binlogDir: dir, gtid: uuidB + ":1",
+			expected:  "server_id 12, committed 2026-03-12 03:11:59.000000 UTC, applied here 2026-03-12 03:12:00.000000 UTC",
+			committed: time.Date(2026, 3, 12, 3, 11, 59, 0, time.UTC),
+		},
+		{name: "files from another server", binlogDir: dir, gtid: uuidB + ":2", expected: "server_id 12 (commit time unknown: binlog.000001:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &BinlogTransaction{GTID: tt.gtid, File: "binlog.000001", StartPos: uint64(gtidPos), Events: []BinlogEvent{{EventType: "Gtid", ServerID: 12}}}
+			origin, committed := describeOrigin(tt.binlogDir, tx)
+			if !strings.HasPrefix(origin, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, origin)
+			}
+			if !committed.Equal(tt.committed) {
+				t.Errorf("expected commit time %v, got %v", tt.committed, committed)
+			}
+		})
+	}
+}
@@ -0,0 +1,903 @@
+package gtids
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/bits"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Binary log (v4) decoding, for archived binlog files and, later, replication
+// streams. Every event starts with a 19-byte header (all integers
+// little-endian):
+//
+//	4 bytes timestamp, 1 byte type, 4 bytes server_id,
+//	4 bytes event length, 4 bytes next position, 2 bytes flags
+//
+// When the Format_description event announces CRC32 checksums, each event
+// ends with the CRC32 of everything before it.
+
+// binlogMagic starts every (unencrypted) binary log file.
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+// encryptedBinlogMagic starts a binary log encrypted with binlog_encryption=ON.
+var encryptedBinlogMagic = []byte{0xfd, 'b', 'i', 'n'}
+
+const (
+	binlogEventHeaderLen = 19
+	binlogChecksumLen    = 4
+	binlogChecksumCRC32  = 1
+	// maxBinlogEventLen bounds a single event to max_allowed_packet's limit.
+	maxBinlogEventLen = 1 << 30
+	// maxBinlogFileLen is the largest position an event header can hold.
+	maxBinlogFileLen = 1<<32 - 1
+)
+
+// BinlogEventType is an event's type code.
+type BinlogEventType byte
+
+// Event types the decoder understands; others are returned with only their header.
+const (
+	QueryEventType              BinlogEventType = 2
+	RotateEventType             BinlogEventType = 4
+	FormatDescriptionEventType  BinlogEventType = 15
+	XidEventType                BinlogEventType = 16
+	TableMapEventType           BinlogEventType = 19
+	WriteRowsEventV1Type        BinlogEventType = 23
+	UpdateRowsEventV1Type       BinlogEventType = 24
+	DeleteRowsEventV1Type       BinlogEventType = 25
+	RowsQueryEventType          BinlogEventType = 29
+	WriteRowsEventType          BinlogEventType = 30
+	UpdateRowsEventType         BinlogEventType = 31
+	DeleteRowsEventType         BinlogEventType = 32
+	GtidEventType               BinlogEventType = 33
+	AnonymousGtidEventType      BinlogEventType = 34
+	PreviousGtidsEventType      BinlogEventType = 35
+	PartialUpdateRowsEventType  BinlogEventType = 39
+	TransactionPayloadEventType BinlogEventType = 40
+	GtidTaggedEventType         BinlogEventType = 42
+)
+
+// binlogEventTypeNames are the names SHOW BINLOG EVENTS uses, by type code.
+var binlogEventTypeNames = map[BinlogEventType]string{
+	2: "Query", 3: "Stop", 4: "Rotate", 5: "Intvar", 9: "Append_block", 11: "Delete_file",
+	13: "RAND", 14: "User var", 15: "Format_desc", 16: "Xid", 17: "Begin_load_query",
+	18: "Execute_load_query", 19: "Table_map", 23: "Write_rows_v1", 24: "Update_rows_v1",
+	25: "Delete_rows_v1", 26: "Incident", 27: "Heartbeat", 28: "Ignorable", 29: "Rows_query",
+	30: "Write_rows", 31: "Update_rows", 32: "Delete_rows", 33: "Gtid", 34: "Anonymous_Gtid",
+	35: "Previous_gtids", 36: "Transaction_context", 37: "View_change", 38: "XA_prepare",
+	39: "Update_rows_partial", 40: "Transaction_payload", 41: "Heartbeat_v2", 42: "Gtid_tagged_log_event",
+}
+
+func (t BinlogEventType) String() string {
+	if name, ok := binlogEventTypeNames[t]; ok {
+		return name
+	}
+	return "Unknown_" + strconv.Itoa(int(t))
+}
+
+// BinlogEventHeader is the common header of every event.
+type BinlogEventHeader struct {
+	Timestamp   uint32
+	Type        BinlogEventType
+	ServerID    uint32
+	EventLength uint32
+	NextPos     uint32
+	Flags       uint16
+}
+
+// DecodedEvent is an event decoded from binary log bytes. Data is one of the
+// *...Event types below for the event types this package understands, and
+// nil for any other.
+type DecodedEvent struct {
+	Header BinlogEventHeader
+	Pos    uint32 // offset of the event in its file
+	Data   any
+}
+
+// FormatDescriptionEvent describes how the events after it are laid out.
+type FormatDescriptionEvent struct {
+	BinlogVersion     uint16
+	ServerVersion     string
+	CreateTimestamp   uint32
+	HeaderLength      uint8
+	PostHeaderLengths []byte
+	ChecksumAlgorithm byte // 0 off, 1 CRC32, 255 unknown (servers before 5.6.1)
+}
+
+// postHeaderLength returns the post-header length of events of type t, or def
+// if the Format_description event does not list it.
+func (fde *FormatDescriptionEvent) postHeaderLength(t BinlogEventType, def int) int {
+	if fde == nil || int(t) < 1 || int(t) > len(fde.PostHeaderLengths) {
+		return def
+	}
+	return int(fde.PostHeaderLengths[t-1])
+}
+
+// PreviousGtidsEvent holds the GTID set executed before the file it starts.
+type PreviousGtidsEvent struct {
+	Set *GtidSet
+}
+
+// GtidEvent starts a transaction. Anonymous is set for Anonymous_Gtid events,
+// which carry no GTID; Tag for the Gtid_tagged events of tagged transactions
+// (MySQL 8.3+). Commit timestamps and server versions are zero when written by
+// servers that predate them (before 8.0.1 and 8.0.14).
+type GtidEvent struct {
+	Anonymous                bool
+	UUID                     string
+	Tag                      string
+	GNO                      int64
+	LastCommitted            int64
+	SequenceNumber           int64
+	ImmediateCommitTimestamp time.Time
+	OriginalCommitTimestamp  time.Time
+	TransactionLength        uint64
+	ImmediateServerVersion   uint32
+	OriginalServerVersion    uint32
+}
+
+// GTID returns the transaction's GTID as "uuid:gno" or "uuid:tag:gno", or
+// "ANONYMOUS".
+func (e *GtidEvent) GTID() string {
+	if e.Anonymous {
+		return "ANONYMOUS"
+	}
+	return fmt.Sprintf("%s:%d", gtidSetKey(e.UUID, e.Tag), e.GNO)
+}
+
+// UndecodedGtidEvent is a Gtid_tagged event this package could not decode:
+// it starts a transaction whose GTID is unknown.
+type UndecodedGtidEvent struct {
+	Err error
+}
+
+// TransactionPayloadEvent holds a whole transaction compressed
+// (binlog_transaction_compression=ON). Its events are not decompressed, so
+// its contents are unknown; its Gtid event precedes it uncompressed.
+type TransactionPayloadEvent struct {
+	Size int // compressed bytes, header fields included
+}
+
+// QueryEvent is a statement (statement-based DML, DDL, or BEGIN).
+type QueryEvent struct {
+	ThreadID  uint32
+	ExecTime  uint32
+	ErrorCode uint16
+	Schema    string
+	Query     string
+}
+
+// TableMapEvent maps a table id to a table for the rows events after it.
+type TableMapEvent struct {
+	TableID uint64
+	Schema  string
+	Table   string
+
+	// Column types and their metadata, needed to decode row images.
+	columnTypes []byte
+	columnMeta  []uint16
+}
+
+// RowsEvent is a Write/Update/Delete rows event. Its row images are decoded
+// against the preceding Table_map event with decodeRows.
+type RowsEvent struct {
+	Type    BinlogEventType
+	TableID uint64
+	Flags   uint16
+
+	columnCount  int
+	present      []byte // columns in the before image (the after image for Write_rows)
+	presentAfter []byte // columns in the after image of Update_rows
+	rowData      []byte
+}
+
+// RowsQueryEvent is the original statement of the following rows events,
+// logged with binlog_rows_query_log_events=ON.
+type RowsQueryEvent struct {
+	Query string
+}
+
+// XidEvent commits a transaction.
+type XidEvent struct {
+	Xid uint64
+}
+
+// RotateEvent points to the next binary log file.
+type RotateEvent struct {
+	Position uint64
+	NextFile string
+}
+
+// BinlogReader reads the events of a binary log file.
+type BinlogReader struct {
+	r   *bufio.Reader
+	pos uint32
+	fde *FormatDescriptionEvent
+}
+
+// NewBinlogReader starts reading a binary log file from r.
+func NewBinlogReader(r io.Reader) (*BinlogReader, error) {
+	br := &BinlogReader{r: bufio.NewReaderSize(r, 64*1024)}
+	magic := make([]byte, len(binlogMagic))
+	if _, err := io.ReadFull(br.r, magic); err != nil {
+		return nil, fmt.Errorf("failed to read binary log header: %w", err)
+	}
+	if bytes.Equal(magic, encryptedBinlogMagic) {
+		return nil, errors.New("encrypted binary logs are not supported")
+	}
+	if !bytes.Equal(magic, binlogMagic) {
+		return nil, fmt.Errorf("not a binary log file (magic %x)", magic)
+	}
+	br.pos = uint32(len(binlogMagic))
+	return br, nil
+}
+
+// Next returns the next event, or io.EOF at the end of the file.
+func (br *BinlogReader) Next() (*DecodedEvent, error) {
+	header := make([]byte, binlogEventHeaderLen)
+	if _, err := io.ReadFull(br.r, header); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("truncated event header at %d: %w", br.pos, err)
+	}
+	length := binary.LittleEndian.Uint32(header[9:])
+	if length < binlogEventHeaderLen || length > maxBinlogEventLen {
+		return nil, fmt.Errorf("invalid event length %d at %d", length, br.pos)
+	}
+	data := make([]byte, length)
+	copy(data, header)
+	if _, err := io.ReadFull(br.r, data[binlogEventHeaderLen:]); err != nil {
+		return nil, fmt.Errorf("truncated event at %d: %w", br.pos, err)
+	}
+
+	event, fde, err := decodeBinlogEvent(data, br.fde)
+	if err != nil {
+		return nil, fmt.Errorf("event at %d: %w", br.pos, err)
+	}
+	if fde != nil {
+		br.fde = fde
+	}
+	event.Pos = br.pos
+	br.pos += length
+	return event, nil
+}
+
+// readBinlogRange reads the events of the binary log file at path that start
+// in [start, end), e.g. one transaction located with SHOW BINLOG EVENTS. The
+// file's Format_description event is read first, then the reader seeks to start.
+func readBinlogRange(path string, start, end uint64) ([]*DecodedEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br, err := NewBinlogReader(f)
+	if err != nil {
+		return nil, err
+	}
+	first, err := br.Next()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := first.Data.(*FormatDescriptionEvent); !ok {
+		return nil, fmt.Errorf("%s does not start with a Format_description event", path)
+	}
+	if start < uint64(br.pos) || end > maxBinlogFileLen {
+		return nil, fmt.Errorf("invalid event range %d-%d", start, end)
+	}
+	if _, err := f.Seek(int64(start), io.SeekStart); err != nil {
+		return nil, err
+	}
+	br.r.Reset(f)
+	br.pos = uint32(start)
+
+	var events []*DecodedEvent
+	for uint64(br.pos) < end {
+		event, err := br.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s ends at %d, before %d", path, br.pos, end)
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// decodeBinlogEvent decodes one complete event (header, body and checksum)
+// laid out as fde describes. For a Format_description event it also returns
+// the new description, which applies to the events after it.
+func decodeBinlogEvent(data []byte, fde *FormatDescriptionEvent) (*DecodedEvent, *FormatDescriptionEvent, error) {
+	if len(data) < binlogEventHeaderLen {
+		return nil, nil, errors.New("event shorter than its header")
+	}
+	event := &DecodedEvent{Header: BinlogEventHeader{
+		Timestamp:   binary.LittleEndian.Uint32(data[0:]),
+		Type:        BinlogEventType(data[4]),
+		ServerID:    binary.LittleEndian.Uint32(data[5:]),
+		EventLength: binary.LittleEndian.Uint32(data[9:]),
+		NextPos:     binary.LittleEndian.Uint32(data[13:]),
+		Flags:       binary.LittleEndian.Uint16(data[17:]),
+	}}
+
+	if event.Header.Type == FormatDescriptionEventType {
+		fde, err := decodeFormatDescriptionEvent(data[binlogEventHeaderLen:])
+		if err != nil {
+			return nil, nil, err
+		}
+		if fde.ChecksumAlgorithm == binlogChecksumCRC32 {
+			if err := verifyBinlogChecksum(data); err != nil {
+				return nil, nil, err
+			}
+		}
+		event.Data = fde
+		return event, fde, nil
+	}
+
+	headerLen := binlogEventHeaderLen
+	if fde != nil && int(fde.HeaderLength) > headerLen {
+		headerLen = int(fde.HeaderLength)
+	}
+	end := len(data)
+	if fde != nil && fde.ChecksumAlgorithm == binlogChecksumCRC32 {
+		if err := verifyBinlogChecksum(data); err != nil {
+			return nil, nil, err
+		}
+		end -= binlogChecksumLen
+	}
+	if end < headerLen {
+		return nil, nil, fmt.Errorf("%s event too short", event.Header.Type)
+	}
+	body := data[headerLen:end]
+
+	var err error
+	switch t := event.Header.Type; t {
+	case PreviousGtidsEventType:
+		var set *GtidSet
+		if set, _, err = decodeGtidSet(body); err == nil {
+			event.Data = &PreviousGtidsEvent{Set: set}
+		}
+	case GtidEventType, AnonymousGtidEventType:
+		event.Data, err = decodeGtidEvent(body, t == AnonymousGtidEventType)
+	case GtidTaggedEventType:
+		// Reading goes on past a tagged event this decoder does not
+		// understand; the transaction is counted with its GTID unknown.
+		if gtid, decodeErr := decodeTaggedGtidEvent(body); decodeErr != nil {
+			event.Data = &UndecodedGtidEvent{Err: decodeErr}
+		} else {
+			event.Data = gtid
+		}
+	case TransactionPayloadEventType:
+		event.Data = &TransactionPayloadEvent{Size: len(body)}
+	case QueryEventType:
+		event.Data, err = decodeQueryEvent(body, fde.postHeaderLength(t, 13))
+	case TableMapEventType:
+		event.Data, err = decodeTableMapEvent(body, fde.postHeaderLength(t, 8))
+	case WriteRowsEventType, UpdateRowsEventType, DeleteRowsEventType, PartialUpdateRowsEventType,
+		WriteRowsEventV1Type, UpdateRowsEventV1Type, DeleteRowsEventV1Type:
+		event.Data, err = decodeRowsEvent(t, body, fde.postHeaderLength(t, 10))
+	case RowsQueryEventType:
+		if len(body) > 0 {
+			// The length byte is truncated to 255; the text runs to the end.
+			event.Data = &RowsQueryEvent{Query: string(body[1:])}
+		}
+	case XidEventType:
+		if len(body) < 8 {
+			err = errors.New("Xid event too short")
+		} else {
+			event.Data = &XidEvent{Xid: binary.LittleEndian.Uint64(body)}
+		}
+	case RotateEventType:
+		if len(body) < 8 {
+			err = errors.New("Rotate event too short")
+		} else {
+			event.Data = &RotateEvent{Position: binary.LittleEndian.Uint64(body), NextFile: string(body[8:])}
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s event: %w", event.Header.Type, err)
+	}
+	return event, nil, nil
+}
+
+// verifyBinlogChecksum checks the CRC32 at the end of an event.
+func verifyBinlogChecksum(data []byte) error {
+	if len(data) < binlogEventHeaderLen+binlogChecksumLen {
+		return errors.New("event too short for its checksum")
+	}
+	end := len(data) - binlogChecksumLen
+	expected := binary.LittleEndian.Uint32(data[end:])
+	if actual := crc32.ChecksumIEEE(data[:end]); actual != expected {
+		return fmt.Errorf("checksum mismatch (stored %08x, computed %08x)", expected, actual)
+	}
+	return nil
+}
+
+// decodeFormatDescriptionEvent decodes a Format_description body, checksum included.
+func decodeFormatDescriptionEvent(body []byte) (*FormatDescriptionEvent, error) {
+	const fixedLen = 2 + 50 + 4 + 1
+	if len(body) < fixedLen {
+		return nil, errors.New("Format_desc event too short")
+	}
+	fde := &FormatDescriptionEvent{
+		BinlogVersion:   binary.LittleEndian.Uint16(body),
+		ServerVersion:   string(bytes.TrimRight(body[2:52], "\x00")),
+		CreateTimestamp: binary.LittleEndian.Uint32(body[52:]),
+		HeaderLength:    body[56],
+	}
+	if fde.BinlogVersion != 4 {
+		return nil, fmt.Errorf("unsupported binary log version %d", fde.BinlogVersion)
+	}
+	rest := body[fixedLen:]
+	// Servers since 5.6.1 end the event with the checksum algorithm and a
+	// checksum (present even when checksums are off).
+	fde.ChecksumAlgorithm = 255
+	if m := versionPattern.FindStringSubmatch(fde.ServerVersion); m != nil {
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+		patch, _ := strconv.Atoi(m[3])
+		if major*10000+minor*100+patch >= 50601 && len(rest) >= 1+binlogChecksumLen {
+			fde.ChecksumAlgorithm = rest[len(rest)-1-binlogChecksumLen]
+			rest = rest[:len(rest)-1-binlogChecksumLen]
+		}
+	}
+	fde.PostHeaderLengths = bytes.Clone(rest)
+	return fde, nil
+}
+
+// decodeGtidEvent decodes a Gtid or Anonymous_Gtid body. Fields added by
+// later server versions are read only when present.
+func decodeGtidEvent(body []byte, anonymous bool) (*GtidEvent, error) {
+	if len(body) < 1+uuidBinaryLength+8 {
+		return nil, errors.New("too short")
+	}
+	e := &GtidEvent{
+		Anonymous: anonymous,
+		UUID:      formatUUIDBytes(body[1 : 1+uuidBinaryLength]),
+		GNO:       int64(binary.LittleEndian.Uint64(body[1+uuidBinaryLength:])),
+	}
+	if !anonymous && (e.GNO < 1 || e.GNO > maxGno) {
+		return nil, fmt.Errorf("invalid transaction number %d", e.GNO)
+	}
+	pos := 1 + uuidBinaryLength + 8
+	// Logical clock: a type byte (2), last_committed, sequence_number.
+	if len(body) >= pos+17 {
+		e.LastCommitted = int64(binary.LittleEndian.Uint64(body[pos+1:]))
+		e.SequenceNumber = int64(binary.LittleEndian.Uint64(body[pos+9:]))
+		pos += 17
+	}
+	// Commit timestamps are 7-byte microsecond counts; the top bit of the
+	// immediate one says whether a different original one follows.
+	if len(body) >= pos+7 {
+		immediate := uint56(body[pos:])
+		pos += 7
+		original := immediate
+		if immediate&(1<<55) != 0 {
+			immediate &^= 1 << 55
+			if len(body) < pos+7 {
+				return nil, errors.New("truncated original_commit_timestamp")
+			}
+			original = uint56(body[pos:])
+			pos += 7
+		}
+		e.ImmediateCommitTimestamp = time.UnixMicro(int64(immediate)).UTC()
+		e.OriginalCommitTimestamp = time.UnixMicro(int64(original)).UTC()
+
+		if n, size := readPackedInt(body[pos:]); size > 0 {
+			e.TransactionLength = n
+			pos += size
+		}
+		if len(body) >= pos+4 {
+			e.ImmediateServerVersion = binary.LittleEndian.Uint32(body[pos:])
+			pos += 4
+			e.OriginalServerVersion = e.ImmediateServerVersion
+			if e.ImmediateServerVersion&(1<<31) != 0 {
+				e.ImmediateServerVersion &^= 1 << 31
+				if len(body) >= pos+4 {
+					e.OriginalServerVersion = binary.LittleEndian.Uint32(body[pos:])
+				}
+			}
+		}
+	}
+	return e, nil
+}
+
+// decodeTaggedGtidEvent decodes a Gtid_tagged body, written in the
+// mysql::serialization format: a message header (the message size and the id
+// of the last field a reader must understand), then each field that is
+// present as its id and value. The fields are, by id: gtid_flags, uuid (16
+// bytes), gno, tag, last_committed, sequence_number, immediate and original
+// commit timestamps, transaction_length, immediate and original server
+// versions, and commit_group_ticket.
+func decodeTaggedGtidEvent(body []byte) (*GtidEvent, error) {
+	r := &serialReader{b: body}
+	size := r.uint()
+	lastRequired := r.uint()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if size < uint64(r.pos) || size > uint64(len(body)) {
+		return nil, fmt.Errorf("invalid message size %d", size)
+	}
+	r.b = body[:size]
+
+	e := &GtidEvent{}
+	var immediate, original uint64
+	hasOriginal, hasOriginalVersion := false, false
+	last := int64(-1)
+	for r.err == nil && r.pos < len(r.b) {
+		id := r.uint()
+		if r.err != nil {
+			break
+		}
+		if int64(id) <= last {
+			return nil, fmt.Errorf("field %d out of order", id)
+		}
+		last = int64(id)
+		switch id {
+		case 0:
+			r.uint() // gtid_flags
+		case 1:
+			var uuid [uuidBinaryLength]byte
+			for i := range uuid {
+				b := r.uint()
+				if b > 0xff {
+					return nil, errors.New("invalid uuid byte")
+				}
+				uuid[i] = byte(b)
+			}
+			e.UUID = formatUUIDBytes(uuid[:])
+		case 2:
+			e.GNO = r.int()
+		case 3:
+			e.Tag = r.string(maxGtidTagLength)
+		case 4:
+			e.LastCommitted = r.int()
+		case 5:
+			e.SequenceNumber = r.int()
+		case 6:
+			immediate = r.uint()
+		case 7:
+			original, hasOriginal = r.uint(), true
+		case 8:
+			e.TransactionLength = r.uint()
+		case 9:
+			e.ImmediateServerVersion = uint32(r.uint())
+		case 10:
+			e.OriginalServerVersion, hasOriginalVersion = uint32(r.uint()), true
+		case 11:
+			r.uint() // commit_group_ticket
+		default:
+			if id <= lastRequired {
+				return nil, fmt.Errorf("unknown field %d written by a newer server", id)
+			}
+			// The fields of later versions that readers may ignore end the
+			// message; their types are unknown, so skip them all.
+			r.pos = len(r.b)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if e.UUID == "" {
+		return nil, errors.New("no uuid")
+	}
+	if e.GNO < 1 || e.GNO > maxGno {
+		return nil, fmt.Errorf("invalid transaction number %d", e.GNO)
+	}
+	if e.Tag != "" && !gtidTagPattern.MatchString(e.Tag) {
+		return nil, fmt.Errorf("invalid tag %q", e.Tag)
+	}
+	if !hasOriginal {
+		original = immediate
+	}
+	if !hasOriginalVersion {
+		e.OriginalServerVersion = e.ImmediateServerVersion
+	}
+	e.ImmediateCommitTimestamp = time.UnixMicro(int64(immediate)).UTC()
+	e.OriginalCommitTimestamp = time.UnixMicro(int64(original)).UTC()
+	return e, nil
+}
+
+// maxGtidTagLength is the longest tag MySQL accepts.
+const maxGtidTagLength = 32
+
+// serialReader reads mysql::serialization values. Integers are variable
+// length: the number of trailing one bits in the first byte is the number of
+// bytes that follow it, and the value fills the bits above them,
+// little-endian (9-byte integers keep all 64 bits in the last 8 bytes).
+// Signed integers are zigzag-encoded; strings are a length and the bytes.
+// The first error sticks.
+type serialReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *serialReader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.b) {
+		r.err = errors.New("truncated")
+		return 0
+	}
+	n := bits.TrailingZeros8(^r.b[r.pos]) + 1
+	if len(r.b)-r.pos < n {
+		r.err = errors.New("truncated")
+		return 0
+	}
+	var v uint64
+	if n == 9 {
+		v = binary.LittleEndian.Uint64(r.b[r.pos+1:])
+	} else {
+		for i := n - 1; i >= 0; i-- {
+			v = v<<8 | uint64(r.b[r.pos+i])
+		}
+		v >>= n
+	}
+	r.pos += n
+	return v
+}
+
+func (r *serialReader) int() int64 {
+	u := r.uint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *serialReader) string(maxLen int) string {
+	n := r.uint()
+	if r.err != nil {
+		return ""
+	}
+	if n > uint64(maxLen) || n > uint64(len(r.b)-r.pos) {
+		r.err = fmt.Errorf("invalid string length %d", n)
+		return ""
+	}
+	s := string(r.b[r.pos : r.pos+int(n)])
+	r.pos += int(n)
+	return s
+}
+
+// uint56 reads a 7-byte little-endian integer.
+func uint56(b []byte) uint64 {
+	var v uint64
+	for i := 6; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// readPackedInt reads a length-encoded integer and returns it with the number
+// of bytes used, or size 0 if b is too short.
+func readPackedInt(b []byte) (n uint64, size int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	switch b[0] {
+	case 0xfc:
+		size = 3
+	case 0xfd:
+		size = 4
+	case 0xfe:
+		size = 9
+	default:
+		return uint64(b[0]), 1
+	}
+	if len(b) < size {
+		return 0, 0
+	}
+	for i := size - 1; i >= 1; i-- {
+		n = n<<8 | uint64(b[i])
+	}
+	return n, size
+}
+
+// decodeQueryEvent decodes a Query body:
+// thread_id(4) exec_time(4) schema_len(1) error_code(2) status_vars_len(2),
+// then the status variables, the schema, a NUL, and the statement.
+func decodeQueryEvent(body []byte, postHeaderLen int) (*QueryEvent, error) {
+	if postHeaderLen < 13 || len(body) < postHeaderLen {
+		return nil, errors.New("too short")
+	}
+	e := &QueryEvent{
+		ThreadID:  binary.LittleEndian.Uint32(body),
+		ExecTime:  binary.LittleEndian.Uint32(body[4:]),
+		ErrorCode: binary.LittleEndian.Uint16(body[9:]),
+	}
+	schemaLen := int(body[8])
+	statusVarsLen := int(binary.LittleEndian.Uint16(body[11:]))
+	pos := postHeaderLen + statusVarsLen
+	if len(body) < pos+schemaLen+1 {
+		return nil, errors.New("truncated schema")
+	}
+	e.Schema = string(body[pos : pos+schemaLen])
+	e.Query = string(body[pos+schemaLen+1:])
+	return e, nil
+}
+
+// readTableID reads a 6-byte table id, or a 4-byte one from servers whose
+// post-header is 6 bytes long.
+func readTableID(body []byte, postHeaderLen int) (id uint64, size int) {
+	if postHeaderLen == 6 {
+		return uint64(binary.LittleEndian.Uint32(body)), 4
+	}
+	return uint64(binary.LittleEndian.Uint32(body)) | uint64(binary.LittleEndian.Uint16(body[4:]))<<32, 6
+}
+
+// decodeTableMapEvent decodes the table id and name of a Table_map body.
+func decodeTableMapEvent(body []byte, postHeaderLen int) (*TableMapEvent, error) {
+	if len(body) < postHeaderLen || postHeaderLen < 6 {
+		return nil, errors.New("too short")
+	}
+	e := &TableMapEvent{}
+	e.TableID, _ = readTableID(body, postHeaderLen)
+	rest := body[postHeaderLen:]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0])+1 {
+		return nil, errors.New("truncated schema name")
+	}
+	e.Schema = string(rest[1 : 1+rest[0]])
+	rest = rest[1+int(rest[0])+1:]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, errors.New("truncated table name")
+	}
+	e.Table = string(rest[1 : 1+rest[0]])
+	rest = rest[1+int(rest[0]):]
+	// Events written before column types were logged (or hand-built ones)
+	// end here; their rows cannot be decoded.
+	if len(rest) <= 1 {
+		return e, nil
+	}
+	var err error
+	e.columnTypes, e.columnMeta, err = decodeTableMapColumns(rest[1:])
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// decodeRowsEvent decodes a rows event: the post-header (table id, flags and,
+// for v2 events, the length of the extra data that follows), the column
+// count, the bitmaps of the columns in each image, and the raw row images.
+func decodeRowsEvent(t BinlogEventType, body []byte, postHeaderLen int) (*RowsEvent, error) {
+	if len(body) < postHeaderLen || postHeaderLen < 6 {
+		return nil, errors.New("too short")
+	}
+	e := &RowsEvent{Type: t}
+	var size int
+	e.TableID, size = readTableID(body, postHeaderLen)
+	e.Flags = binary.LittleEndian.Uint16(body[size:])
+
+	rest := body[postHeaderLen:]
+	if postHeaderLen == size+4 {
+		// The extra data length counts its own two bytes.
+		extraLen := int(binary.LittleEndian.Uint16(body[size+2:]))
+		if extraLen < 2 || len(rest) < extraLen-2 {
+			return nil, errors.New("truncated extra data")
+		}
+		rest = rest[extraLen-2:]
+	}
+	count, n := readPackedInt(rest)
+	if n == 0 || count > uint64(len(rest)*8) {
+		return nil, errors.New("truncated column count")
+	}
+	e.columnCount = int(count)
+	rest = rest[n:]
+	bitmapLen := (e.columnCount + 7) / 8
+	if len(rest) < bitmapLen {
+		return nil, errors.New("truncated column bitmap")
+	}
+	e.present, rest = rest[:bitmapLen], rest[bitmapLen:]
+	if t == UpdateRowsEventType || t == UpdateRowsEventV1Type || t == PartialUpdateRowsEventType {
+		if len(rest) < bitmapLen {
+			return nil, errors.New("truncated column bitmap")
+		}
+		e.presentAfter, rest = rest[:bitmapLen], rest[bitmapLen:]
+	}
+	e.rowData = rest
+	return e, nil
+}
+
+// BinlogFileSummary is what a binary log file says about GTIDs.
+type BinlogFileSummary struct {
+	ServerVersion string
+	// Previous is the GTID set executed before the file (its Previous_gtids event).
+	Previous *GtidSet
+	// Contained is the GTID set of the transactions in the file.
+	Contained *GtidSet
+	// Transactions counts Gtid events, Anonymous counts Anonymous_Gtid events.
+	Transactions int
+	Anonymous    int
+	// Undecoded counts tagged Gtid events that could not be decoded: their
+	// transactions are missing from Contained.
+	Undecoded int
+	// Compressed counts Transaction_payload events, whose contents are unknown.
+	Compressed int
+	// NextFile is the file named by a closing Rotate event, if any.
+	NextFile string
+}
+
+// SummarizeBinlogFile reads a binary log file and reports the GTID sets
+// preceding and contained in it.
+func SummarizeBinlogFile(r io.Reader) (*BinlogFileSummary, error) {
+	br, err := NewBinlogReader(r)
+	if err != nil {
+		return nil, err
+	}
+	res := &BinlogFileSummary{Previous: &GtidSet{intervals: map[string][]Interval{}}}
+	contained := map[string][]Interval{}
+	for {
+		event, err := br.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch data := event.Data.(type) {
+		case *FormatDescriptionEvent:
+			res.ServerVersion = data.ServerVersion
+		case *PreviousGtidsEvent:
+			res.Previous = data.Set
+		case *GtidEvent:
+			if data.Anonymous {
+				res.Anonymous++
+				continue
+			}
+			res.Transactions++
+			key := gtidSetKey(data.UUID, data.Tag)
+			contained[key] = append(contained[key], Interval{Start: data.GNO, End: data.GNO})
+		case *UndecodedGtidEvent:
+			res.Undecoded++
+		case *TransactionPayloadEvent:
+			res.Compressed++
+		case *RotateEvent:
+			res.NextFile = data.NextFile
+		}
+	}
+	res.Contained = &GtidSet{intervals: map[string][]Interval{}}
+	for key, intervals := range contained {
+		res.Contained.add(key, normalizeIntervals(intervals))
+	}
+	return res, nil
+}
+
+// ReportBinlogFile summarizes the binary log file read from r and writes the
+// GTID sets preceding and contained in it to w. name only labels the output.
+func ReportBinlogFile(w io.Writer, name string, r io.Reader) error {
+	summary, err := SummarizeBinlogFile(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	fmt.Fprintln(w, blue("[+]"), name, "written by MySQL", summary.ServerVersion)
+	fmt.Fprintln(w, blue("[i]"), "Previous GTIDs:", summary.Previous)
+	if summary.Contained.IsEmpty() {
+		fmt.Fprintln(w, blue("[i]"), "Contains no GTID transactions")
+	} else {
+		fmt.Fprintln(w, blue("[i]"), "Contains", summary.Contained.Summary("")+":", summary.Contained)
+	}
+	if summary.Anonymous > 0 {
+		fmt.Fprintln(w, yellow("[!]"), summary.Anonymous, "anonymous transactions (written without GTIDs)")
+	}
+	if summary.Undecoded > 0 {
+		fmt.Fprintln(w, yellow("[!]"), summary.Undecoded, "transactions with tagged GTIDs that could not be decoded (not in the set above)")
+	}
+	if summary.Compressed > 0 {
+		fmt.Fprintln(w, yellow("[!]"), summary.Compressed, "compressed transactions (binlog_transaction_compression), contents unknown")
+	}
+	if summary.NextFile != "" {
+		fmt.Fprintln(w, blue("[i]"), "Next file:", summary.NextFile)
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package gtids
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// binlogBuilder writes a binary log file event by event, the way a MySQL
+// 8.0 server lays it out.
+type binlogBuilder struct {
+	buf      bytes.Buffer
+	checksum bool
+}
+
+func newBinlogBuilder(checksum bool) *binlogBuilder {
+	b := &binlogBuilder{checksum: checksum}
+	b.buf.Write(binlogMagic)
+	alg := byte(0)
+	if checksum {
+		alg = binlogChecksumCRC32
+	}
+	// Format_description: version, server version, timestamp, header length,
+	// post-header lengths by type, checksum algorithm.
+	body := binary.LittleEndian.AppendUint16(nil, 4)
+	body = append(body, make([]byte, 50)...)
+	copy(body[2:], "8.0.36-log")
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	body = append(body, binlogEventHeaderLen)
+	postHeaders := make([]byte, 41)
+	postHeaders[QueryEventType-1] = 13
+	postHeaders[RotateEventType-1] = 8
+	postHeaders[TableMapEventType-1] = 8
+	for _, t := range []BinlogEventType{WriteRowsEventType, UpdateRowsEventType, DeleteRowsEventType} {
+		postHeaders[t-1] = 10
+	}
+	body = append(body, postHeaders...)
+	body = append(body, alg)
+	b.event(FormatDescriptionEventType, body, true)
+	return b
+}
+
+// event appends an event; the Format_description always carries 4 checksum
+// bytes, other events only when checksums are on.
+func (b *binlogBuilder) event(t BinlogEventType, body []byte, withChecksumBytes bool) {
+	length := binlogEventHeaderLen + len(body)
+	if withChecksumBytes || b.checksum {
+		length += binlogChecksumLen
+	}
+	start := b.buf.Len()
+	header := binary.LittleEndian.AppendUint32(nil, 1700000000)
+	header = append(header, byte(t))
+	header = binary.LittleEndian.AppendUint32(header, 7)
+	header = binary.LittleEndian.AppendUint32(header, uint32(length))
+	header = binary.LittleEndian.AppendUint32(header, uint32(start+length))
+	header = binary.LittleEndian.AppendUint16(header, 0)
+	b.buf.Write(header)
+	b.buf.Write(body)
+	if withChecksumBytes || b.checksum {
+		var crc uint32
+		if b.checksum {
+			crc = crc32.ChecksumIEEE(b.buf.Bytes()[start:])
+		}
+		b.buf.Write(binary.LittleEndian.AppendUint32(nil, crc))
+	}
+}
+
+func (b *binlogBuilder) add(t BinlogEventType, body []byte) *binlogBuilder {
+	b.event(t, body, false)
+	return b
+}
+
+func previousGtidsBody(t *testing.T, set string) []byte {
+	data, err := mustGtidSet(t, set).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	return data
+}
+
+func gtidBody(t *testing.T, uuid string, gno int64, committed time.Time) []byte {
+	uuidBytes, err := parseUUIDBytes(uuid)
+	if err != nil {
+		t.Fatalf("bad uuid: %v", err)
+	}
+	body := append([]byte{1}, uuidBytes[:]...)
+	body = binary.LittleEndian.AppendUint64(body, uint64(gno))
+	body = append(body, 2)
+	body = binary.LittleEndian.AppendUint64(body, uint64(gno-1))
+	body = binary.LittleEndian.AppendUint64(body, uint64(gno))
+	// Immediate timestamp with the "original follows" bit, then the original.
+	immediate := uint64(committed.UnixMicro()) | 1<<55
+	body = append(body, binary.LittleEndian.AppendUint64(nil, immediate)[:7]...)
+	body = append(body, binary.LittleEndian.AppendUint64(nil, uint64(committed.Add(-time.Second).UnixMicro()))[:7]...)
+	body = append(body, 0xfc, 0x2c, 0x01) // transaction length 300
+	return binary.LittleEndian.AppendUint32(body, 80036)
+}
+
+// serialUint encodes v the way mysql::serialization writes unsigned integers.
+func serialUint(v uint64) []byte {
+	for n := 1; n <= 8; n++ {
+		if v < 1<<(7*n) {
+			return binary.LittleEndian.AppendUint64(nil, v<<n|(1<<(n-1)-1))[:n]
+		}
+	}
+	return binary.LittleEndian.AppendUint64([]byte{0xff}, v)
+}
+
+func serialInt(v int64) []byte {
+	return serialUint(uint64(v<<1 ^ v>>63))
+}
+
+// taggedGtidBody builds a Gtid_tagged body: its fields in id order, after the
+// message size and the id of the last field a reader must understand.
+func taggedGtidBody(t *testing.T, uuid, tag string, gno int64, committed time.Time) []byte {
+	uuidBytes, err := parseUUIDBytes(uuid)
+	if err != nil {
+		t.Fatalf("bad uuid: %v", err)
+	}
+	fields := append(serialUint(0), serialUint(0)...) // gtid_flags
+	fields = append(fields, serialUint(1)...)
+	for _, b := range uuidBytes {
+		fields = append(fields, serialUint(uint64(b))...)
+	}
+	fields = append(append(fields, serialUint(2)...), serialInt(gno)...)
+	fields = append(append(fields, serialUint(3)...), serialUint(uint64(len(tag)))...)
+	fields = append(fields, tag...)
+	fields = append(append(fields, serialUint(4)...), serialInt(gno-1)...)
+	fields = append(append(fields, serialUint(5)...), serialInt(gno)...)
+	fields = append(append(fields, serialUint(6)...), serialUint(uint64(committed.UnixMicro()))...)
+	fields = append(append(fields, serialUint(8)...), serialUint(300)...)
+	fields = append(append(fields, serialUint(9)...), serialUint(80400)...)
+	// A field from a later version that readers may skip.
+	fields = append(append(fields, serialUint(20)...), 0xde, 0xad)
+
+	lastRequired := serialUint(9)
+	size := len(fields) + len(lastRequired) + 1
+	for len(serialUint(uint64(size)))+len(lastRequired)+len(fields) != size {
+		size++
+	}
+	return append(append(serialUint(uint64(size)), lastRequired...), fields...)
+}
+
+func queryBody(schema, query string) []byte {
+	body := binary.LittleEndian.AppendUint32(nil, 42)
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	body = append(body, byte(len(schema)))
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	body = append(body, schema...)
+	body = append(body, 0)
+	return append(body, query...)
+}
+
+func tableMapBody(tableID uint64, schema, table string) []byte {
+	body := binary.LittleEndian.AppendUint64(nil, tableID)[:6]
+	body = binary.LittleEndian.AppendUint16(body, 1)
+	body = append(body, byte(len(schema)))
+	body = append(body, schema...)
+	body = append(body, 0, byte(len(table)))
+	body = append(body, table...)
+	return append(body, 0, 1, 3, 0) // one INT column
+}
+
+func rowsBody(tableID uint64) []byte {
+	body := binary.LittleEndian.AppendUint64(nil, tableID)[:6]
+	body = binary.LittleEndian.AppendUint16(body, 1)
+	body = binary.LittleEndian.AppendUint16(body, 2)
+	return append(body, 1, 0xff, 0, 1, 0, 0, 0) // row image, left undecoded
+}
+
+func sampleBinlog(t *testing.T, checksum bool) *binlogBuilder {
+	committed := time.Date(2026, 3, 12, 3, 12, 0, 0, time.UTC)
+	b := newBinlogBuilder(checksum)
+	b.add(PreviousGtidsEventType, previousGtidsBody(t, uuidA+":1-10"))
+	b.add(GtidEventType, gtidBody(t, uuidA, 11, committed)).
+		add(QueryEventType, queryBody("shop", "BEGIN")).
+		add(TableMapEventType, tableMapBody(90, "shop", "orders")).
+		add(WriteRowsEventType, rowsBody(90)).
+		add(XidEventType, binary.LittleEndian.AppendUint64(nil, 77))
+	b.add(GtidEventType, gtidBody(t, uuidB, 3, committed)).
+		add(QueryEventType, queryBody("shop", "CREATE TABLE t (id int)"))
+	b.add(RotateEventType, append(binary.LittleEndian.AppendUint64(nil, 4), "binlog.000002"...))
+	return b
+}
+
+func TestBinlogReader_DecodesEvents(t *testing.T) {
+	for _, checksum := range []bool{true, false} {
+		reader, err := NewBinlogReader(bytes.NewReader(sampleBinlog(t, checksum).buf.Bytes()))
+		if err != nil {
+			t.Fatalf("NewBinlogReader failed: %v", err)
+		}
+		var types []string
+		var events []*DecodedEvent
+		for {
+			event, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("checksum=%v: Next failed: %v", checksum, err)
+			}
+			types = append(types, event.Header.Type.String())
+			events = append(events, event)
+		}
+		expected := "Format_desc Previous_gtids Gtid Query Table_map Write_rows Xid Gtid Query Rotate"
+		if got := strings.Join(types, " "); got != expected {
+			t.Fatalf("checksum=%v: expected %s, got %s", checksum, expected, got)
+		}
+
+		if fde := events[0].Data.(*FormatDescriptionEvent); fde.ServerVersion != "8.0.36-log" || (fde.ChecksumAlgorithm == binlogChecksumCRC32) != checksum {
+			t.Errorf("unexpected Format_description %+v", fde)
+		}
+		if events[1].Pos != 4+events[0].Header.EventLength || events[1].Header.NextPos != events[2].Pos {
+			t.Errorf("positions do not chain: %d/%d/%d", events[1].Pos, events[1].Header.NextPos, events[2].Pos)
+		}
+		gtid := events[2].Data.(*GtidEvent)
+		if gtid.GTID() != uuidA+":11" || gtid.LastCommitted != 10 || gtid.SequenceNumber != 11 || gtid.TransactionLength != 300 || gtid.ImmediateServerVersion != 80036 {
+			t.Errorf("unexpected Gtid event %+v", gtid)
+		}
+		if !gtid.ImmediateCommitTimestamp.Equal(time.Date(2026, 3, 12, 3, 12, 0, 0, time.UTC)) || !gtid.OriginalCommitTimestamp.Equal(time.Date(2026, 3, 12, 3, 11, 59, 0, time.UTC)) {
+			t.Errorf("unexpected commit timestamps %v / %v", gtid.ImmediateCommitTimestamp, gtid.OriginalCommitTimestamp)
+		}
+		if query := events[3].Data.(*QueryEvent); query.Schema != "shop" || query.Query != "BEGIN" || query.ThreadID != 42 {
+			t.Errorf("unexpected Query event %+v", query)
+		}
+		if tableMap := events[4].Data.(*TableMapEvent); tableMap.TableID != 90 || tableMap.Schema != "shop" || tableMap.Table != "orders" {
+			t.Errorf("unexpected Table_map event %+v", tableMap)
+		}
+		if rows := events[5].Data.(*RowsEvent); rows.TableID != 90 || rows.Type != WriteRowsEventType {
+			t.Errorf("unexpected rows event %+v", rows)
+		}
+		if xid := events[6].Data.(*XidEvent); xid.Xid != 77 {
+			t.Errorf("unexpected Xid event %+v", xid)
+		}
+		if rotate := events[9].Data.(*RotateEvent); rotate.NextFile != "binlog.000002" || rotate.Position != 4 {
+			t.Errorf("unexpected Rotate event %+v", rotate)
+		}
+	}
+}
+
+func TestSummarizeBinlogFile(t *testing.T) {
+	summary, err := SummarizeBinlogFile(bytes.NewReader(sampleBinlog(t, true).buf.Bytes()))
+	if err != nil {
+		t.Fatalf("SummarizeBinlogFile failed: %v", err)
+	}
+	if summary.Previous.String() != uuidA+":1-10" {
+		t.Errorf("unexpected previous set %q", summary.Previous)
+	}
+	if summary.Contained.String() != uuidA+":11,"+uuidB+":3" || summary.Transactions != 2 {
+		t.Errorf("unexpected contained set %q (%d transactions)", summary.Contained, summary.Transactions)
+	}
+	if summary.NextFile != "binlog.000002" || summary.ServerVersion != "8.0.36-log" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestReportBinlogFile(t *testing.T) {
+	var out bytes.Buffer
+	if err := ReportBinlogFile(&out, "binlog.000001", bytes.NewReader(sampleBinlog(t, true).buf.Bytes())); err != nil {
+		t.Fatalf("ReportBinlogFile failed: %v", err)
+	}
+	for _, line := range []string{
+		"binlog.000001 written by MySQL 8.0.36-log",
+		"Previous GTIDs: " + uuidA + ":1-10",
+		"Contains 2 transactions across 2 UUIDs: " + uuidA + ":11," + uuidB + ":3",
+		"Next file: binlog.000002",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("expected the report to contain %q, got:\n%s", line, out.String())
+		}
+	}
+
+	if err := ReportBinlogFile(&out, "truncated", bytes.NewReader([]byte{0xfe, 'b'})); err == nil || !strings.HasPrefix(err.Error(), "truncated: ") {
+		t.Errorf("expected an error naming the file, got %v", err)
+	}
+}
+
+func TestBinlogReader_TaggedAndCompressedTransactions(t *testing.T) {
+	committed := time.Date(2026, 3, 12, 3, 12, 0, 0, time.UTC)
+	b := newBinlogBuilder(true)
+	b.add(PreviousGtidsEventType, previousGtidsBody(t, uuidA+":1-10"))
+	b.add(GtidTaggedEventType, taggedGtidBody(t, uuidA, "batch", 7, committed)).
+		add(QueryEventType, queryBody("shop", "BEGIN")).
+		add(XidEventType, binary.LittleEndian.AppendUint64(nil, 78))
+	b.add(GtidEventType, gtidBody(t, uuidA, 11, committed)).
+		add(TransactionPayloadEventType, []byte{2, 1, 0, 0, 0x9f, 0x80})
+	// A tagged event this decoder does not understand does not stop reading.
+	b.add(GtidTaggedEventType, []byte{0x7f}).
+		add(QueryEventType, queryBody("shop", "BEGIN"))
+
+	reader, err := NewBinlogReader(bytes.NewReader(b.buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewBinlogReader failed: %v", err)
+	}
+	var events []*DecodedEvent
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		events = append(events, event)
+	}
+	gtid, ok := events[2].Data.(*GtidEvent)
+	if !ok {
+		t.Fatalf("expected the tagged Gtid event to decode, got %#v", events[2].Data)
+	}
+	if gtid.GTID() != uuidA+":batch:7" || gtid.LastCommitted != 6 || gtid.SequenceNumber != 7 || gtid.TransactionLength != 300 ||
+		gtid.ImmediateServerVersion != 80400 || gtid.OriginalServerVersion != 80400 {
+		t.Errorf("unexpected tagged Gtid event %+v", gtid)
+	}
+	if !gtid.ImmediateCommitTimestamp.Equal(committed) || !gtid.OriginalCommitTimestamp.Equal(committed) {
+		t.Errorf("unexpected commit timestamps %v / %v", gtid.ImmediateCommitTimestamp, gtid.OriginalCommitTimestamp)
+	}
+	if _, ok := events[6].Data.(*TransactionPayloadEvent); !ok {
+		t.Errorf("expected a Transaction_payload event, got %#v", events[6].Data)
+	}
+	if _, ok := events[7].Data.(*UndecodedGtidEvent); !ok {
+		t.Errorf("expected an undecoded Gtid_tagged event, got %#v", events[7].Data)
+	}
+
+	summary, err := SummarizeBinlogFile(bytes.NewReader(b.buf.Bytes()))
+	if err != nil {
+		t.Fatalf("SummarizeBinlogFile failed: %v", err)
+	}
+	if summary.Contained.String() != uuidA+":11:batch:7" || summary.Transactions != 2 || summary.Undecoded != 1 || summary.Compressed != 1 {
+		t.Errorf("unexpected summary %+v (contained %q)", summary, summary.Contained)
+	}
+}
+
+func TestSerialReader(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 300, 1<<21 - 1, 1 << 21, 1<<56 - 1, 1 << 56, 1<<64 - 1} {
+		r := &serialReader{b: serialUint(v)}
+		if got := r.uint(); r.err != nil || got != v || r.pos != len(r.b) {
+			t.Errorf("uint %d: got %d (%v, %d of %d bytes)", v, got, r.err, r.pos, len(r.b))
+		}
+	}
+	for _, v := range []int64{0, -1, 1, -64, 64, maxGno, -maxGno - 1} {
+		r := &serialReader{b: serialInt(v)}
+		if got := r.int(); r.err != nil || got != v {
+			t.Errorf("int %d: got %d (%v)", v, got, r.err)
+		}
+	}
+	r := &serialReader{b: serialUint(300)[:1]}
+	if r.uint(); r.err == nil {
+		t.Error("expected a truncated integer to fail")
+	}
+}
+
+func TestBinlogReader_RejectsBadInput(t *testing.T) {
+	valid := sampleBinlog(t, true).buf.Bytes()
+	corrupted := bytes.Clone(valid)
+	corrupted[len(corrupted)-10] ^= 0xff
+
+	tests := []struct {
+		name  string
+		data  []byte
+		error string
+	}{
+		{name: "not a binlog", data: []byte("hello world"), error: "not a binary log"},
+		{name: "encrypted", data: append([]byte{0xfd, 'b', 'i', 'n'}, make([]byte, 100)...), error: "encrypted"},
+		{name: "checksum mismatch", data: corrupted, error: "checksum mismatch"},
+		{name: "truncated", data: valid[:len(valid)-3], error: "truncated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SummarizeBinlogFile(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected an error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,456 @@
+package gtids
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Row image decoding. A Table_map event lists each column's type and
+// type-specific metadata (lengths, precision, fractional-second digits); a
+// rows event then holds, per row, a NULL bitmap and the values of the columns
+// present in the image, back to back, in the server's storage format.
+
+// Column types as MySQL numbers them (enum_field_types).
+const (
+	mysqlTypeDecimal    = 0
+	mysqlTypeTiny       = 1
+	mysqlTypeShort      = 2
+	mysqlTypeLong       = 3
+	mysqlTypeFloat      = 4
+	mysqlTypeDouble     = 5
+	mysqlTypeNull       = 6
+	mysqlTypeTimestamp  = 7
+	mysqlTypeLongLong   = 8
+	mysqlTypeInt24      = 9
+	mysqlTypeDate       = 10
+	mysqlTypeTime       = 11
+	mysqlTypeDatetime   = 12
+	mysqlTypeYear       = 13
+	mysqlTypeVarchar    = 15
+	mysqlTypeBit        = 16
+	mysqlTypeTimestamp2 = 17
+	mysqlTypeDatetime2  = 18
+	mysqlTypeTime2      = 19
+	mysqlTypeJSON       = 245
+	mysqlTypeNewDecimal = 246
+	mysqlTypeEnum       = 247
+	mysqlTypeSet        = 248
+	mysqlTypeTinyBlob   = 249
+	mysqlTypeMediumBlob = 250
+	mysqlTypeLongBlob   = 251
+	mysqlTypeBlob       = 252
+	mysqlTypeVarString  = 253
+	mysqlTypeString     = 254
+	mysqlTypeGeometry   = 255
+)
+
+// sqlLiteral is a decoded value already rendered as SQL, e.g. a DECIMAL
+// ("-12.50") or a quoted temporal value ("'2024-01-15 10:30:45'").
+type sqlLiteral string
+
+// unsupportedValue stands for a value that was skipped over but cannot be
+// rendered as SQL faithfully (JSON's binary format, spatial types).
+type unsupportedValue struct {
+	columnType byte
+}
+
+// rowImage is one before or after image: the values of the columns present in
+// it, nil for NULL. Values are int64, uint64, float32, float64, []byte,
+// sqlLiteral or unsupportedValue.
+type rowImage struct {
+	present []bool
+	values  []any
+}
+
+// rowChange is one row of a rows event: Write_rows has only an after image,
+// Delete_rows only a before image, Update_rows both.
+type rowChange struct {
+	before, after *rowImage
+}
+
+// decodeTableMapColumns decodes the column part of a Table_map body, after the
+// table name: column count, types, and the metadata of each column.
+func decodeTableMapColumns(b []byte) (types []byte, meta []uint16, err error) {
+	count, n := readPackedInt(b)
+	if n == 0 || count > uint64(len(b)) {
+		return nil, nil, errors.New("truncated column count")
+	}
+	b = b[n:]
+	if uint64(len(b)) < count {
+		return nil, nil, errors.New("truncated column types")
+	}
+	types = b[:count]
+	b = b[count:]
+	metaLen, n := readPackedInt(b)
+	if n == 0 || uint64(len(b)-n) < metaLen {
+		return nil, nil, errors.New("truncated column metadata")
+	}
+	m := b[n : n+int(metaLen)]
+	meta = make([]uint16, count)
+	for i, t := range types {
+		size := 0
+		switch t {
+		case mysqlTypeFloat, mysqlTypeDouble, mysqlTypeBlob, mysqlTypeTinyBlob, mysqlTypeMediumBlob, mysqlTypeLongBlob,
+			mysqlTypeJSON, mysqlTypeGeometry, mysqlTypeTimestamp2, mysqlTypeDatetime2, mysqlTypeTime2:
+			size = 1
+		case mysqlTypeVarchar, mysqlTypeVarString, mysqlTypeBit, mysqlTypeNewDecimal, mysqlTypeString, mysqlTypeEnum, mysqlTypeSet:
+			size = 2
+		case mysqlTypeDecimal, mysqlTypeTiny, mysqlTypeShort, mysqlTypeLong, mysqlTypeNull, mysqlTypeTimestamp,
+			mysqlTypeLongLong, mysqlTypeInt24, mysqlTypeDate, mysqlTypeTime, mysqlTypeDatetime, mysqlTypeYear:
+		default:
+			return nil, nil, fmt.Errorf("unsupported column type %d", t)
+		}
+		if len(m) < size {
+			return nil, nil, errors.New("truncated column metadata")
+		}
+		switch {
+		case size == 1:
+			meta[i] = uint16(m[0])
+		case t == mysqlTypeNewDecimal || t == mysqlTypeString || t == mysqlTypeEnum || t == mysqlTypeSet:
+			// Precision and scale, or real type and length: big-endian.
+			meta[i] = uint16(m[0])<<8 | uint16(m[1])
+		case size == 2:
+			meta[i] = binary.LittleEndian.Uint16(m)
+		}
+		m = m[size:]
+	}
+	return types, meta, nil
+}
+
+// bitmapBit reports whether bit i of a column bitmap is set.
+func bitmapBit(bitmap []byte, i int) bool {
+	return bitmap[i/8]&(1<<(i%8)) != 0
+}
+
+// decodeRows decodes the row images of e against its table map. unsigned
+// says which integer columns are UNSIGNED, which the table map does not.
+func (e *RowsEvent) decodeRows(tm *TableMapEvent, unsigned []bool) ([]rowChange, error) {
+	if tm.columnTypes == nil {
+		return nil, fmt.Errorf("Table_map of %s.%s has no column types", tm.Schema, tm.Table)
+	}
+	if e.columnCount != len(tm.columnTypes) {
+		return nil, fmt.Errorf("rows event has %d columns, %s.%s has %d", e.columnCount, tm.Schema, tm.Table, len(tm.columnTypes))
+	}
+	var changes []rowChange
+	data := e.rowData
+	for len(data) > 0 {
+		var change rowChange
+		var err error
+		switch e.Type {
+		case WriteRowsEventType, WriteRowsEventV1Type:
+			change.after, data, err = decodeRowImage(tm, unsigned, e.present, data)
+		case DeleteRowsEventType, DeleteRowsEventV1Type:
+			change.before, data, err = decodeRowImage(tm, unsigned, e.present, data)
+		case UpdateRowsEventType, UpdateRowsEventV1Type:
+			change.before, data, err = decodeRowImage(tm, unsigned, e.present, data)
+			if err == nil {
+				change.after, data, err = decodeRowImage(tm, unsigned, e.presentAfter, data)
+			}
+		default:
+			return nil, fmt.Errorf("%s events cannot be decoded", e.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", len(changes)+1, err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// decodeRowImage decodes one row image and returns the bytes after it.
+func decodeRowImage(tm *TableMapEvent, unsigned []bool, present []byte, data []byte) (*rowImage, []byte, error) {
+	image := &rowImage{present: make([]bool, len(tm.columnTypes)), values: make([]any, len(tm.columnTypes))}
+	presentCount := 0
+	for i := range tm.columnTypes {
+		if bitmapBit(present, i) {
+			image.present[i] = true
+			presentCount++
+		}
+	}
+	nullBitmapLen := (presentCount + 7) / 8
+	if len(data) < nullBitmapLen {
+		return nil, nil, errors.New("truncated NULL bitmap")
+	}
+	nulls, data := data[:nullBitmapLen], data[nullBitmapLen:]
+	n := 0
+	for i, t := range tm.columnTypes {
+		if !image.present[i] {
+			continue
+		}
+		isNull := bitmapBit(nulls, n)
+		n++
+		if isNull {
+			continue
+		}
+		value, size, err := decodeColumnValue(t, tm.columnMeta[i], i < len(unsigned) && unsigned[i], data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("column %d: %w", i+1, err)
+		}
+		image.values[i] = value
+		data = data[size:]
+	}
+	return image, data, nil
+}
+
+// decodeColumnValue decodes one non-NULL value and returns it with its size.
+func decodeColumnValue(t byte, meta uint16, unsigned bool, b []byte) (value any, size int, err error) {
+	need := func(n int) error {
+		if len(b) < n {
+			return errors.New("truncated value")
+		}
+		return nil
+	}
+	switch t {
+	case mysqlTypeTiny, mysqlTypeShort, mysqlTypeInt24, mysqlTypeLong, mysqlTypeLongLong:
+		size = map[byte]int{mysqlTypeTiny: 1, mysqlTypeShort: 2, mysqlTypeInt24: 3, mysqlTypeLong: 4, mysqlTypeLongLong: 8}[t]
+		if err := need(size); err != nil {
+			return nil, 0, err
+		}
+		var v uint64
+		for i := size - 1; i >= 0; i-- {
+			v = v<<8 | uint64(b[i])
+		}
+		if unsigned {
+			return v, size, nil
+		}
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, size, nil
+	case mysqlTypeFloat:
+		if err := need(4); err != nil {
+			return nil, 0, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), 4, nil
+	case mysqlTypeDouble:
+		if err := need(8); err != nil {
+			return nil, 0, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), 8, nil
+	case mysqlTypeYear:
+		if err := need(1); err != nil {
+			return nil, 0, err
+		}
+		// Quoted, because the number 0 would be stored as 2000.
+		if b[0] == 0 {
+			return sqlLiteral("'0000'"), 1, nil
+		}
+		return sqlLiteral(fmt.Sprintf("'%d'", 1900+int(b[0]))), 1, nil
+	case mysqlTypeDate:
+		if err := need(3); err != nil {
+			return nil, 0, err
+		}
+		v := uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+		return sqlLiteral(fmt.Sprintf("'%04d-%02d-%02d'", v>>9, v>>5&15, v&31)), 3, nil
+	case mysqlTypeDatetime2:
+		size = 5 + int(meta+1)/2
+		if err := need(size); err != nil {
+			return nil, 0, err
+		}
+		v := bigEndian(b[:5]) - 0x8000000000
+		ymd, hms := v>>17, v&(1<<17-1)
+		ym := ymd >> 5
+		text := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", ym/13, ym%13, ymd&31, hms>>12, hms>>6&63, hms&63)
+		return sqlLiteral("'" + text + fractionalSeconds(b[5:size], int(meta)) + "'"), size, nil
+	case mysqlTypeTimestamp2:
+		size = 4 + int(meta+1)/2
+		if err := need(size); err != nil {
+			return nil, 0, err
+		}
+		seconds := int64(binary.BigEndian.Uint32(b))
+		if seconds == 0 {
+			return sqlLiteral("'0000-00-00 00:00:00'"), size, nil
+		}
+		// Rendered in UTC; the replay session sets time_zone to +00:00.
+		text := time.Unix(seconds, 0).UTC().Format("2006-01-02 15:04:05")
+		return sqlLiteral("'" + text + fractionalSeconds(b[4:size], int(meta)) + "'"), size, nil
+	case mysqlTypeTime2:
+		size = 3 + int(meta+1)/2
+		if err := need(size); err != nil {
+			return nil, 0, err
+		}
+		return sqlLiteral("'" + decodeTime2(b[:size], int(meta)) + "'"), size, nil
+	case mysqlTypeNewDecimal:
+		literal, size, err := decodeDecimal(b, int(meta>>8), int(meta&0xff))
+		if err != nil {
+			return nil, 0, err
+		}
+		return sqlLiteral(literal), size, nil
+	case mysqlTypeBit:
+		size = int(meta>>8) + boolToInt(meta&0xff != 0)
+		if err := need(size); err != nil {
+			return nil, 0, err
+		}
+		return bigEndian(b[:size]), size, nil
+	case mysqlTypeVarchar, mysqlTypeVarString:
+		return decodeLengthPrefixed(b, boolToInt(meta >= 256)+1)
+	case mysqlTypeString:
+		realType, maxLen := byte(meta>>8), int(meta&0xff)
+		if realType&0x30 != 0x30 {
+			// CHAR columns longer than 255 bytes borrow two bits of the type.
+			maxLen |= int(realType&0x30^0x30) << 4
+			realType |= 0x30
+		}
+		switch realType {
+		case mysqlTypeEnum, mysqlTypeSet:
+			size = maxLen
+			if err := need(size); err != nil {
+				return nil, 0, err
+			}
+			var v uint64
+			for i := size - 1; i >= 0; i-- {
+				v = v<<8 | uint64(b[i])
+			}
+			// An ENUM index or SET bitmask is stored back as the same number.
+			return v, size, nil
+		}
+		return decodeLengthPrefixed(b, boolToInt(maxLen >= 256)+1)
+	case mysqlTypeBlob, mysqlTypeTinyBlob, mysqlTypeMediumBlob, mysqlTypeLongBlob:
+		return decodeLengthPrefixed(b, int(meta))
+	case mysqlTypeJSON, mysqlTypeGeometry:
+		_, size, err := decodeLengthPrefixed(b, int(meta))
+		if err != nil {
+			return nil, 0, err
+		}
+		return unsupportedValue{columnType: t}, size, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported column type %d", t)
+}
+
+// decodeLengthPrefixed reads a string whose length is stored in its first
+// prefixLen bytes (little-endian).
+func decodeLengthPrefixed(b []byte, prefixLen int) (any, int, error) {
+	if prefixLen < 1 || prefixLen > 4 || len(b) < prefixLen {
+		return nil, 0, errors.New("truncated value length")
+	}
+	var length int
+	for i := prefixLen - 1; i >= 0; i-- {
+		length = length<<8 | int(b[i])
+	}
+	if len(b) < prefixLen+length {
+		return nil, 0, errors.New("truncated value")
+	}
+	return b[prefixLen : prefixLen+length], prefixLen + length, nil
+}
+
+// bigEndian reads an unsigned big-endian integer of up to 8 bytes.
+func bigEndian(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// fractionalSeconds renders the fractional part of a temporal value with fsp
+// digits, from its (fsp+1)/2 big-endian bytes.
+func fractionalSeconds(b []byte, fsp int) string {
+	if fsp == 0 {
+		return ""
+	}
+	// 1 byte holds hundredths, 2 bytes ten-thousandths, 3 bytes microseconds.
+	micros := bigEndian(b) * [4]uint64{0, 10000, 100, 1}[len(b)]
+	return "." + fmt.Sprintf("%06d", micros)[:fsp]
+}
+
+// decodeTime2 renders a TIME2 value: a 3-byte big-endian packed
+// hours/minutes/seconds offset by 0x800000, then the fraction. Negative
+// values store the fraction counted down from the next second.
+func decodeTime2(b []byte, fsp int) string {
+	intPart := int64(bigEndian(b[:3])) - 0x800000
+	packed := intPart << 24
+	switch len(b) - 3 {
+	case 1:
+		frac := int64(b[3])
+		if intPart < 0 && frac != 0 {
+			intPart++
+			frac -= 0x100
+		}
+		packed = intPart<<24 + frac*10000
+	case 2:
+		frac := int64(binary.BigEndian.Uint16(b[3:]))
+		if intPart < 0 && frac != 0 {
+			intPart++
+			frac -= 0x10000
+		}
+		packed = intPart<<24 + frac*100
+	case 3:
+		packed = int64(bigEndian(b)) - 0x800000000000
+	}
+	sign := ""
+	if packed < 0 {
+		sign = "-"
+		packed = -packed
+	}
+	hms, micros := packed>>24, packed&(1<<24-1)
+	text := fmt.Sprintf("%s%02d:%02d:%02d", sign, hms>>12&1023, hms>>6&63, hms&63)
+	if fsp > 0 {
+		text += "." + fmt.Sprintf("%06d", micros)[:fsp]
+	}
+	return text
+}
+
+// decimalDigitBytes is how many bytes hold 0..9 leftover decimal digits.
+var decimalDigitBytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decodeDecimal renders a DECIMAL(precision, scale) value from MySQL's binary
+// format: the integer and fractional parts in big-endian groups of 9 digits
+// per 4 bytes (leftover digits in fewer bytes), with the sign bit flipped and
+// every byte inverted for negative numbers.
+func decodeDecimal(b []byte, precision, scale int) (string, int, error) {
+	intDigits := precision - scale
+	size := intDigits/9*4 + decimalDigitBytes[intDigits%9] + scale/9*4 + decimalDigitBytes[scale%9]
+	if precision == 0 || scale > precision || len(b) < size {
+		return "", 0, errors.New("truncated or invalid DECIMAL")
+	}
+	buf := make([]byte, size)
+	copy(buf, b)
+	negative := buf[0]&0x80 == 0
+	buf[0] ^= 0x80
+	if negative {
+		for i := range buf {
+			buf[i] ^= 0xff
+		}
+	}
+	take := func(n int) uint64 {
+		v := bigEndian(buf[:n])
+		buf = buf[n:]
+		return v
+	}
+
+	var intPart strings.Builder
+	if lead := intDigits % 9; lead > 0 {
+		intPart.WriteString(strconv.FormatUint(take(decimalDigitBytes[lead]), 10))
+	}
+	for range intDigits / 9 {
+		fmt.Fprintf(&intPart, "%09d", take(4))
+	}
+	text := strings.TrimLeft(intPart.String(), "0")
+	if text == "" {
+		text = "0"
+	}
+	if negative {
+		text = "-" + text
+	}
+	if scale > 0 {
+		var fracPart strings.Builder
+		for range scale / 9 {
+			fmt.Fprintf(&fracPart, "%09d", take(4))
+		}
+		if trail := scale % 9; trail > 0 {
+			fmt.Fprintf(&fracPart, "%0*d", trail, take(decimalDigitBytes[trail]))
+		}
+		text += "." + fracPart.String()
+	}
+	return text, size, nil
+}
@@ -0,0 +1,102 @@
+package gtids
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeColumnValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		columnType byte
+		meta       uint16
+		unsigned   bool
+		hex        string
+		expected   any
+	}{
+		{name: "TINYINT", columnType: mysqlTypeTiny, hex: "ff", expected: int64(-1)},
+		{name: "TINYINT UNSIGNED", columnType: mysqlTypeTiny, unsigned: true, hex: "ff", expected: uint64(255)},
+		{name: "MEDIUMINT", columnType: mysqlTypeInt24, hex: "feffff", expected: int64(-2)},
+		{name: "BIGINT UNSIGNED", columnType: mysqlTypeLongLong, unsigned: true, hex: "ffffffffffffffff", expected: uint64(1<<64 - 1)},
+		{name: "DOUBLE", columnType: mysqlTypeDouble, meta: 8, hex: "000000000000f83f", expected: 1.5},
+		// Examples from MySQL's decimal2bin documentation.
+		{name: "DECIMAL(14,4)", columnType: mysqlTypeNewDecimal, meta: 14<<8 | 4, hex: "810dfb38d204d2", expected: sqlLiteral("1234567890.1234")},
+		{name: "negative DECIMAL(14,4)", columnType: mysqlTypeNewDecimal, meta: 14<<8 | 4, hex: "7ef204c72dfb2d", expected: sqlLiteral("-1234567890.1234")},
+		{name: "DECIMAL(5,2) below one", columnType: mysqlTypeNewDecimal, meta: 5<<8 | 2, hex: "800007", expected: sqlLiteral("0.07")},
+		{name: "DATE", columnType: mysqlTypeDate, hex: "5dd00f", expected: sqlLiteral("'2024-02-29'")},
+		{name: "DATETIME(2)", columnType: mysqlTypeDatetime2, meta: 2, hex: "99b25ea7ad 19", expected: sqlLiteral("'2024-01-15 10:30:45.25'")},
+		{name: "TIMESTAMP(3)", columnType: mysqlTypeTimestamp2, meta: 3, hex: "6553f100 04ce", expected: sqlLiteral("'2023-11-14 22:13:20.123'")},
+		{name: "zero TIMESTAMP", columnType: mysqlTypeTimestamp2, hex: "00000000", expected: sqlLiteral("'0000-00-00 00:00:00'")},
+		{name: "TIME", columnType: mysqlTypeTime2, hex: "b46efb", expected: sqlLiteral("'838:59:59'")},
+		{name: "negative TIME(1)", columnType: mysqlTypeTime2, meta: 1, hex: "7f3747 ce", expected: sqlLiteral("'-12:34:56.5'")},
+		{name: "YEAR", columnType: mysqlTypeYear, hex: "7c", expected: sqlLiteral("'2024'")},
+		{name: "VARCHAR(10)", columnType: mysqlTypeVarchar, meta: 40, hex: "03 616263", expected: []byte("abc")},
+		{name: "VARCHAR(300)", columnType: mysqlTypeVarchar, meta: 1200, hex: "0300 616263", expected: []byte("abc")},
+		{name: "CHAR(10)", columnType: mysqlTypeString, meta: mysqlTypeString<<8 | 40, hex: "02 6869", expected: []byte("hi")},
+		// CHAR(255) in utf8mb4 is 1020 bytes: the length's high bits live in the type byte.
+		{name: "CHAR(255) utf8mb4", columnType: mysqlTypeString, meta: 0xce<<8 | 0xfc, hex: "0200 6869", expected: []byte("hi")},
+		{name: "ENUM", columnType: mysqlTypeString, meta: mysqlTypeEnum<<8 | 1, hex: "02", expected: uint64(2)},
+		{name: "SET", columnType: mysqlTypeString, meta: mysqlTypeSet<<8 | 1, hex: "05", expected: uint64(5)},
+		{name: "BLOB", columnType: mysqlTypeBlob, meta: 2, hex: "0200 0001", expected: []byte{0, 1}},
+		{name: "BIT(10)", columnType: mysqlTypeBit, meta: 1<<8 | 2, hex: "0201", expected: uint64(513)},
+		{name: "JSON", columnType: mysqlTypeJSON, meta: 4, hex: "01000000 00", expected: unsupportedValue{columnType: mysqlTypeJSON}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := fixtureBytes(t, tt.hex)
+			value, size, err := decodeColumnValue(tt.columnType, tt.meta, tt.unsigned, append(data, 0xaa))
+			if err != nil {
+				t.Fatalf("decodeColumnValue failed: %v", err)
+			}
+			if size != len(data) {
+				t.Errorf("expected size %d, got %d", len(data), size)
+			}
+			if !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("expected %#v, got %#v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestDecodeColumnValue_Truncated(t *testing.T) {
+	if _, _, err := decodeColumnValue(mysqlTypeLong, 0, false, []byte{1, 2}); err == nil {
+		t.Error("expected a truncated INT to fail")
+	}
+	if _, _, err := decodeColumnValue(mysqlTypeVarchar, 40, false, []byte{5, 'a'}); err == nil {
+		t.Error("expected a truncated VARCHAR to fail")
+	}
+}
+
+func TestRowsEvent_DecodeRows(t *testing.T) {
+	// Table_map of shop.orders (id INT, status VARCHAR(10), note TEXT) after
+	// the table name, then an Update_rows event changing one row.
+	types, meta, err := decodeTableMapColumns(fixtureBytes(t, "03 030ffc 03 2800 02 06"))
+	if err != nil {
+		t.Fatalf("decodeTableMapColumns failed: %v", err)
+	}
+	tm := &TableMapEvent{TableID: 90, Schema: "shop", Table: "orders", columnTypes: types, columnMeta: meta}
+	rows, err := decodeRowsEvent(UpdateRowsEventType, fixtureBytes(t, `
+		5a0000000000 0100 0200
+		03 07 07
+		04 07000000 03 6e6577
+		00 07000000 04 70616964 0200 6f6b`), 10)
+	if err != nil {
+		t.Fatalf("decodeRowsEvent failed: %v", err)
+	}
+	changes, err := rows.decodeRows(tm, nil)
+	if err != nil {
+		t.Fatalf("decodeRows failed: %v", err)
+	}
+	expected := []rowChange{{
+		before: &rowImage{present: []bool{true, true, true}, values: []any{int64(7), []byte("new"), nil}},
+		after:  &rowImage{present: []bool{true, true, true}, values: []any{int64(7), []byte("paid"), []byte("ok")}},
+	}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, changes)
+	}
+
+	tm.columnTypes, tm.columnMeta = tm.columnTypes[:2], tm.columnMeta[:2]
+	if _, err := rows.decodeRows(tm, nil); err == nil {
+		t.Error("expected a column count mismatch to fail")
+	}
+}
@@ -0,0 +1,122 @@
+package gtids
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ChannelSource is one source of a multi-source replica and the replication
+// channel the replica receives its transactions on.
+type ChannelSource struct {
+	Channel string
+	Host    string // labels the source in the report
+	DB      *sql.DB
+}
+
+// CheckMultiSourceGtidSubset compares a multi-source replica db2 with all of its
+// sources. Errant transactions are the target's that none of the sources
+// executed; missing ones are reported per channel, and opts.FixMissingReplica
+// marks each channel's executed with only that channel stopped, so the others
+// keep replicating. Errant transactions are reported only: -fix, -fix-replica,
+// -fix-replay and -flashback would each have to pick one of the sources.
+func CheckMultiSourceGtidSubset(ctx context.Context, sources []ChannelSource, db2 *sql.DB, target string, opts Options) (outcome Outcome, err error) {
+	if opts.Fix || opts.FixReplica || opts.Replay || opts.FlashbackScript != "" {
+		return InSync, fmt.Errorf("errant transactions of a multi-source replica can only be reported; of the fixes, only -fix-missing-replica applies")
+	}
+	w := opts.output()
+
+	type channelState struct {
+		ChannelSource
+		executed, purged *GtidSet
+	}
+	channels := make([]channelState, len(sources))
+	seen := map[string]bool{}
+	union := &GtidSet{intervals: map[string][]Interval{}}
+	for i, source := range sources {
+		if seen[source.Channel] {
+			return InSync, fmt.Errorf("channel '%s' is given twice", source.Channel)
+		}
+		seen[source.Channel] = true
+		label := fmt.Sprintf("source %s (channel '%s')", source.Host, source.Channel)
+		executed, purged, err := readMultiSourceMember(ctx, source.DB, label)
+		if err != nil {
+			return InSync, err
+		}
+		channels[i] = channelState{ChannelSource: source, executed: executed, purged: purged}
+		union = union.Union(executed)
+	}
+	targetExecuted, targetPurged, err := readMultiSourceMember(ctx, db2, "target")
+	if err != nil {
+		return InSync, err
+	}
+
+	for _, c := range channels {
+		fmt.Fprintln(w, blue("[+]"), "Source ->", c.Host, "channel", "'"+c.Channel+"'", "gtid_executed:", c.executed)
+		if !c.purged.IsEmpty() {
+			fmt.Fprintln(w, blue("[+]"), "gtid_purged:", c.purged)
+		}
+	}
+	fmt.Fprintln(w, yellow("[+]"), "Target ->", target, "gtid_executed:", targetExecuted)
+	if !targetPurged.IsEmpty() {
+		fmt.Fprintln(w, yellow("[+]"), "gtid_purged:", targetPurged)
+	}
+	printGtidSetGaps(w, "target", targetExecuted)
+
+	errantSet := targetExecuted.Subtract(union)
+	if errantSet.IsEmpty() {
+		fmt.Fprintln(w, green("[+]"), "No Errant Transactions across", len(channels), "channels")
+	} else {
+		outcome, _ = reportErrantTransactions(ctx, w, db2, target, errantSet, targetPurged, opts)
+	}
+
+	// A transaction that reaches the target on several channels (a shared
+	// upstream) is handled on the first one only.
+	handled := &GtidSet{intervals: map[string][]Interval{}}
+	for _, c := range channels {
+		missingSet := c.executed.Subtract(targetExecuted).Subtract(handled)
+		handled = handled.Union(missingSet)
+		if missingSet.IsEmpty() {
+			fmt.Fprintln(w, green("[+]"), "Channel", "'"+c.Channel+"':", "no missing transactions from", c.Host)
+			continue
+		}
+		fmt.Fprintln(w, yellow("[i]"), "Channel", "'"+c.Channel+"':", missingSet.Summary("missing"), "from", c.Host+":", missingSet)
+		channelOpts := opts
+		channelOpts.Channel = c.Channel
+		unresolved, err := reportMissingTransactions(ctx, w, c.DB, db2, c.Host, target, missingSet, c.purged, channelOpts)
+		if unresolved {
+			outcome = max(outcome, Unresolved)
+		}
+		if err != nil {
+			return outcome, err
+		}
+	}
+	return outcome, nil
+}
+
+// readMultiSourceMember reads the gtid_executed and gtid_purged of one server of
+// a multi-source check; label names it in errors.
+func readMultiSourceMember(ctx context.Context, db *sql.DB, label string) (executed, purged *GtidSet, err error) {
+	version, err := getServerVersion(ctx, db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s version: %w", label, err)
+	}
+	if isMariaDB(version) {
+		return nil, nil, fmt.Errorf("multi-source checks support MySQL GTIDs only (%s is %s)", label, version)
+	}
+	_, gtidExecuted, err := getServerInfo(ctx, db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s server info: %w", label, err)
+	}
+	gtidPurged, err := getGtidPurged(ctx, db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s server info: %w", label, err)
+	}
+	if executed, err = NewGtidSet(gtidExecuted); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s gtid_executed: %w", label, err)
+	}
+	if purged, err = NewGtidSet(gtidPurged); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s gtid_purged: %w", label, err)
+	}
+	return executed, purged, nil
+}
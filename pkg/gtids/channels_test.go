@@ -0,0 +1,125 @@
+package gtids
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectMultiSourceMember mocks the queries readMultiSourceMember runs.
+func expectMultiSourceMember(mock sqlmock.Sqlmock, uuid, executed, purged string) {
+	mock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT @@server_uuid")).WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(uuid))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(executed))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_PURGED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(purged))
+}
+
+func TestCheckMultiSourceGtidSubset(t *testing.T) {
+	const replicaUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	orders, ordersMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer orders.Close()
+	billing, billingMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer billing.Close()
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer replica.Close()
+
+	// The replica has every transaction of orders, lacks billing's last two,
+	// and wrote two of its own, already purged from its binary logs.
+	expectMultiSourceMember(ordersMock, uuidA, uuidA+":1-10", "")
+	expectMultiSourceMember(billingMock, uuidB, uuidB+":1-20", "")
+	expectMultiSourceMember(replicaMock, replicaUUID, uuidA+":1-10,"+uuidB+":1-18,"+replicaUUID+":1-2", replicaUUID+":1-2")
+	replicaMock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+
+	var out bytes.Buffer
+	sources := []ChannelSource{{Channel: "orders", Host: "primary1", DB: orders}, {Channel: "billing", Host: "primary2", DB: billing}}
+	opts := Options{FixMissingReplica: true, DryRun: true, Output: &out}
+	outcome, err := CheckMultiSourceGtidSubset(context.Background(), sources, replica, "replica", opts)
+	if err != nil {
+		t.Fatalf("CheckMultiSourceGtidSubset failed: %v", err)
+	}
+	if outcome != ErrantPurged {
+		t.Errorf("expected outcome %v, got %v", ErrantPurged, outcome)
+	}
+	for _, expected := range []string{
+		"Errant Transactions: " + replicaUUID + ":1-2\n",
+		"Channel 'orders': no missing transactions from primary1",
+		"Channel 'billing': 2 missing transactions across 1 UUID from primary2: " + uuidB + ":19-20",
+		"STOP REPLICA FOR CHANNEL 'billing';",
+		"START REPLICA FOR CHANNEL 'billing';",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
+	for _, mock := range []sqlmock.Sqlmock{ordersMock, billingMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	}
+
+	opts = Options{Fix: true, Output: &out}
+	if _, err := CheckMultiSourceGtidSubset(context.Background(), sources, replica, "replica", opts); err == nil {
+		t.Error("expected -fix to be rejected for a multi-source replica")
+	}
+}
+
+func TestCheckMultiSourceGtidSubset_DefaultChannel(t *testing.T) {
+	const replicaUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	primary, primaryMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer primary.Close()
+	billing, billingMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer billing.Close()
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer replica.Close()
+
+	// The default channel lacks two transactions; the replica also has a
+	// billing channel, which the fix must leave running.
+	expectMultiSourceMember(primaryMock, uuidA, uuidA+":1-10", "")
+	expectMultiSourceMember(billingMock, uuidB, uuidB+":1-5", "")
+	expectMultiSourceMember(replicaMock, replicaUUID, uuidA+":1-8,"+uuidB+":1-5", "")
+	replicaMock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+	replicaMock.ExpectQuery("SHOW REPLICA STATUS").WillReturnRows(sqlmock.NewRows([]string{"Channel_Name"}).AddRow("").AddRow("billing"))
+
+	var out bytes.Buffer
+	sources := []ChannelSource{{Channel: "", Host: "primary1", DB: primary}, {Channel: "billing", Host: "primary2", DB: billing}}
+	opts := Options{FixMissingReplica: true, DryRun: true, Output: &out}
+	outcome, err := CheckMultiSourceGtidSubset(context.Background(), sources, replica, "replica", opts)
+	if err != nil {
+		t.Fatalf("CheckMultiSourceGtidSubset failed: %v", err)
+	}
+	if outcome != Unresolved {
+		t.Errorf("expected outcome %v, got %v", Unresolved, outcome)
+	}
+	for _, expected := range []string{"STOP REPLICA FOR CHANNEL '';", "START REPLICA FOR CHANNEL '';"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, billingMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	}
+}
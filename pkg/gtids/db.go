@@ -94,6 +94,10 @@ func connectWithRetry(ctx context.Context, dsn string, maxRetries int) (*sql.DB,
 	return nil, fmt.Errorf("unexpected error in connection retry logic")
 }
 
+// dsnOptions sets connection/read/write timeouts so a hung server can't hang
+// the tool forever.
+const dsnOptions = "?timeout=10s&readTimeout=1m&writeTimeout=1m"
+
 // ConnectToDatabases connects to the source and target databases with retry logic
 func ConnectToDatabases(ctx context.Context, sourceHost, sourcePort, targetHost, targetPort string) (db1, db2 *sql.DB, err error) {
 	user, password, err := ReadMyCnf()
@@ -101,8 +105,6 @@ func ConnectToDatabases(ctx context.Context, sourceHost, sourcePort, targetHost,
 		return nil, nil, fmt.Errorf("failed to read MySQL credentials: %w", err)
 	}
 
-	// Connection/read/write timeouts so a hung server can't hang the tool forever.
-	const dsnOptions = "?timeout=10s&readTimeout=1m&writeTimeout=1m"
 	sourceDSN := fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql%s", user, password, sourceHost, sourcePort, dsnOptions)
 	targetDSN := fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql%s", user, password, targetHost, targetPort, dsnOptions)
 
@@ -122,6 +124,21 @@ func ConnectToDatabases(ctx context.Context, sourceHost, sourcePort, targetHost,
 	return db1, db2, nil
 }
 
+// ConnectToDatabase connects to a single database with retry logic, for
+// modes that look at one server.
+func ConnectToDatabase(ctx context.Context, host, port string) (*sql.DB, error) {
+	user, password, err := ReadMyCnf()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MySQL credentials: %w", err)
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql%s", user, password, host, port, dsnOptions)
+	db, err := connectWithRetry(ctx, dsn, 3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database %s:%s: %w", host, port, err)
+	}
+	return db, nil
+}
+
 // isRetryableError reports whether an error is transient enough to retry:
 // broken/invalid connections, network errors, lock wait timeouts, deadlocks.
 func isRetryableError(err error) bool {
@@ -0,0 +1,171 @@
+package gtids
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Replica is a replica connected to a source, as the source sees it.
+type Replica struct {
+	Host string
+	// Port is the port the replica reports, or "" when discovered from the
+	// processlist, which only shows the client side of its connection.
+	Port     string
+	ServerID uint32 // 0 when unknown
+	UUID     string // "" when unknown
+}
+
+// replicaHostsForVersion picks SHOW REPLICAS vs SHOW SLAVE HOSTS, like
+// replicationCommandsForVersion picks the other replication statements.
+func replicaHostsForVersion(version string) string {
+	if usesReplicaStatements(version) {
+		return "SHOW REPLICAS"
+	}
+	return "SHOW SLAVE HOSTS"
+}
+
+// DiscoverReplicas lists the replicas of the source db. They are read from
+// SHOW REPLICAS (SHOW SLAVE HOSTS before MySQL 8.0.22), which names a replica
+// only if it sets report_host; when that listing fails or leaves any replica
+// unnamed, the client addresses of the processlist's Binlog Dump threads are
+// used instead. Those threads also serve other binlog readers (mysqlbinlog,
+// change data capture), so the fallback may list hosts that are not replicas.
+func DiscoverReplicas(ctx context.Context, db *sql.DB) (replicas []Replica, fromProcesslist bool, err error) {
+	version, err := getServerVersion(ctx, db)
+	if err != nil {
+		return nil, false, err
+	}
+	replicas, err = listReplicaHosts(ctx, db, replicaHostsForVersion(version))
+	if err == nil && len(replicas) > 0 {
+		complete := true
+		for _, replica := range replicas {
+			complete = complete && replica.Host != ""
+		}
+		if complete {
+			return replicas, false, nil
+		}
+	}
+	replicas, err = listBinlogDumpClients(ctx, db)
+	if err != nil {
+		return nil, false, err
+	}
+	return replicas, true, nil
+}
+
+// listReplicaHosts runs SHOW REPLICAS or SHOW SLAVE HOSTS, whose column names
+// differ across versions and flavors.
+func listReplicaHosts(ctx context.Context, db *sql.DB, query string) ([]Replica, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicas with %s: %w", query, err)
+	}
+	defer rows.Close()
+
+	var replicas []Replica
+	for rows.Next() {
+		columns, err := scanRowAsMap(rows)
+		if err != nil {
+			return nil, err
+		}
+		replica := Replica{Host: columns["Host"], Port: columns["Port"]}
+		if id, err := strconv.ParseUint(firstNonEmpty(columns["Server_Id"], columns["Server_id"]), 10, 32); err == nil {
+			replica.ServerID = uint32(id)
+		}
+		replica.UUID = firstNonEmpty(columns["Replica_UUID"], columns["Slave_UUID"])
+		if replica.Port == "0" {
+			replica.Port = ""
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas, rows.Err()
+}
+
+// listBinlogDumpClients returns the client hosts of the threads sending
+// binary logs, one per host.
+func listBinlogDumpClients(ctx context.Context, db *sql.DB) ([]Replica, error) {
+	rows, err := db.QueryContext(ctx, "SELECT HOST FROM information_schema.PROCESSLIST WHERE COMMAND IN ('Binlog Dump', 'Binlog Dump GTID')")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Binlog Dump threads: %w", err)
+	}
+	defer rows.Close()
+
+	var replicas []Replica
+	seen := map[string]bool{}
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return nil, err
+		}
+		// HOST is "address:client_port" for TCP connections.
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		replicas = append(replicas, Replica{Host: host})
+	}
+	return replicas, rows.Err()
+}
+
+// ReplicationChannel is a source a replica replicates from, as the replica
+// resolves it, and the channel it does so on.
+type ReplicationChannel struct {
+	Channel string // "" for the default channel
+	Host    string
+	Port    string
+}
+
+// ReplicationSources returns the sources the replica db replicates from: the
+// Source_Host and Source_Port (Master_Host and Master_Port before MySQL 8.0.22)
+// of each row of its replication status, one per channel.
+func ReplicationSources(ctx context.Context, db *sql.DB) ([]ReplicationChannel, error) {
+	version, err := getServerVersion(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	_, _, statusCmd := replicationCommandsForVersion(version)
+	rows, err := db.QueryContext(ctx, statusCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query replication status: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []ReplicationChannel
+	for rows.Next() {
+		columns, err := scanRowAsMap(rows)
+		if err != nil {
+			return nil, err
+		}
+		channel := ReplicationChannel{
+			Channel: pickColumn(columns, "Channel_Name", "Connection_name"),
+			Host:    pickColumn(columns, "Master_Host", "Source_Host"),
+			Port:    pickColumn(columns, "Master_Port", "Source_Port"),
+		}
+		if channel.Host == "" {
+			return nil, fmt.Errorf("%s shows no source host for channel '%s'", statusCmd, channel.Channel)
+		}
+		channels = append(channels, channel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("%s is empty: the server is not a replica", statusCmd)
+	}
+	return channels, nil
+}
+
+// firstNonEmpty returns the first of values that is not "".
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
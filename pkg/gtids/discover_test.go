@@ -0,0 +1,183 @@
+package gtids
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicaHostsForVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"5.7.44-log", "SHOW SLAVE HOSTS"},
+		{"8.0.21", "SHOW SLAVE HOSTS"},
+		{"8.0.22", "SHOW REPLICAS"},
+		{"8.4.3", "SHOW REPLICAS"},
+		{"10.11.6-MariaDB-log", "SHOW SLAVE HOSTS"},
+	}
+	for _, tt := range tests {
+		if got := replicaHostsForVersion(tt.version); got != tt.expected {
+			t.Errorf("replicaHostsForVersion(%q) = %q, expected %q", tt.version, got, tt.expected)
+		}
+	}
+}
+
+const binlogDumpQuery = "SELECT HOST FROM information_schema.PROCESSLIST WHERE COMMAND IN ('Binlog Dump', 'Binlog Dump GTID')"
+
+func TestDiscoverReplicas(t *testing.T) {
+	tests := []struct {
+		name            string
+		version         string
+		expect          func(mock sqlmock.Sqlmock)
+		expected        []Replica
+		fromProcesslist bool
+	}{
+		{
+			name:    "SHOW REPLICAS names every replica",
+			version: "8.0.36",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SHOW REPLICAS").WillReturnRows(sqlmock.NewRows([]string{"Server_Id", "Host", "Port", "Source_Id", "Replica_UUID"}).
+					AddRow(2, "replica1", 3306, 1, uuidB).
+					AddRow(3, "replica2", 3307, 1, uuidA))
+			},
+			expected: []Replica{
+				{Host: "replica1", Port: "3306", ServerID: 2, UUID: uuidB},
+				{Host: "replica2", Port: "3307", ServerID: 3, UUID: uuidA},
+			},
+		},
+		{
+			name:    "a replica without report_host",
+			version: "5.7.44-log",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SHOW SLAVE HOSTS").WillReturnRows(sqlmock.NewRows([]string{"Server_id", "Host", "Port", "Master_id", "Slave_UUID"}).
+					AddRow(2, "replica1", 3306, 1, uuidB).
+					AddRow(3, "", 3306, 1, uuidA))
+				mock.ExpectQuery(regexp.QuoteMeta(binlogDumpQuery)).WillReturnRows(sqlmock.NewRows([]string{"HOST"}).
+					AddRow("10.0.0.2:51544").
+					AddRow("10.0.0.3:40112").
+					AddRow("10.0.0.3:40260"))
+			},
+			expected:        []Replica{{Host: "10.0.0.2"}, {Host: "10.0.0.3"}},
+			fromProcesslist: true,
+		},
+		{
+			name:    "listing fails",
+			version: "10.11.6-MariaDB-log",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SHOW SLAVE HOSTS").WillReturnError(errors.New("Error 1227 (42000): Access denied"))
+				mock.ExpectQuery(regexp.QuoteMeta(binlogDumpQuery)).WillReturnRows(sqlmock.NewRows([]string{"HOST"}).AddRow("localhost"))
+			},
+			expected:        []Replica{{Host: "localhost"}},
+			fromProcesslist: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow(tt.version))
+			tt.expect(mock)
+
+			replicas, fromProcesslist, err := DiscoverReplicas(context.Background(), db)
+			if err != nil {
+				t.Fatalf("DiscoverReplicas failed: %v", err)
+			}
+			if fromProcesslist != tt.fromProcesslist {
+				t.Errorf("expected fromProcesslist=%v, got %v", tt.fromProcesslist, fromProcesslist)
+			}
+			if len(replicas) != len(tt.expected) {
+				t.Fatalf("expected %+v, got %+v", tt.expected, replicas)
+			}
+			for i := range replicas {
+				if replicas[i] != tt.expected[i] {
+					t.Errorf("replica %d: expected %+v, got %+v", i, tt.expected[i], replicas[i])
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestReplicationSources(t *testing.T) {
+	tests := []struct {
+		name          string
+		version       string
+		status        string
+		rows          *sqlmock.Rows
+		expected      []ReplicationChannel
+		expectedError string
+	}{
+		{
+			name:     "SHOW REPLICA STATUS",
+			version:  "8.0.36",
+			status:   "SHOW REPLICA STATUS",
+			rows:     sqlmock.NewRows([]string{"Replica_IO_State", "Source_Host", "Source_User", "Source_Port", "Channel_Name"}).AddRow("Waiting for source to send event", "primary.internal", "repl", 3307, ""),
+			expected: []ReplicationChannel{{Host: "primary.internal", Port: "3307"}},
+		},
+		{
+			name:     "SHOW SLAVE STATUS",
+			version:  "5.7.44-log",
+			status:   "SHOW SLAVE STATUS",
+			rows:     sqlmock.NewRows([]string{"Slave_IO_State", "Master_Host", "Master_User", "Master_Port"}).AddRow("Waiting for master to send event", "10.0.0.1", "repl", 3306),
+			expected: []ReplicationChannel{{Host: "10.0.0.1", Port: "3306"}},
+		},
+		{
+			name:     "several channels",
+			version:  "8.0.36",
+			status:   "SHOW REPLICA STATUS",
+			rows:     sqlmock.NewRows([]string{"Source_Host", "Source_Port", "Channel_Name"}).AddRow("primary1", 3306, "orders").AddRow("primary2", 3307, "billing"),
+			expected: []ReplicationChannel{{Channel: "orders", Host: "primary1", Port: "3306"}, {Channel: "billing", Host: "primary2", Port: "3307"}},
+		},
+		{
+			name:          "not a replica",
+			version:       "8.0.36",
+			status:        "SHOW REPLICA STATUS",
+			rows:          sqlmock.NewRows([]string{"Source_Host", "Source_Port"}),
+			expectedError: "not a replica",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow(tt.version))
+			mock.ExpectQuery(tt.status).WillReturnRows(tt.rows)
+
+			channels, err := ReplicationSources(context.Background(), db)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected an error containing %q, got %v", tt.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("ReplicationSources failed: %v", err)
+			}
+			if len(channels) != len(tt.expected) {
+				t.Fatalf("expected %+v, got %+v", tt.expected, channels)
+			}
+			for i := range channels {
+				if channels[i] != tt.expected[i] {
+					t.Errorf("channel %d: expected %+v, got %+v", i, tt.expected[i], channels[i])
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package gtids
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MySQL's binary GTID set format, as written in Previous_gtids_log_event,
+// COM_BINLOG_DUMP_GTID and clone/backup metadata (Gtid_set::encode). All
+// integers are little-endian:
+//
+//	8 bytes   number of SIDs
+//	per SID:  16 bytes UUID
+//	          [tagged format only: tag length as a 1-byte varint, then the tag]
+//	          8 bytes number of intervals
+//	          per interval: 8 bytes start, 8 bytes end (exclusive)
+//
+// MySQL 8.3+ switches to the tagged format only when the set holds tagged
+// GTIDs; it is marked by a format byte of 1 in the first and last byte of the
+// SID count, with the count itself in the six bytes between.
+
+const (
+	gtidFormatTagged  = 1
+	uuidBinaryLength  = 16
+	intervalBinaryLen = 16
+)
+
+var errTruncatedGtidSet = errors.New("truncated binary GTID set")
+
+// parseUUIDBytes converts a textual UUID (with or without dashes) to its 16 bytes.
+func parseUUIDBytes(uuid string) ([uuidBinaryLength]byte, error) {
+	var res [uuidBinaryLength]byte
+	digits := strings.ReplaceAll(uuid, "-", "")
+	if len(digits) != 2*uuidBinaryLength {
+		return res, fmt.Errorf("invalid UUID %q", uuid)
+	}
+	if _, err := hex.Decode(res[:], []byte(digits)); err != nil {
+		return res, fmt.Errorf("invalid UUID %q", uuid)
+	}
+	return res, nil
+}
+
+// formatUUIDBytes renders 16 bytes as a lowercase 8-4-4-4-12 UUID.
+func formatUUIDBytes(b []byte) string {
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// MarshalBinary encodes the set in MySQL's binary GTID set format. The
+// tagged format is used only if the set holds tagged GTIDs, so untagged sets
+// stay readable by servers older than 8.3.
+func (s *GtidSet) MarshalBinary() ([]byte, error) {
+	keys := s.UUIDs()
+	tagged := false
+	for _, key := range keys {
+		if strings.Contains(key, ":") {
+			tagged = true
+		}
+	}
+
+	nsids := uint64(len(keys))
+	if tagged {
+		nsids = nsids<<8 | gtidFormatTagged<<56 | gtidFormatTagged
+	}
+	buf := binary.LittleEndian.AppendUint64(nil, nsids)
+	for _, key := range keys {
+		uuid, tag, _ := strings.Cut(key, ":")
+		uuidBytes, err := parseUUIDBytes(uuid)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, uuidBytes[:]...)
+		if tagged {
+			buf = append(buf, byte(len(tag)<<1))
+			buf = append(buf, tag...)
+		}
+		intervals := s.intervals[key]
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(len(intervals)))
+		for _, interval := range intervals {
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(interval.Start))
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(interval.End+1))
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary replaces the set with one decoded from MySQL's binary GTID
+// set format (tagged or untagged). data must hold exactly one encoded set.
+func (s *GtidSet) UnmarshalBinary(data []byte) error {
+	decoded, n, err := decodeGtidSet(data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("%d trailing bytes after binary GTID set", len(data)-n)
+	}
+	*s = *decoded
+	return nil
+}
+
+// decodeGtidSet decodes a binary GTID set from the start of data and returns
+// it together with the number of bytes consumed.
+func decodeGtidSet(data []byte) (*GtidSet, int, error) {
+	res := &GtidSet{intervals: map[string][]Interval{}}
+	pos := 0
+	readUint64 := func() (uint64, error) {
+		if len(data)-pos < 8 {
+			return 0, errTruncatedGtidSet
+		}
+		v := binary.LittleEndian.Uint64(data[pos:])
+		pos += 8
+		return v, nil
+	}
+
+	nsids, err := readUint64()
+	if err != nil {
+		return nil, 0, err
+	}
+	tagged := nsids&0xff == gtidFormatTagged && nsids>>56 == gtidFormatTagged
+	if tagged {
+		nsids = (nsids >> 8) & 0xffffffffffff
+	}
+	// Every SID takes at least a UUID and an interval count.
+	if nsids > uint64(len(data)-pos)/(uuidBinaryLength+8) {
+		return nil, 0, errTruncatedGtidSet
+	}
+
+	for range nsids {
+		if len(data)-pos < uuidBinaryLength {
+			return nil, 0, errTruncatedGtidSet
+		}
+		key := formatUUIDBytes(data[pos : pos+uuidBinaryLength])
+		pos += uuidBinaryLength
+		if tagged {
+			if pos >= len(data) {
+				return nil, 0, errTruncatedGtidSet
+			}
+			// Tags are at most 32 characters, so the length is always a 1-byte varint.
+			if data[pos]&1 != 0 {
+				return nil, 0, fmt.Errorf("invalid tag length byte 0x%02x in binary GTID set", data[pos])
+			}
+			tagLen := int(data[pos] >> 1)
+			pos++
+			if len(data)-pos < tagLen {
+				return nil, 0, errTruncatedGtidSet
+			}
+			if tagLen > 0 {
+				tag := string(data[pos : pos+tagLen])
+				if !gtidTagPattern.MatchString(tag) {
+					return nil, 0, fmt.Errorf("invalid tag %q in binary GTID set", tag)
+				}
+				key = gtidSetKey(key, tag)
+			}
+			pos += tagLen
+		}
+
+		nintervals, err := readUint64()
+		if err != nil {
+			return nil, 0, err
+		}
+		if nintervals > uint64(len(data)-pos)/intervalBinaryLen {
+			return nil, 0, errTruncatedGtidSet
+		}
+		intervals := make([]Interval, 0, nintervals)
+		for range nintervals {
+			start, _ := readUint64()
+			end, _ := readUint64()
+			if start < 1 || end <= start || end-1 > maxGno {
+				return nil, 0, fmt.Errorf("invalid interval [%d, %d) for %s in binary GTID set", start, end, key)
+			}
+			intervals = append(intervals, Interval{Start: int64(start), End: int64(end - 1)})
+		}
+		res.add(key, normalizeIntervals(intervals))
+	}
+	return res, pos, nil
+}
+
+// MarshalBinary encodes the set in MySQL's binary GTID set format (see GtidSet.MarshalBinary).
+func (ogs *OracleGtidSet) MarshalBinary() ([]byte, error) {
+	gtidSet, err := ogs.GtidSet()
+	if err != nil {
+		return nil, err
+	}
+	return gtidSet.MarshalBinary()
+}
+
+// UnmarshalBinary replaces the entries with a set decoded from MySQL's binary
+// GTID set format, in canonical form.
+func (ogs *OracleGtidSet) UnmarshalBinary(data []byte) error {
+	var gtidSet GtidSet
+	if err := gtidSet.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	ogs.GtidEntries = gtidSet.OracleGtidSet().GtidEntries
+	return nil
+}
@@ -0,0 +1,107 @@
+package gtids
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// writeUndoPlans writes the statements that revert plans, each errant
+// transaction in its own transaction, newest first.
+func writeUndoPlans(w io.Writer, indent string, plans []*replayPlan) {
+	fmt.Fprintf(w, "%sSET SESSION time_zone = '+00:00';\n", indent)
+	for _, plan := range plans {
+		fmt.Fprintf(w, "%s-- undo %s from %s\n", indent, plan.GTID, plan.Location)
+		fmt.Fprintf(w, "%sBEGIN;\n", indent)
+		for _, statement := range plan.Statements {
+			fmt.Fprintf(w, "%s%s;\n", indent, statement)
+		}
+		fmt.Fprintf(w, "%sCOMMIT;\n", indent)
+	}
+	fmt.Fprintf(w, "%sSET SESSION time_zone = DEFAULT;\n", indent)
+}
+
+// writeFlashbackScript writes a reviewable SQL script that reverts plans on
+// target. Binary logging is off for the session, so the reverts neither get
+// GTIDs of their own nor replicate.
+func writeFlashbackScript(path, target string, errantSet *GtidSet, plans []*replayPlan) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create flashback script: %w", err)
+	}
+	fmt.Fprintf(f, "-- Flashback of errant transactions on %s, generated %s.\n", target, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(f, "-- Reverts the row changes of %s: %s\n", errantSet.Summary("errant"), errantSet)
+	fmt.Fprintln(f, "-- Run on the replica with replication stopped. Every statement must affect")
+	fmt.Fprintln(f, "-- exactly one row; otherwise roll back, the data has changed since.")
+	fmt.Fprintln(f, "-- The GTIDs stay in gtid_executed: inject them on the source with -fix.")
+	fmt.Fprintln(f, "SET SESSION sql_log_bin = 0;")
+	writeUndoPlans(f, "", plans)
+	fmt.Fprintln(f, "SET SESSION sql_log_bin = 1;")
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write flashback script: %w", err)
+	}
+	return nil
+}
+
+// applyUndoPlans runs each plan as one transaction on conn, newest first.
+func applyUndoPlans(ctx context.Context, w io.Writer, conn *sql.Conn, plans []*replayPlan) error {
+	return withUTCTimeZone(ctx, conn, func() error {
+		for _, plan := range plans {
+			if err := execPlanStatements(ctx, conn, plan); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Undid %s on replica: %d statement(s)\n", plan.GTID, len(plan.Statements))
+		}
+		return nil
+	})
+}
+
+// flashbackErrantTransactions writes SQL that reverts the errant transactions'
+// row changes, read from the target's binary log files in opts.BinlogDir, to
+// opts.FlashbackScript. With opts.FlashbackApply it also runs the script on
+// the target with binary logging off, between stopping and restarting
+// replication. Every transaction is translated before anything is written.
+// It returns true once the changes are reverted; their GTIDs stay executed.
+func flashbackErrantTransactions(ctx context.Context, w io.Writer, db *sql.DB, target string, errantSet *GtidSet, locations *GtidLocations, opts Options) (undone bool, err error) {
+	plans, err := planErrantTransactions(ctx, db, errantSet, locations, opts.BinlogDir, true)
+	if err != nil {
+		return false, fmt.Errorf("cannot undo errant transactions: %w", err)
+	}
+	if err := writeFlashbackScript(opts.FlashbackScript, target, errantSet, plans); err != nil {
+		return false, err
+	}
+	fmt.Fprintln(w, green("[+]"), "Wrote flashback script for", len(plans), "errant transaction(s) to", opts.FlashbackScript)
+	if !opts.FlashbackApply {
+		return false, nil
+	}
+
+	if opts.DryRun {
+		stopCmd, startCmd, _, err := determineReplicationCommands(ctx, db, opts.Channel)
+		if err != nil {
+			return false, fmt.Errorf("failed to determine replication commands: %w", err)
+		}
+		fmt.Fprintln(w, yellow("[dry-run]"), "Would execute on replica (single pinned session):")
+		fmt.Fprintf(w, "    %s;\n", stopCmd)
+		fmt.Fprintln(w, "    SET SESSION sql_log_bin = 0;")
+		writeUndoPlans(w, "    ", plans)
+		fmt.Fprintln(w, "    SET SESSION sql_log_bin = 1;")
+		fmt.Fprintf(w, "    %s;\n", startCmd)
+		return false, nil
+	}
+	prompt := fmt.Sprintf("About to undo %d errant transaction(s) on the REPLICA %s (replication will be stopped and restarted).", len(plans), target)
+	if !confirmAction(w, prompt, opts.AssumeYes) {
+		fmt.Fprintln(w, yellow("[i]"), "Skipped undoing errant transactions on replica.")
+		return false, nil
+	}
+	err = withReplicationStopped(ctx, w, db, opts.Channel, replicaLocation(opts.Channel), errantSet.String(), true, func(conn *sql.Conn) error {
+		fmt.Fprintln(w, "Undoing errant transactions on replica...")
+		return applyUndoPlans(ctx, w, conn, plans)
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
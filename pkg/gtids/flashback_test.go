@@ -0,0 +1,220 @@
+package gtids
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPlanReplay_Undo(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   func(t *testing.T) []testEvent
+		expected []string
+		error    string
+	}{
+		{
+			name: "update is reverted to the before image",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{ordersTableMap(t), ordersRows(t, UpdateRowsEventType, ordersUpdate)}
+			},
+			expected: []string{"UPDATE `shop`.`orders` SET `id` = 7, `status` = 'new', `note` = NULL WHERE `id` <=> 7 AND `status` <=> 'paid' AND `note` <=> 'ok' LIMIT 1"},
+		},
+		{
+			name: "inserts are deleted, newest first",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{ordersTableMap(t), ordersRows(t, WriteRowsEventType, "03 07  00 07000000 03 6e6577 0000  00 08000000 00 0000")}
+			},
+			expected: []string{
+				"DELETE FROM `shop`.`orders` WHERE `id` <=> 8 AND `status` <=> '' AND `note` <=> '' LIMIT 1",
+				"DELETE FROM `shop`.`orders` WHERE `id` <=> 7 AND `status` <=> 'new' AND `note` <=> '' LIMIT 1",
+			},
+		},
+		{
+			name: "delete is reinserted",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{ordersTableMap(t), ordersRows(t, DeleteRowsEventType, "03 07  04 07000000 03 6e6577")}
+			},
+			expected: []string{"INSERT INTO `shop`.`orders` (`id`, `status`, `note`) VALUES (7, 'new', NULL)"},
+		},
+		{
+			name: "minimal before image",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{ordersTableMap(t), ordersRows(t, DeleteRowsEventType, "03 01  00 07000000")}
+			},
+			error: "before image is incomplete",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("information_schema.COLUMNS").WithArgs("shop", "orders").WillReturnRows(ordersColumns("PRI"))
+
+			events := decodeTestBinlog(t, errantTransactionBinlog(t, tt.events(t)...))
+			tx := &BinlogTransaction{GTID: uuidB + ":1", File: "binlog.000001"}
+			plan, err := planReplay(context.Background(), db, tx, events[2:], map[string]*replayTable{}, true)
+			if tt.error != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.error) {
+					t.Fatalf("expected an error containing %q, got %v", tt.error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planReplay failed: %v", err)
+			}
+			if strings.Join(plan.Statements, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected statements\n%s\ngot\n%s", strings.Join(tt.expected, "\n"), strings.Join(plan.Statements, "\n"))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckGtidSetOutcome_WritesFlashbackScript(t *testing.T) {
+	db1, mock1, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db1.Close()
+	db2, mock2, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db2.Close()
+
+	data := errantTransactionBinlog(t, ordersTableMap(t), ordersRows(t, UpdateRowsEventType, ordersUpdate))
+	dir := expectErrantTransactionCheck(t, mock1, mock2, data)
+	script := filepath.Join(t.TempDir(), "flashback.sql")
+
+	outcome, err := CheckGtidSetOutcome(context.Background(), db1, db2, "source", "target", Options{
+		BinlogDir: dir, FlashbackScript: script,
+	})
+	if err != nil {
+		t.Fatalf("CheckGtidSetOutcome failed: %v", err)
+	}
+	if outcome != Unresolved {
+		t.Errorf("expected outcome %q, got %q", Unresolved, outcome)
+	}
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		"SET SESSION sql_log_bin = 0;",
+		"SET SESSION time_zone = '+00:00';",
+		"-- undo " + uuidB + ":1 from binlog.000001:197-473",
+		"BEGIN;",
+		"UPDATE `shop`.`orders` SET `id` = 7, `status` = 'new', `note` = NULL WHERE `id` <=> 7 AND `status` <=> 'paid' AND `note` <=> 'ok' LIMIT 1;",
+		"COMMIT;",
+		"SET SESSION time_zone = DEFAULT;",
+		"SET SESSION sql_log_bin = 1;",
+	}, "\n")
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("expected the script to contain\n%s\ngot\n%s", expected, content)
+	}
+}
+
+func TestCheckGtidSetOutcome_FlashbackKeepsPurgedErrantOutcome(t *testing.T) {
+	const purged = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-3"
+	undo := "UPDATE `shop`.`orders` SET `id` = 7, `status` = 'new', `note` = NULL WHERE `id` <=> 7 AND `status` <=> 'paid' AND `note` <=> 'ok' LIMIT 1"
+	for _, tt := range []struct {
+		purged   string
+		expected Outcome
+	}{
+		{purged: "", expected: ErrantWithoutDataChanges},
+		{purged: purged, expected: ErrantPurged},
+	} {
+		db1, mock1, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db1.Close()
+		db2, mock2, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db2.Close()
+
+		data := errantTransactionBinlog(t, ordersTableMap(t), ordersRows(t, UpdateRowsEventType, ordersUpdate))
+		dir := expectPurgedErrantTransactionCheck(t, mock1, mock2, data, tt.purged)
+		mock2.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+		expectSingleChannel(mock2)
+		mock2.ExpectExec("STOP REPLICA").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock2.ExpectExec("SET SESSION sql_log_bin = 0").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock2.ExpectExec(regexp.QuoteMeta("SET SESSION time_zone = '+00:00'")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock2.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock2.ExpectExec(regexp.QuoteMeta(undo)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock2.ExpectExec("COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock2.ExpectExec(regexp.QuoteMeta("SET SESSION time_zone = DEFAULT")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock2.ExpectExec("SET SESSION sql_log_bin = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock2.ExpectExec("START REPLICA").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock2.ExpectQuery("SHOW REPLICA STATUS").WillReturnRows(sqlmock.NewRows([]string{"Replica_IO_Running", "Replica_SQL_Running"}).AddRow("Yes", "Yes"))
+
+		var out bytes.Buffer
+		outcome, err := CheckGtidSetOutcome(context.Background(), db1, db2, "source", "target", Options{
+			BinlogDir: dir, FlashbackScript: filepath.Join(t.TempDir(), "flashback.sql"), FlashbackApply: true, AssumeYes: true, Output: &out,
+		})
+		if err != nil {
+			t.Fatalf("purged=%q: CheckGtidSetOutcome failed: %v\n%s", tt.purged, err, out.String())
+		}
+		if outcome != tt.expected {
+			t.Errorf("purged=%q: expected outcome %q, got %q", tt.purged, tt.expected, outcome)
+		}
+		for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("purged=%q: unmet expectations: %v", tt.purged, err)
+			}
+		}
+	}
+}
+
+func TestApplyUndoPlans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	plans := []*replayPlan{
+		{GTID: uuidB + ":2", Statements: []string{"DELETE FROM `shop`.`orders` WHERE `id` <=> 8 LIMIT 1"}},
+		{GTID: uuidB + ":1", Statements: []string{"INSERT INTO `shop`.`orders` (`id`) VALUES (7)"}},
+	}
+	mock.ExpectExec(regexp.QuoteMeta("SET SESSION time_zone = '+00:00'")).WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, plan := range plans {
+		mock.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(plan.Statements[0])).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(regexp.QuoteMeta("SET SESSION time_zone = DEFAULT")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := applyUndoPlans(context.Background(), os.Stdout, conn, plans); err != nil {
+		t.Fatalf("applyUndoPlans failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"log"
+	"io"
+	"iter"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -37,61 +39,130 @@ import (
 var (
 	singleValueInterval = regexp.MustCompile("^([0-9]+)$")
 	multiValueInterval  = regexp.MustCompile("^([0-9]+)[-]([0-9]+)$")
-	// gtidEntryPattern matches a single exploded GTID entry (uuid:transaction-id).
+	// gtidTagPattern matches a MySQL 8.3+ GTID tag, e.g. the "mytag" in uuid:mytag:1-5.
+	gtidTagPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,31}$`)
+	// gtidEntryPattern matches a single exploded GTID entry (uuid[:tag]:transaction-id).
 	// SET GTID_NEXT cannot be parameterized, so entries are validated against this
 	// before being interpolated into the statement.
-	gtidEntryPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}(?:-[0-9a-fA-F]{4}){3}-[0-9a-fA-F]{12}:[0-9]+$`)
-	versionPattern   = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
-	green            = color.New(color.FgGreen).SprintFunc()
-	red              = color.New(color.FgRed).SprintFunc()
-	yellow           = color.New(color.FgYellow).SprintFunc()
-	blue             = color.New(color.FgBlue).SprintFunc()
+	gtidEntryPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}(?:-[0-9a-fA-F]{4}){3}-[0-9a-fA-F]{12}(?::[a-zA-Z_][a-zA-Z0-9_]{0,31})?:[0-9]+$`)
+	// channelNamePattern matches the replication channel names quoted into
+	// FOR CHANNEL, which cannot be parameterized either.
+	channelNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+	versionPattern     = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+	green              = color.New(color.FgGreen).SprintFunc()
+	red                = color.New(color.FgRed).SprintFunc()
+	yellow             = color.New(color.FgYellow).SprintFunc()
+	blue               = color.New(color.FgBlue).SprintFunc()
 )
 
 // OracleGtidSetEntry represents an entry in a set of GTID ranges,
 // for example, the entry: "316d193c-70e5-11e5-adb2-ecf4bb2262ff:1-8935:8984-6124596" (may include gaps)
+// Tag is set for MySQL 8.3+ tagged GTIDs ("uuid:mytag:1-5") and empty otherwise.
 type OracleGtidSetEntry struct {
 	UUID   string
+	Tag    string
 	Ranges string
 }
 
 // NewOracleGtidSetEntry parses a single entry text
 func NewOracleGtidSetEntry(gtidRangeString string) (*OracleGtidSetEntry, error) {
+	entries, err := parseOracleGtidSetEntries(gtidRangeString)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("%s holds more than one tag; parse it with NewOracleGtidSet", strings.TrimSpace(gtidRangeString))
+	}
+	return entries[0], nil
+}
+
+// parseOracleGtidSetEntries parses one comma-separated element of a GTID set.
+// Since MySQL 8.3 an element may list tagged ranges after the untagged ones,
+// e.g. "uuid:1-5:mytag:1-3:other:7", which yields one entry per tag.
+func parseOracleGtidSetEntries(gtidRangeString string) (entries [](*OracleGtidSetEntry), err error) {
 	gtidRangeString = strings.TrimSpace(gtidRangeString)
-	tokens := strings.SplitN(gtidRangeString, ":", 2)
-	if len(tokens) != 2 {
+	tokens := strings.Split(gtidRangeString, ":")
+	if len(tokens) < 2 {
 		return nil, fmt.Errorf("cannot parse OracleGtidSetEntry from %s", gtidRangeString)
 	}
-	if tokens[0] == "" {
-		return nil, fmt.Errorf("unexpected UUID: %s", tokens[0])
+	uuid := strings.TrimSpace(tokens[0])
+	if uuid == "" {
+		return nil, fmt.Errorf("unexpected UUID: %s", uuid)
+	}
+
+	tag := ""
+	var ranges []string
+	flush := func() error {
+		if tag == "" && ranges == nil && len(entries) == 0 {
+			// "uuid:tag:1-5" has no untagged ranges at all.
+			return nil
+		}
+		rangesText := strings.Join(ranges, ":")
+		if rangesText == "" {
+			return fmt.Errorf("unexpected GTID range: %s", rangesText)
+		}
+		entries = append(entries, &OracleGtidSetEntry{UUID: uuid, Tag: tag, Ranges: rangesText})
+		return nil
+	}
+	for _, token := range tokens[1:] {
+		token = strings.TrimSpace(token)
+		if gtidTagPattern.MatchString(token) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			tag, ranges = token, nil
+			continue
+		}
+		ranges = append(ranges, token)
 	}
-	if tokens[1] == "" {
-		return nil, fmt.Errorf("unexpected GTID range: %s", tokens[1])
+	if err := flush(); err != nil {
+		return nil, err
 	}
-	gtidRange := &OracleGtidSetEntry{UUID: tokens[0], Ranges: tokens[1]}
-	return gtidRange, nil
+	return entries, nil
 }
 
 // String returns a user-friendly string representation of this entry
 func (oge *OracleGtidSetEntry) String() string {
+	if oge.Tag != "" {
+		return fmt.Sprintf("%s:%s:%s", oge.UUID, oge.Tag, oge.Ranges)
+	}
 	return fmt.Sprintf("%s:%s", oge.UUID, oge.Ranges)
 }
 
 // Explode returns this entry as a list of single-transaction entries
 func (oge *OracleGtidSetEntry) Explode() (result [](*OracleGtidSetEntry)) {
-	intervals := strings.Split(oge.Ranges, ":")
-	for _, interval := range intervals {
-		if submatch := multiValueInterval.FindStringSubmatch(interval); submatch != nil {
-			intervalStart, _ := strconv.Atoi(submatch[1])
-			intervalEnd, _ := strconv.Atoi(submatch[2])
-			for i := intervalStart; i <= intervalEnd; i++ {
-				result = append(result, &OracleGtidSetEntry{UUID: oge.UUID, Ranges: fmt.Sprintf("%d", i)})
+	return slices.Collect(oge.ExplodeSeq())
+}
+
+// ExplodeSeq yields this entry as single-transaction entries one at a time,
+// so memory stays flat no matter how large the ranges are.
+func (oge *OracleGtidSetEntry) ExplodeSeq() iter.Seq[*OracleGtidSetEntry] {
+	return func(yield func(*OracleGtidSetEntry) bool) {
+		intervals := strings.Split(oge.Ranges, ":")
+		for _, interval := range intervals {
+			if submatch := multiValueInterval.FindStringSubmatch(interval); submatch != nil {
+				intervalStart, err1 := strconv.ParseInt(submatch[1], 10, 64)
+				intervalEnd, err2 := strconv.ParseInt(submatch[2], 10, 64)
+				if err1 != nil || err2 != nil || intervalStart > intervalEnd {
+					continue
+				}
+				// Stop on the last value rather than past it, so an interval ending at
+				// math.MaxInt64 cannot overflow the counter.
+				for i := intervalStart; ; i++ {
+					if !yield(&OracleGtidSetEntry{UUID: oge.UUID, Tag: oge.Tag, Ranges: strconv.FormatInt(i, 10)}) {
+						return
+					}
+					if i == intervalEnd {
+						break
+					}
+				}
+			} else if submatch := singleValueInterval.FindStringSubmatch(interval); submatch != nil {
+				if !yield(&OracleGtidSetEntry{UUID: oge.UUID, Tag: oge.Tag, Ranges: interval}) {
+					return
+				}
 			}
-		} else if submatch := singleValueInterval.FindStringSubmatch(interval); submatch != nil {
-			result = append(result, &OracleGtidSetEntry{UUID: oge.UUID, Ranges: interval})
 		}
 	}
-	return result
 }
 
 // OracleGtidSet represents a set of GTID ranges as depicted by Retrieved_Gtid_Set, Executed_Gtid_Set or @@gtid_purged.
@@ -115,8 +186,8 @@ func NewOracleGtidSet(gtidSet string) (res *OracleGtidSet, err error) {
 		if entry == "" {
 			continue
 		}
-		if gtidRange, err := NewOracleGtidSetEntry(entry); err == nil {
-			res.GtidEntries = append(res.GtidEntries, gtidRange)
+		if gtidRanges, err := parseOracleGtidSetEntries(entry); err == nil {
+			res.GtidEntries = append(res.GtidEntries, gtidRanges...)
 		} else {
 			return res, err
 		}
@@ -183,15 +254,31 @@ func (ogs *OracleGtidSet) SharedUUIDs(other *OracleGtidSet) (shared []string) {
 
 // Explode returns the set as a list of single-transaction entries
 func (ogs *OracleGtidSet) Explode() (result [](*OracleGtidSetEntry)) {
-	for _, entries := range ogs.GtidEntries {
-		result = append(result, entries.Explode()...)
+	return slices.Collect(ogs.ExplodeSeq())
+}
+
+// ExplodeSeq yields the set as single-transaction entries one at a time.
+func (ogs *OracleGtidSet) ExplodeSeq() iter.Seq[*OracleGtidSetEntry] {
+	return func(yield func(*OracleGtidSetEntry) bool) {
+		for _, entries := range ogs.GtidEntries {
+			for entry := range entries.ExplodeSeq() {
+				if !yield(entry) {
+					return
+				}
+			}
+		}
 	}
-	return result
 }
 
+// String joins the entries with ",". A tagged entry that follows an entry for
+// the same UUID is folded into it MySQL-style: "uuid:1-5:mytag:1-3".
 func (ogs *OracleGtidSet) String() string {
 	tokens := []string{}
-	for _, entry := range ogs.GtidEntries {
+	for i, entry := range ogs.GtidEntries {
+		if i > 0 && entry.Tag != "" && ogs.GtidEntries[i-1].UUID == entry.UUID {
+			tokens[len(tokens)-1] += ":" + entry.Tag + ":" + entry.Ranges
+			continue
+		}
 		tokens = append(tokens, entry.String())
 	}
 	return strings.Join(tokens, ",")
@@ -220,65 +307,72 @@ func getServerInfo(ctx context.Context, db *sql.DB) (uuid, gtidExecuted string,
 	return uuid, gtidExecuted, nil
 }
 
-// checkErrantTransactions uses gtid_subtract to find transactions that exist on target but not on source
-func checkErrantTransactions(ctx context.Context, targetGtid, sourceGtid string, db *sql.DB) (errant string, err error) {
-	err = retryDatabaseOperation(ctx, func() error {
-		return db.QueryRowContext(ctx, "SELECT gtid_subtract(?, ?) AS errant_transactions", targetGtid, sourceGtid).Scan(&errant)
-	}, 3)
-	if err != nil {
-		return "", fmt.Errorf("failed to check errant transactions: %w", err)
-	}
-	return errant, nil
-}
-
-// checkMissingTransactions uses gtid_subtract to find transactions that exist on source but not on target
-func checkMissingTransactions(ctx context.Context, sourceGtid, targetGtid string, db *sql.DB) (missing string, err error) {
+// getGtidPurged retrieves GTID_PURGED: the transactions executed on the server
+// that its binary logs no longer hold.
+func getGtidPurged(ctx context.Context, db *sql.DB) (gtidPurged string, err error) {
 	err = retryDatabaseOperation(ctx, func() error {
-		return db.QueryRowContext(ctx, "SELECT gtid_subtract(?, ?) AS missing_transactions", sourceGtid, targetGtid).Scan(&missing)
+		return db.QueryRowContext(ctx, "SELECT @@GLOBAL.GTID_PURGED").Scan(&gtidPurged)
 	}, 3)
 	if err != nil {
-		return "", fmt.Errorf("failed to check missing transactions: %w", err)
+		return "", fmt.Errorf("failed to get GTID_PURGED: %w", err)
 	}
-	return missing, nil
+	return gtidPurged, nil
 }
 
-// getBinaryLogInfo retrieves the current binary log file name.
+// getBinaryLogInfo retrieves the server's current binary log position: the
+// file being written and the end of its last event.
 // MySQL 8.4 removed SHOW MASTER STATUS in favor of SHOW BINARY LOG STATUS
 // (available since 8.2), so try the new statement first and fall back.
-func getBinaryLogInfo(ctx context.Context, db *sql.DB) (logName string, err error) {
+func getBinaryLogInfo(ctx context.Context, db *sql.DB) (pos BinlogPosition, err error) {
 	for _, stmt := range []string{"SHOW BINARY LOG STATUS", "SHOW MASTER STATUS"} {
-		logName, err = queryColumnByName(ctx, db, stmt, "File")
+		var columns map[string]string
+		columns, err = queryColumnsByName(ctx, db, stmt, "File", "Position")
 		if err == nil {
-			return logName, nil
+			pos.File = columns["File"]
+			if pos.Pos, err = strconv.ParseUint(columns["Position"], 10, 64); err != nil {
+				return BinlogPosition{}, fmt.Errorf("failed to parse binary log position %q: %w", columns["Position"], err)
+			}
+			return pos, nil
 		}
 	}
-	return "", fmt.Errorf("failed to get binary log info: %w", err)
+	return BinlogPosition{}, fmt.Errorf("failed to get binary log info: %w", err)
 }
 
 // queryColumnByName runs a query and returns the named column from the first row,
 // scanning dynamically so column count/order differences across versions don't matter.
 func queryColumnByName(ctx context.Context, db *sql.DB, query, column string) (string, error) {
-	rows, err := db.QueryContext(ctx, query)
+	columns, err := queryColumnsByName(ctx, db, query, column)
 	if err != nil {
 		return "", err
 	}
+	return columns[column], nil
+}
+
+// queryColumnsByName is queryColumnByName for several columns, all of which
+// must be present.
+func queryColumnsByName(ctx context.Context, db *sql.DB, query string, names ...string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	if !rows.Next() {
 		if err := rows.Err(); err != nil {
-			return "", err
+			return nil, err
 		}
-		return "", fmt.Errorf("%s returned no rows", query)
+		return nil, fmt.Errorf("%s returned no rows", query)
 	}
 	columns, err := scanRowAsMap(rows)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	value, ok := columns[column]
-	if !ok {
-		return "", fmt.Errorf("column %s not found in %s output", column, query)
+	for _, name := range names {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("column %s not found in %s output", name, query)
+		}
 	}
-	return value, nil
+	return columns, nil
 }
 
 // scanRowAsMap scans the current row of rows into a column-name -> string map.
@@ -311,70 +405,237 @@ func scanRowAsMap(rows *sql.Rows) (map[string]string, error) {
 	return columns, nil
 }
 
-// parseErrantTransactions explodes the errant GTID set into individual entries
-func parseErrantTransactions(errant string) ([]string, error) {
+// parseErrantTransactions lazily explodes the errant GTID set into individual
+// entries and reports how many there are, without materializing them. The
+// entries come from the normalized set, so overlapping or repeated ranges are
+// expanded once and in canonical order.
+func parseErrantTransactions(errant string) (entries iter.Seq[string], count int64, err error) {
 	oracleGtidSet, err := NewOracleGtidSet(errant)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	gtidSet, err := oracleGtidSet.GtidSet()
+	if err != nil {
+		return nil, 0, err
 	}
 
-	var entries []string
-	gtidEntries := oracleGtidSet.Explode()
-	for _, entry := range gtidEntries {
-		entries = append(entries, fmt.Sprintf("%s:%s", entry.UUID, entry.Ranges))
+	normalized := gtidSet.OracleGtidSet()
+	entries = func(yield func(string) bool) {
+		for entry := range normalized.ExplodeSeq() {
+			if !yield(entry.String()) {
+				return
+			}
+		}
 	}
-	return entries, nil
+	return entries, gtidSet.Count(), nil
+}
+
+// isMariaDB reports whether a VERSION() string belongs to MariaDB.
+func isMariaDB(version string) bool {
+	return strings.Contains(strings.ToLower(version), "mariadb")
+}
+
+// usesReplicaStatements reports whether the server takes the REPLICA forms of
+// the replication statements. MySQL 8.0.22 introduced them; 8.4 removed the
+// SLAVE ones. MariaDB keeps the SLAVE statements (its GTIDs are handled in
+// mariadb.go).
+func usesReplicaStatements(version string) bool {
+	if isMariaDB(version) {
+		return false
+	}
+	m := versionPattern.FindStringSubmatch(version)
+	if m == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	return major > 8 || (major == 8 && (minor > 0 || patch >= 22))
 }
 
 // replicationCommandsForVersion picks STOP/START SLAVE vs REPLICA statements.
-// MySQL 8.0.22 introduced the REPLICA statements; 8.4 removed the SLAVE ones.
-// MariaDB keeps the SLAVE statements (and has an incompatible GTID scheme anyway).
 func replicationCommandsForVersion(version string) (stopCmd, startCmd, statusCmd string) {
-	if !strings.Contains(strings.ToLower(version), "mariadb") {
-		if m := versionPattern.FindStringSubmatch(version); m != nil {
-			major, _ := strconv.Atoi(m[1])
-			minor, _ := strconv.Atoi(m[2])
-			patch, _ := strconv.Atoi(m[3])
-			if major > 8 || (major == 8 && (minor > 0 || patch >= 22)) {
-				return "STOP REPLICA", "START REPLICA", "SHOW REPLICA STATUS"
-			}
-		}
+	if usesReplicaStatements(version) {
+		return "STOP REPLICA", "START REPLICA", "SHOW REPLICA STATUS"
 	}
 	return "STOP SLAVE", "START SLAVE", "SHOW SLAVE STATUS"
 }
 
-// determineReplicationCommands determines the correct replication commands based on MySQL version
-func determineReplicationCommands(ctx context.Context, db *sql.DB) (stopCmd, startCmd, statusCmd string, err error) {
-	var version string
+// getServerVersion returns the server's VERSION() string.
+func getServerVersion(ctx context.Context, db *sql.DB) (version string, err error) {
 	err = retryDatabaseOperation(ctx, func() error {
 		return db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version)
 	}, 3)
 	if err != nil {
-		return "", "", "", fmt.Errorf("failed to get MySQL version: %w", err)
+		return "", fmt.Errorf("failed to get MySQL version: %w", err)
+	}
+	return version, nil
+}
+
+// determineReplicationCommands determines the correct replication commands based on MySQL version
+// and limits them to one replication channel. channel "" is the default
+// channel: on a MySQL replica with several channels it is named with an
+// empty FOR CHANNEL, as the bare statements would stop and start all of
+// them. MariaDB's bare SLAVE statements act on the default connection only.
+func determineReplicationCommands(ctx context.Context, db *sql.DB, channel string) (stopCmd, startCmd, statusCmd string, err error) {
+	version, err := getServerVersion(ctx, db)
+	if err != nil {
+		return "", "", "", err
+	}
+	stopCmd, startCmd, statusCmd = replicationCommandsForVersion(version)
+	if channel == "" {
+		if isMariaDB(version) {
+			return stopCmd, startCmd, statusCmd, nil
+		}
+		channels, err := countReplicationChannels(ctx, db, statusCmd)
+		if err != nil {
+			return "", "", "", err
+		}
+		if channels <= 1 {
+			return stopCmd, startCmd, statusCmd, nil
+		}
+	}
+	return replicationCommandsForChannel(version, channel)
+}
+
+// countReplicationChannels returns the number of rows statusCmd lists, one
+// per replication channel.
+func countReplicationChannels(ctx context.Context, db *sql.DB, statusCmd string) (int, error) {
+	rows, err := db.QueryContext(ctx, statusCmd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query replication status: %w", err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err()
+}
+
+// replicationCommandsForChannel limits the replication statements to one
+// channel ("" for the default one): MySQL appends FOR CHANNEL, MariaDB names
+// the connection after SLAVE.
+func replicationCommandsForChannel(version, channel string) (stopCmd, startCmd, statusCmd string, err error) {
+	if channel != "" && !channelNamePattern.MatchString(channel) {
+		return "", "", "", fmt.Errorf("refusing to use invalid replication channel name %q", channel)
+	}
+	if isMariaDB(version) {
+		return "STOP SLAVE '" + channel + "'", "START SLAVE '" + channel + "'", "SHOW SLAVE '" + channel + "' STATUS", nil
 	}
 	stopCmd, startCmd, statusCmd = replicationCommandsForVersion(version)
-	return stopCmd, startCmd, statusCmd, nil
+	forChannel := " FOR CHANNEL '" + channel + "'"
+	return stopCmd + forChannel, startCmd + forChannel, statusCmd + forChannel, nil
 }
 
-// Options controls the fix behavior of CheckGtidSetSubset.
+// Options controls the fix behavior of CheckGtidSetOutcome.
 type Options struct {
 	Fix               bool // apply errant GTIDs to the source
 	FixReplica        bool // apply errant GTIDs to the replica
 	FixMissingReplica bool // mark missing GTIDs as executed on the replica (skips their data)
 	DryRun            bool // print the statements a fix would run without executing them
 	AssumeYes         bool // skip the confirmation prompt
+	Inspect           bool // print the statements and row changes of each errant transaction
+	// Size prints the binary log footprint of the errant and missing
+	// transactions, per table.
+	Size bool
+	// BinlogDir holds the target's binary log files, for the errant
+	// transactions' commit timestamps and row counts and the row changes
+	// Replay and FlashbackScript need.
+	BinlogDir string
+	// SourceBinlogDir holds the source's binary log files, for the missing
+	// transactions' row counts.
+	SourceBinlogDir string
+	// Replay applies the errant transactions' row changes to the source under
+	// their GTIDs.
+	Replay bool
+	// FlashbackScript receives SQL that reverts the errant transactions' row
+	// changes on the target; FlashbackApply also runs it.
+	FlashbackScript string
+	FlashbackApply  bool
+	// Channel is the replication channel (the connection name on MariaDB)
+	// the target receives the source's transactions on; fixes on the target
+	// stop and start only that channel. "" is the default channel.
+	Channel string
+	// Output receives the report and fix progress; nil means standard output.
+	Output io.Writer
+}
+
+// output returns where the report goes.
+func (o Options) output() io.Writer {
+	if o.Output == nil {
+		return os.Stdout
+	}
+	return o.Output
+}
+
+// Outcome is the result of a check. Outcomes are ordered by severity, so the
+// worst of several is simply the largest.
+type Outcome int
+
+const (
+	// InSync means no errant transactions remain (or a fix resolved them).
+	InSync Outcome = iota
+	// ErrantWithoutDataChanges means errant transactions remain, but each was
+	// located and is empty or administrative (FLUSH, ANALYZE, account
+	// management), so injecting them with -fix loses no data.
+	ErrantWithoutDataChanges
+	// Unresolved means errant transactions that changed data (or could not be
+	// classified) remain, or missing transactions a fix left behind.
+	Unresolved
+	// ErrantPurged means some errant transactions are already purged from the
+	// target's binary logs: if the target is promoted, replicas that never saw
+	// them cannot fetch them and stop with error 1236.
+	ErrantPurged
+)
+
+// String describes the outcome for reports.
+func (o Outcome) String() string {
+	switch o {
+	case InSync:
+		return "in sync"
+	case ErrantWithoutDataChanges:
+		return "errant transactions without data changes"
+	case ErrantPurged:
+		return "errant transactions purged from the target's binary logs"
+	default:
+		return "unresolved"
+	}
+}
+
+// ExitCode maps the outcome to the CLI's exit code.
+func (o Outcome) ExitCode() int {
+	switch o {
+	case InSync:
+		return 0
+	case ErrantWithoutDataChanges:
+		return 3
+	case ErrantPurged:
+		return 4
+	default:
+		return 2
+	}
+}
+
+// outcomeOf converts the unresolved flag of checks that cannot classify
+// errant transactions (MariaDB, offline) to an Outcome.
+func outcomeOf(unresolved bool) Outcome {
+	if unresolved {
+		return Unresolved
+	}
+	return InSync
 }
 
 // confirmAction prompts on stdin before a destructive operation. Non-interactive
 // runs (closed stdin, cron) hit EOF and abort — pass -yes to skip the prompt.
-func confirmAction(prompt string, assumeYes bool) bool {
+func confirmAction(w io.Writer, prompt string, assumeYes bool) bool {
 	if assumeYes {
 		return true
 	}
-	fmt.Printf("%s %s Type 'yes' to continue (or pass -yes to skip this prompt): ", yellow("[?]"), prompt)
+	fmt.Fprintf(w, "%s %s Type 'yes' to continue (or pass -yes to skip this prompt): ", yellow("[?]"), prompt)
 	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
 	if err != nil {
-		fmt.Println("\nNo confirmation received — aborting.")
+		fmt.Fprintln(w, "\nNo confirmation received — aborting.")
 		return false
 	}
 	answer := strings.ToLower(strings.TrimSpace(line))
@@ -382,31 +643,31 @@ func confirmAction(prompt string, assumeYes bool) bool {
 }
 
 // printGtidStatements prints the empty-transaction sequence a fix would execute.
-func printGtidStatements(entries []string) {
-	for _, entry := range entries {
-		fmt.Printf("    SET GTID_NEXT='%s'; BEGIN; COMMIT;\n", entry)
+func printGtidStatements(w io.Writer, entries iter.Seq[string]) {
+	for entry := range entries {
+		fmt.Fprintf(w, "    SET GTID_NEXT='%s'; BEGIN; COMMIT;\n", entry)
 	}
-	fmt.Println("    SET GTID_NEXT='AUTOMATIC';")
+	fmt.Fprintln(w, "    SET GTID_NEXT='AUTOMATIC';")
 }
 
 // dryRunSourceFix prints what applyGtidsToSource would execute.
-func dryRunSourceFix(entries []string) {
-	fmt.Println(yellow("[dry-run]"), "Would execute on source (single pinned session):")
-	printGtidStatements(entries)
+func dryRunSourceFix(w io.Writer, entries iter.Seq[string]) {
+	fmt.Fprintln(w, yellow("[dry-run]"), "Would execute on source (single pinned session):")
+	printGtidStatements(w, entries)
 }
 
 // dryRunReplicaFix prints what applyGtidsToReplica would execute.
-func dryRunReplicaFix(ctx context.Context, db *sql.DB, entries []string) error {
-	stopCmd, startCmd, _, err := determineReplicationCommands(ctx, db)
+func dryRunReplicaFix(ctx context.Context, w io.Writer, db *sql.DB, channel string, entries iter.Seq[string]) error {
+	stopCmd, startCmd, _, err := determineReplicationCommands(ctx, db, channel)
 	if err != nil {
 		return fmt.Errorf("failed to determine replication commands: %w", err)
 	}
-	fmt.Println(yellow("[dry-run]"), "Would execute on replica (single pinned session):")
-	fmt.Printf("    %s;\n", stopCmd)
-	fmt.Println("    SET SESSION sql_log_bin = 0;")
-	printGtidStatements(entries)
-	fmt.Println("    SET SESSION sql_log_bin = 1;")
-	fmt.Printf("    %s;\n", startCmd)
+	fmt.Fprintln(w, yellow("[dry-run]"), "Would execute on replica (single pinned session):")
+	fmt.Fprintf(w, "    %s;\n", stopCmd)
+	fmt.Fprintln(w, "    SET SESSION sql_log_bin = 0;")
+	printGtidStatements(w, entries)
+	fmt.Fprintln(w, "    SET SESSION sql_log_bin = 1;")
+	fmt.Fprintf(w, "    %s;\n", startCmd)
 	return nil
 }
 
@@ -414,7 +675,7 @@ func dryRunReplicaFix(ctx context.Context, db *sql.DB, entries []string) error {
 // pinned connection. GTID_NEXT is session-scoped, so every statement in the
 // sequence must run on the same connection — never on the *sql.DB pool.
 // GTID_NEXT is always reset to AUTOMATIC before returning, even on failure.
-func applyGtidEntries(ctx context.Context, conn *sql.Conn, entries []string, fixLocation string) (err error) {
+func applyGtidEntries(ctx context.Context, w io.Writer, conn *sql.Conn, entries iter.Seq[string], fixLocation string) (err error) {
 	defer func() {
 		// Cleanup must run even if ctx was cancelled (e.g. Ctrl-C mid-apply).
 		cleanupCtx := context.WithoutCancel(ctx)
@@ -423,7 +684,7 @@ func applyGtidEntries(ctx context.Context, conn *sql.Conn, entries []string, fix
 		}
 	}()
 
-	for _, entry := range entries {
+	for entry := range entries {
 		if !gtidEntryPattern.MatchString(entry) {
 			return fmt.Errorf("refusing to apply invalid GTID entry %q", entry)
 		}
@@ -436,29 +697,44 @@ func applyGtidEntries(ctx context.Context, conn *sql.Conn, entries []string, fix
 		if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
 			return fmt.Errorf("failed to commit transaction for %s: %w", entry, err)
 		}
-		fmt.Printf("Applied entry to %s: %s\n", fixLocation, entry)
+		fmt.Fprintf(w, "Applied entry to %s: %s\n", fixLocation, entry)
 	}
 	return nil
 }
 
 // applyGtidsToSource injects empty transactions on the source (binary logging
 // stays on so the GTIDs replicate downstream, where they are auto-skipped).
-func applyGtidsToSource(ctx context.Context, db *sql.DB, entries []string) error {
+func applyGtidsToSource(ctx context.Context, w io.Writer, db *sql.DB, entries iter.Seq[string]) error {
 	conn, err := db.Conn(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to acquire connection: %w", err)
 	}
 	defer conn.Close()
 
-	fmt.Println("Applying errant GTIDs to source...")
-	return applyGtidEntries(ctx, conn, entries, "source")
+	fmt.Fprintln(w, "Applying errant GTIDs to source...")
+	return applyGtidEntries(ctx, w, conn, entries, "source")
 }
 
-// applyGtidsToReplica stops replication, injects empty transactions with binary
-// logging disabled on the session, then restarts replication and verifies it.
-// Replication is restarted even if applying the entries fails or ctx is cancelled.
-func applyGtidsToReplica(ctx context.Context, db *sql.DB, entries []string, fixLocation string, errantTransactions string) error {
-	stopCmd, startCmd, statusCmd, err := determineReplicationCommands(ctx, db)
+// applyGtidsToReplica stops replication (on channel only, unless it is ""),
+// injects empty transactions with binary logging disabled on the session, then
+// restarts replication and verifies it. Replication is restarted even if
+// applying the entries fails or ctx is cancelled. Empty transactions change no
+// data, so they are still injected if binary logging cannot be disabled.
+func applyGtidsToReplica(ctx context.Context, w io.Writer, db *sql.DB, channel string, entries iter.Seq[string], fixLocation string, errantTransactions string) error {
+	return withReplicationStopped(ctx, w, db, channel, fixLocation, errantTransactions, false, func(conn *sql.Conn) error {
+		fmt.Fprintf(w, "Applying GTIDs to %s...\n", fixLocation)
+		return applyGtidEntries(ctx, w, conn, entries, fixLocation)
+	})
+}
+
+// withReplicationStopped stops replication on channel ("" for all channels)
+// and runs apply on a pinned connection with binary logging disabled, then
+// restarts replication and verifies it. Replication is restarted even if apply
+// fails or ctx is cancelled. If binary logging cannot be disabled, apply is
+// not run when changesData is set: its changes would be binlogged under new
+// GTIDs and replicate on. Otherwise a warning is written and apply runs.
+func withReplicationStopped(ctx context.Context, w io.Writer, db *sql.DB, channel string, fixLocation string, errantTransactions string, changesData bool, apply func(conn *sql.Conn) error) error {
+	stopCmd, startCmd, statusCmd, err := determineReplicationCommands(ctx, db, channel)
 	if err != nil {
 		return fmt.Errorf("failed to determine replication commands: %w", err)
 	}
@@ -466,7 +742,7 @@ func applyGtidsToReplica(ctx context.Context, db *sql.DB, entries []string, fixL
 	// Cleanup must run even if ctx is cancelled mid-fix (e.g. Ctrl-C).
 	cleanupCtx := context.WithoutCancel(ctx)
 
-	fmt.Printf("Stopping replication on %s...\n", fixLocation)
+	fmt.Fprintf(w, "Stopping replication on %s...\n", fixLocation)
 	if _, err := db.ExecContext(ctx, stopCmd); err != nil {
 		return fmt.Errorf("failed to stop replication on %s: %w", fixLocation, err)
 	}
@@ -478,42 +754,46 @@ func applyGtidsToReplica(ctx context.Context, db *sql.DB, entries []string, fixL
 		}
 		defer conn.Close()
 
-		fmt.Printf("Disabling binary logging on %s...\n", fixLocation)
+		fmt.Fprintf(w, "Disabling binary logging on %s...\n", fixLocation)
 		if _, err := conn.ExecContext(ctx, "SET SESSION sql_log_bin = 0"); err != nil {
-			log.Printf("Warning: failed to disable binary logging: %v", err)
+			if changesData {
+				return fmt.Errorf("failed to disable binary logging, so nothing was changed: %w", err)
+			}
+			fmt.Fprintln(w, yellow("[!]"), "Warning: failed to disable binary logging:", err)
 		}
 		defer func() {
 			if _, err := conn.ExecContext(cleanupCtx, "SET SESSION sql_log_bin = 1"); err != nil {
-				log.Printf("Warning: failed to re-enable binary logging: %v", err)
+				fmt.Fprintln(w, yellow("[!]"), "Warning: failed to re-enable binary logging:", err)
 			}
 		}()
 
-		fmt.Printf("Applying GTIDs to %s...\n", fixLocation)
-		return applyGtidEntries(ctx, conn, entries, fixLocation)
+		return apply(conn)
 	}()
 
-	fmt.Printf("Starting replication on %s...\n", fixLocation)
+	fmt.Fprintf(w, "Starting replication on %s...\n", fixLocation)
 	if _, err := db.ExecContext(cleanupCtx, startCmd); err != nil {
 		if applyErr != nil {
-			return fmt.Errorf("applying GTIDs failed (%v) and replication could not be restarted on %s: %w", applyErr, fixLocation, err)
+			return fmt.Errorf("applying the fix failed (%v) and replication could not be restarted on %s: %w", applyErr, fixLocation, err)
 		}
 		return fmt.Errorf("failed to start replication on %s: %w", fixLocation, err)
 	}
 	if applyErr != nil {
-		return fmt.Errorf("failed to apply GTIDs to %s: %w", fixLocation, applyErr)
+		return fmt.Errorf("failed to apply the fix on %s: %w", fixLocation, applyErr)
 	}
 
-	fmt.Println("Waiting for replication to initialize...")
+	fmt.Fprintln(w, "Waiting for replication to initialize...")
 	if err := sleepCtx(ctx, 2*time.Second); err != nil {
 		return err
 	}
 
-	fmt.Printf("Verifying replication status on %s...\n", fixLocation)
-	return verifyReplicationStatus(ctx, db, statusCmd, fixLocation, errantTransactions)
+	fmt.Fprintf(w, "Verifying replication status on %s...\n", fixLocation)
+	return verifyReplicationStatus(ctx, w, db, statusCmd, fixLocation, errantTransactions)
 }
 
-// verifyReplicationStatus checks and reports the replication status after fixes
-func verifyReplicationStatus(ctx context.Context, db *sql.DB, statusCmd string, fixLocation string, errantTransactions string) error {
+// verifyReplicationStatus checks and reports the replication status after
+// fixes, for each channel statusCmd lists (one row per channel on a
+// multi-source replica).
+func verifyReplicationStatus(ctx context.Context, w io.Writer, db *sql.DB, statusCmd string, fixLocation string, errantTransactions string) error {
 	var rows *sql.Rows
 	err := retryDatabaseOperation(ctx, func() error {
 		var err error
@@ -525,162 +805,350 @@ func verifyReplicationStatus(ctx context.Context, db *sql.DB, statusCmd string,
 	}
 	defer rows.Close()
 
-	columns := map[string]string{}
-	if rows.Next() {
-		if columns, err = scanRowAsMap(rows); err != nil {
+	var channels []map[string]string
+	for rows.Next() {
+		columns, err := scanRowAsMap(rows)
+		if err != nil {
 			return err
 		}
+		channels = append(channels, columns)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read replication status: %w", err)
+	}
+	if len(channels) == 0 {
+		// Not a replica (any more): report the empty status as an issue.
+		channels = append(channels, map[string]string{})
 	}
 
-	// Handle both MySQL 5.7/8.0 (Master/Slave) and 8.0.22+ (Source/Replica) column names.
-	pick := func(names ...string) string {
-		for _, name := range names {
-			if v, ok := columns[name]; ok {
-				return v
-			}
+	fmt.Fprintln(w, "\nReplication Status:")
+	var stopped []string
+	for i, columns := range channels {
+		pick := func(names ...string) string { return pickColumn(columns, names...) }
+		channel := pick("Channel_Name", "Connection_name")
+		masterHost := pick("Master_Host", "Source_Host")
+		ioRunning := pick("Slave_IO_Running", "Replica_IO_Running")
+		sqlRunning := pick("Slave_SQL_Running", "Replica_SQL_Running")
+		secondsBehind := pick("Seconds_Behind_Master", "Seconds_Behind_Source")
+		sqlRunningState := pick("Slave_SQL_Running_State", "Replica_SQL_Running_State")
+		retrievedGtidSet := pick("Retrieved_Gtid_Set", "Gtid_IO_Pos")
+		executedGtidSet := pick("Executed_Gtid_Set", "Gtid_Slave_Pos")
+
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if channel != "" {
+			fmt.Fprintf(w, "                Channel_Name: %s\n", channel)
+		}
+		fmt.Fprintf(w, "                 Master_Host: %s\n", masterHost)
+		fmt.Fprintf(w, "            Slave_IO_Running: %s\n", ioRunning)
+		fmt.Fprintf(w, "           Slave_SQL_Running: %s\n", sqlRunning)
+		fmt.Fprintf(w, "       Seconds_Behind_Master: %s\n", secondsBehind)
+		fmt.Fprintf(w, "     Slave_SQL_Running_State: %s\n", sqlRunningState)
+		fmt.Fprintf(w, "          Retrieved_Gtid_Set: %s\n", retrievedGtidSet)
+		fmt.Fprintf(w, "           Executed_Gtid_Set: %s\n", executedGtidSet)
+
+		if ioRunning != "Yes" || sqlRunning != "Yes" {
+			stopped = append(stopped, channel)
 		}
-		return ""
-	}
-	masterHost := pick("Master_Host", "Source_Host")
-	ioRunning := pick("Slave_IO_Running", "Replica_IO_Running")
-	sqlRunning := pick("Slave_SQL_Running", "Replica_SQL_Running")
-	secondsBehind := pick("Seconds_Behind_Master", "Seconds_Behind_Source")
-	sqlRunningState := pick("Slave_SQL_Running_State", "Replica_SQL_Running_State")
-	retrievedGtidSet := pick("Retrieved_Gtid_Set")
-	executedGtidSet := pick("Executed_Gtid_Set")
-
-	fmt.Println("\nReplication Status:")
-	fmt.Printf("                 Master_Host: %s\n", masterHost)
-	fmt.Printf("            Slave_IO_Running: %s\n", ioRunning)
-	fmt.Printf("           Slave_SQL_Running: %s\n", sqlRunning)
-	fmt.Printf("       Seconds_Behind_Master: %s\n", secondsBehind)
-	fmt.Printf("     Slave_SQL_Running_State: %s\n", sqlRunningState)
-	fmt.Printf("          Retrieved_Gtid_Set: %s\n", retrievedGtidSet)
-	fmt.Printf("           Executed_Gtid_Set: %s\n", executedGtidSet)
-
-	if ioRunning == "Yes" && sqlRunning == "Yes" {
-		fmt.Printf("%s Replication is running on %s\n", green("[+]"), fixLocation)
+	}
+
+	switch {
+	case len(stopped) == 0:
+		fmt.Fprintf(w, "%s Replication is running on %s\n", green("[+]"), fixLocation)
 		if errantTransactions != "" {
-			fmt.Printf("%s Note: Applied errant GTID %s to %s, but it will still show as errant until applied to source\n",
+			fmt.Fprintf(w, "%s Note: Applied errant GTID %s to %s, but it will still show as errant until applied to source\n",
 				blue("[i]"), errantTransactions, fixLocation)
 		}
-	} else {
-		fmt.Printf("%s Replication issue on %s\n", red("[-]"), fixLocation)
+	case len(channels) > 1:
+		fmt.Fprintf(w, "%s Replication issue on %s, channel(s): %s\n", red("[-]"), fixLocation, strings.Join(stopped, ", "))
+	default:
+		fmt.Fprintf(w, "%s Replication issue on %s\n", red("[-]"), fixLocation)
 	}
 
 	return nil
 }
 
+// pickColumn returns the first of the named columns present in a replication
+// status row, so both MySQL 5.7/8.0 (Master/Slave) and 8.0.22+ (Source/Replica)
+// column names can be given.
+func pickColumn(columns map[string]string, names ...string) string {
+	for _, name := range names {
+		if v, ok := columns[name]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// printGtidSetGaps reports holes inside a server's gtid_executed, e.g. left by
+// skipped or purged transactions; nothing is printed for a contiguous set.
+func printGtidSetGaps(w io.Writer, label string, set *GtidSet) {
+	if gaps := set.Gaps(); !gaps.IsEmpty() {
+		fmt.Fprintln(w, yellow("[i]"), "Gaps in", label, "gtid_executed:", gaps, "("+gaps.Summary("")+")")
+	}
+}
+
 // CheckGtidSetSubset compares GTID_EXECUTED between source and target, reports
-// errant/missing transactions, and optionally fixes them per opts.
-// unresolved reports whether errant or missing transactions remain after the run
-// (found in check-only mode, shown in dry-run, or left when a fix was declined).
+// errant/missing transactions, and optionally fixes them per opts. unresolved
+// says whether errant or missing transactions remain after the run.
+//
+// Deprecated: use CheckGtidSetOutcome, whose Outcome also says whether the
+// errant transactions changed data or were already purged.
 func CheckGtidSetSubset(ctx context.Context, db1 *sql.DB, db2 *sql.DB, source string, target string, opts Options) (unresolved bool, err error) {
+	outcome, err := CheckGtidSetOutcome(ctx, db1, db2, source, target, opts)
+	return outcome != InSync, err
+}
+
+// CheckGtidSetOutcome compares GTID_EXECUTED between source and target, reports
+// errant/missing transactions, and optionally fixes them per opts.
+// The Outcome says whether errant or missing transactions remain after the run
+// (found in check-only mode, shown in dry-run, or left when a fix was declined)
+// and whether the errant ones changed any data.
+func CheckGtidSetOutcome(ctx context.Context, db1 *sql.DB, db2 *sql.DB, source string, target string, opts Options) (outcome Outcome, err error) {
+	w := opts.output()
+	sourceVersion, err := getServerVersion(ctx, db1)
+	if err != nil {
+		return InSync, fmt.Errorf("failed to get source version: %w", err)
+	}
+	targetVersion, err := getServerVersion(ctx, db2)
+	if err != nil {
+		return InSync, fmt.Errorf("failed to get target version: %w", err)
+	}
+	switch {
+	case isMariaDB(sourceVersion) && isMariaDB(targetVersion):
+		unresolved, err := checkMariadbGtidSubset(ctx, w, db1, db2, source, target, targetVersion, opts)
+		return outcomeOf(unresolved), err
+	case isMariaDB(sourceVersion) != isMariaDB(targetVersion):
+		return InSync, fmt.Errorf("cannot compare MySQL and MariaDB GTIDs (source %s, target %s)", sourceVersion, targetVersion)
+	}
+
 	sourceUUID, sourceGtidSet, err := getServerInfo(ctx, db1)
 	if err != nil {
-		return false, fmt.Errorf("failed to get source server info: %w", err)
+		return InSync, fmt.Errorf("failed to get source server info: %w", err)
 	}
 
 	targetUUID, targetGtidSet, err := getServerInfo(ctx, db2)
 	if err != nil {
-		return false, fmt.Errorf("failed to get target server info: %w", err)
+		return InSync, fmt.Errorf("failed to get target server info: %w", err)
 	}
 
-	fmt.Println(blue("[+]"), "Source ->", source, "gtid_executed:", sourceGtidSet)
-	fmt.Println(blue("[+]"), "server_uuid:", sourceUUID)
-	fmt.Println(yellow("[+]"), "Target ->", target, "gtid_executed:", targetGtidSet)
-	fmt.Println(yellow("[+]"), "server_uuid:", targetUUID)
+	sourceGtidPurged, err := getGtidPurged(ctx, db1)
+	if err != nil {
+		return InSync, fmt.Errorf("failed to get source server info: %w", err)
+	}
+	targetGtidPurged, err := getGtidPurged(ctx, db2)
+	if err != nil {
+		return InSync, fmt.Errorf("failed to get target server info: %w", err)
+	}
 
-	errantTransactions, err := checkErrantTransactions(ctx, targetGtidSet, sourceGtidSet, db2)
+	// Parsed and compared locally rather than with the server's gtid_subtract:
+	// same result, no round trip, and both sets print in canonical form.
+	sourceSet, err := NewGtidSet(sourceGtidSet)
+	if err != nil {
+		return InSync, fmt.Errorf("failed to parse source gtid_executed: %w", err)
+	}
+	targetSet, err := NewGtidSet(targetGtidSet)
+	if err != nil {
+		return InSync, fmt.Errorf("failed to parse target gtid_executed: %w", err)
+	}
+	sourcePurged, err := NewGtidSet(sourceGtidPurged)
+	if err != nil {
+		return InSync, fmt.Errorf("failed to parse source gtid_purged: %w", err)
+	}
+	targetPurged, err := NewGtidSet(targetGtidPurged)
 	if err != nil {
-		return false, fmt.Errorf("failed to check errant transactions: %w", err)
+		return InSync, fmt.Errorf("failed to parse target gtid_purged: %w", err)
 	}
 
+	fmt.Fprintln(w, blue("[+]"), "Source ->", source, "gtid_executed:", sourceSet)
+	fmt.Fprintln(w, blue("[+]"), "server_uuid:", sourceUUID)
+	if !sourcePurged.IsEmpty() {
+		fmt.Fprintln(w, blue("[+]"), "gtid_purged:", sourcePurged)
+	}
+	fmt.Fprintln(w, yellow("[+]"), "Target ->", target, "gtid_executed:", targetSet)
+	fmt.Fprintln(w, yellow("[+]"), "server_uuid:", targetUUID)
+	if !targetPurged.IsEmpty() {
+		fmt.Fprintln(w, yellow("[+]"), "gtid_purged:", targetPurged)
+	}
+	printGtidSetGaps(w, "source", sourceSet)
+	printGtidSetGaps(w, "target", targetSet)
+
+	errantSet := targetSet.Subtract(sourceSet)
+	errantTransactions := errantSet.String()
 	if errantTransactions == "" {
-		fmt.Println(green("[+]"), "No Errant Transactions:", errantTransactions)
+		fmt.Fprintln(w, green("[+]"), "No Errant Transactions:", errantTransactions)
 	} else {
-		unresolved = true
-		fmt.Println(red("[-]"), "Errant Transactions:", errantTransactions)
-
-		logName, err := getBinaryLogInfo(ctx, db2)
-		if err != nil {
-			return unresolved, fmt.Errorf("failed to get binary log info: %w", err)
+		var locations *GtidLocations
+		outcome, locations = reportErrantTransactions(ctx, w, db2, target, errantSet, targetPurged, opts)
+
+		if opts.FlashbackScript != "" {
+			// Purged errant transactions are no longer in the binary logs
+			// to be undone; the rest still can be.
+			if undoable := errantSet.Subtract(targetPurged); undoable.IsEmpty() {
+				fmt.Fprintln(w, yellow("[i]"), "No flashback script written: every errant transaction is already purged from the target's binary logs.")
+			} else {
+				undone, err := flashbackErrantTransactions(ctx, w, db2, target, undoable, locations, opts)
+				if err != nil {
+					return outcome, err
+				}
+				if undone && outcome == Unresolved {
+					// The GTIDs remain errant, but their changes are gone.
+					// Purged ones stay ErrantPurged: promoting the target
+					// would still break its replicas.
+					outcome = ErrantWithoutDataChanges
+				}
+			}
 		}
-		fmt.Println(yellow("[-]"), "Errant Transaction Found in Log Name:", logName)
 
-		if opts.Fix || opts.FixReplica {
-			entries, err := parseErrantTransactions(errantTransactions)
+		if opts.Replay {
+			resolved, err := replayErrantTransactions(ctx, w, db1, db2, source, errantSet, locations, opts)
+			if err != nil {
+				return outcome, err
+			}
+			if resolved {
+				outcome = InSync
+			}
+		} else if opts.Fix || opts.FixReplica {
+			entries, count, err := parseErrantTransactions(errantTransactions)
 			if err != nil {
-				return unresolved, fmt.Errorf("failed to parse errant transactions: %w", err)
+				return outcome, fmt.Errorf("failed to parse errant transactions: %w", err)
 			}
 
-			if len(entries) > 0 {
+			if count > 0 {
 				switch {
 				case opts.FixReplica && opts.DryRun:
-					if err := dryRunReplicaFix(ctx, db2, entries); err != nil {
-						return unresolved, err
+					if err := dryRunReplicaFix(ctx, w, db2, opts.Channel, entries); err != nil {
+						return outcome, err
 					}
 				case opts.FixReplica:
-					prompt := fmt.Sprintf("About to apply %d empty transaction(s) on the REPLICA %s (replication will be stopped and restarted).", len(entries), target)
-					if !confirmAction(prompt, opts.AssumeYes) {
-						fmt.Println(yellow("[i]"), "Skipped applying errant GTIDs to replica.")
+					prompt := fmt.Sprintf("About to apply %d empty transaction(s) on the REPLICA %s (replication will be stopped and restarted).", count, target)
+					if !confirmAction(w, prompt, opts.AssumeYes) {
+						fmt.Fprintln(w, yellow("[i]"), "Skipped applying errant GTIDs to replica.")
 						break
 					}
-					if err := applyGtidsToReplica(ctx, db2, entries, "replica", errantTransactions); err != nil {
-						return unresolved, err
+					if err := applyGtidsToReplica(ctx, w, db2, opts.Channel, entries, replicaLocation(opts.Channel), errantTransactions); err != nil {
+						return outcome, err
 					}
-					unresolved = false
+					outcome = InSync
 				case opts.DryRun:
-					dryRunSourceFix(entries)
+					dryRunSourceFix(w, entries)
 				default:
-					prompt := fmt.Sprintf("About to apply %d empty transaction(s) on the SOURCE %s (they will replicate downstream).", len(entries), source)
-					if !confirmAction(prompt, opts.AssumeYes) {
-						fmt.Println(yellow("[i]"), "Skipped applying errant GTIDs to source.")
+					prompt := fmt.Sprintf("About to apply %d empty transaction(s) on the SOURCE %s (they will replicate downstream).", count, source)
+					if !confirmAction(w, prompt, opts.AssumeYes) {
+						fmt.Fprintln(w, yellow("[i]"), "Skipped applying errant GTIDs to source.")
 						break
 					}
-					if err := applyGtidsToSource(ctx, db1, entries); err != nil {
-						return unresolved, err
+					if err := applyGtidsToSource(ctx, w, db1, entries); err != nil {
+						return outcome, err
 					}
-					unresolved = false
+					outcome = InSync
 				}
 			}
 		}
 	}
 
-	if opts.FixMissingReplica {
-		missingGtids, err := checkMissingTransactions(ctx, sourceGtidSet, targetGtidSet, db1)
-		if err != nil {
-			return unresolved, fmt.Errorf("failed to check missing transactions: %w", err)
-		}
-		if missingGtids != "" {
-			fmt.Println(red("[-]"), "Missing GTIDs:", missingGtids)
-			fmt.Println(red("[!]"), "WARNING: injecting empty transactions for missing GTIDs marks them as")
-			fmt.Println(red("[!]"), "executed WITHOUT applying their data — the source will never resend them.")
-			fmt.Println(red("[!]"), "The skipped transactions' data must be synced separately (e.g. data-diff).")
+	missingSet := sourceSet.Subtract(targetSet)
+	unresolved, err := reportMissingTransactions(ctx, w, db1, db2, source, target, missingSet, sourcePurged, opts)
+	if unresolved {
+		outcome = max(outcome, Unresolved)
+	}
+	return outcome, err
+}
 
-			entries, err := parseErrantTransactions(missingGtids)
-			if err != nil {
-				return unresolved, fmt.Errorf("failed to parse missing GTIDs: %w", err)
-			}
+// reportErrantTransactions prints the errant transactions, locates and
+// classifies those still in the target's binary logs, and rates them. Unless
+// opts needs them all, more than maxDefaultLocatedErrant are not located and
+// stay unresolved.
+func reportErrantTransactions(ctx context.Context, w io.Writer, db2 *sql.DB, target string, errantSet, targetPurged *GtidSet, opts Options) (outcome Outcome, locations *GtidLocations) {
+	fmt.Fprintln(w, red("[-]"), errantSet.Summary("errant"))
+	fmt.Fprintln(w, red("[-]"), "Errant Transactions:", errantSet)
+
+	outcome = Unresolved
+	purgedErrant := errantSet.Intersect(targetPurged)
+	if !purgedErrant.IsEmpty() {
+		outcome = ErrantPurged
+		fmt.Fprintln(w, red("[!]"), purgedErrant.Summary("errant"), "already purged from the target's binary logs:", purgedErrant)
+		fmt.Fprintln(w, red("[!]"), "Promoting", target, "would break every replica that lacks them (error 1236); -fix makes the source own them.")
+	}
+	unpurged := errantSet.Subtract(targetPurged)
+	if unpurged.IsEmpty() {
+		return outcome, nil
+	}
+	if !locateAllErrant(opts) && unpurged.Count() > maxDefaultLocatedErrant {
+		fmt.Fprintf(w, "%s Not locating %s in the binary logs (more than %d); -inspect or -binlog-dir locates and classifies them.\n",
+			yellow("[i]"), unpurged.Summary("errant"), maxDefaultLocatedErrant)
+		return outcome, nil
+	}
+	impact, classified, locations := printErrantLocations(ctx, w, db2, unpurged, opts.Inspect, opts.BinlogDir)
+	if outcome == Unresolved && classified && !impact.ChangesData() {
+		outcome = ErrantWithoutDataChanges
+	}
+	if opts.Size && locations != nil {
+		printSetSize(ctx, w, db2, "errant", unpurged, locations, opts.BinlogDir)
+	}
+	return outcome, locations
+}
 
-			if opts.DryRun {
-				unresolved = true
-				if err := dryRunReplicaFix(ctx, db2, entries); err != nil {
-					return unresolved, err
-				}
-			} else {
-				prompt := fmt.Sprintf("About to mark %d missing transaction(s) as executed on the REPLICA %s WITHOUT applying their data.", len(entries), target)
-				if !confirmAction(prompt, opts.AssumeYes) {
-					fmt.Println(yellow("[i]"), "Skipped applying missing GTIDs to replica.")
-					return true, nil
-				}
-				if err := applyGtidsToReplica(ctx, db2, entries, "replica", ""); err != nil {
-					return unresolved, fmt.Errorf("failed to apply missing GTID fixes: %w", err)
-				}
-			}
-		} else {
-			fmt.Println(green("[+]"), "No Missing GTIDs")
-		}
+// locateAllErrant says whether opts needs every errant transaction located,
+// however many there are.
+func locateAllErrant(opts Options) bool {
+	return opts.Inspect || opts.BinlogDir != "" || opts.Size || opts.Replay || opts.FlashbackScript != ""
+}
+
+// reportMissingTransactions warns about the missing transactions the source db1
+// can no longer deliver, sizes them, and with opts.FixMissingReplica marks them
+// executed on the target db2, stopping only opts.Channel if set. unresolved
+// says whether a dry run or a declined prompt left them missing.
+func reportMissingTransactions(ctx context.Context, w io.Writer, db1, db2 *sql.DB, source, target string, missingSet, sourcePurged *GtidSet, opts Options) (unresolved bool, err error) {
+	// The target catches up by fetching missing transactions from the source's
+	// binary logs; purged ones can only be restored from a backup.
+	if purgedMissing := missingSet.Intersect(sourcePurged); !purgedMissing.IsEmpty() {
+		fmt.Fprintln(w, red("[!]"), purgedMissing.Summary("missing"), "already purged from the source's binary logs:", purgedMissing)
+		fmt.Fprintln(w, red("[!]"), "Replication from", source, "cannot deliver them (error 1236).")
+	}
+	if opts.Size && !missingSet.IsEmpty() {
+		printSetSize(ctx, w, db1, "missing", missingSet, nil, opts.SourceBinlogDir)
+	}
+	if !opts.FixMissingReplica {
+		return false, nil
+	}
+
+	missingGtids := missingSet.String()
+	if missingGtids == "" {
+		fmt.Fprintln(w, green("[+]"), "No Missing GTIDs")
+		return false, nil
+	}
+	fmt.Fprintln(w, red("[-]"), missingSet.Summary("missing"))
+	fmt.Fprintln(w, red("[-]"), "Missing GTIDs:", missingGtids)
+	fmt.Fprintln(w, red("[!]"), "WARNING: injecting empty transactions for missing GTIDs marks them as")
+	fmt.Fprintln(w, red("[!]"), "executed WITHOUT applying their data — the source will never resend them.")
+	fmt.Fprintln(w, red("[!]"), "The skipped transactions' data must be synced separately (e.g. data-diff).")
+
+	entries, count, err := parseErrantTransactions(missingGtids)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse missing GTIDs: %w", err)
+	}
+
+	if opts.DryRun {
+		return true, dryRunReplicaFix(ctx, w, db2, opts.Channel, entries)
+	}
+	prompt := fmt.Sprintf("About to mark %d missing transaction(s) as executed on the REPLICA %s WITHOUT applying their data.", count, target)
+	if !confirmAction(w, prompt, opts.AssumeYes) {
+		fmt.Fprintln(w, yellow("[i]"), "Skipped applying missing GTIDs to replica.")
+		return true, nil
+	}
+	if err := applyGtidsToReplica(ctx, w, db2, opts.Channel, entries, replicaLocation(opts.Channel), ""); err != nil {
+		return false, fmt.Errorf("failed to apply missing GTID fixes: %w", err)
+	}
+	return false, nil
+}
+
+// replicaLocation names the replica, and the channel a fix stops, in progress
+// messages.
+func replicaLocation(channel string) string {
+	if channel == "" {
+		return "replica"
 	}
-	return unresolved, nil
+	return fmt.Sprintf("replica (channel '%s')", channel)
 }
@@ -1,8 +1,16 @@
 package gtids
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -37,7 +45,35 @@ func TestApplyGtidEntries_StatementSequence(t *testing.T) {
 	}
 	defer conn.Close()
 
-	if err := applyGtidEntries(ctx, conn, entries, "test"); err != nil {
+	if err := applyGtidEntries(ctx, os.Stdout, conn, slices.Values(entries), "test"); err != nil {
+		t.Fatalf("applyGtidEntries failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestApplyGtidEntries_TaggedEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	entry := "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:mytag:7"
+	mock.ExpectExec("SET GTID_NEXT='" + entry + "'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET GTID_NEXT='AUTOMATIC'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("failed to acquire connection: %v", err)
+	}
+	defer conn.Close()
+
+	if err := applyGtidEntries(ctx, os.Stdout, conn, slices.Values([]string{entry}), "test"); err != nil {
 		t.Fatalf("applyGtidEntries failed: %v", err)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -67,10 +103,13 @@ func TestApplyGtidEntries_RejectsInvalidEntry(t *testing.T) {
 		"not-a-uuid:1",
 		"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1'; DROP TABLE users; --",
 		"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:",
+		"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:9tag:1",
+		"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:my-tag:1",
+		"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:a_tag_that_is_longer_than_32_chars:1",
 		"",
 	}
 	for _, bad := range injections {
-		if err := applyGtidEntries(ctx, conn, []string{bad}, "test"); err == nil {
+		if err := applyGtidEntries(ctx, os.Stdout, conn, slices.Values([]string{bad}), "test"); err == nil {
 			t.Errorf("expected error for invalid entry %q, got nil", bad)
 		}
 	}
@@ -96,7 +135,7 @@ func TestApplyGtidEntries_ResetsGtidNextOnFailure(t *testing.T) {
 	}
 	defer conn.Close()
 
-	if err := applyGtidEntries(ctx, conn, []string{entry}, "test"); err == nil {
+	if err := applyGtidEntries(ctx, os.Stdout, conn, slices.Values([]string{entry}), "test"); err == nil {
 		t.Fatal("expected error from failed BEGIN, got nil")
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -117,12 +156,12 @@ func TestGetBinaryLogInfo_FallsBackToShowMasterStatus(t *testing.T) {
 		AddRow("binlog.000042", 1234, "", "", "uuid:1-10")
 	mock.ExpectQuery("SHOW MASTER STATUS").WillReturnRows(rows)
 
-	logName, err := getBinaryLogInfo(context.Background(), db)
+	pos, err := getBinaryLogInfo(context.Background(), db)
 	if err != nil {
 		t.Fatalf("getBinaryLogInfo failed: %v", err)
 	}
-	if logName != "binlog.000042" {
-		t.Errorf("expected binlog.000042, got %q", logName)
+	if pos.String() != "binlog.000042:1234" {
+		t.Errorf("expected binlog.000042:1234, got %q", pos)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("unmet expectations: %v", err)
@@ -141,12 +180,12 @@ func TestGetBinaryLogInfo_PrefersShowBinaryLogStatus(t *testing.T) {
 		AddRow("binlog.000007", 999, "", "", "uuid:1-10")
 	mock.ExpectQuery("SHOW BINARY LOG STATUS").WillReturnRows(rows)
 
-	logName, err := getBinaryLogInfo(context.Background(), db)
+	pos, err := getBinaryLogInfo(context.Background(), db)
 	if err != nil {
 		t.Fatalf("getBinaryLogInfo failed: %v", err)
 	}
-	if logName != "binlog.000007" {
-		t.Errorf("expected binlog.000007, got %q", logName)
+	if pos.String() != "binlog.000007:999" {
+		t.Errorf("expected binlog.000007:999, got %q", pos)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("unmet expectations: %v", err)
@@ -154,21 +193,90 @@ func TestGetBinaryLogInfo_PrefersShowBinaryLogStatus(t *testing.T) {
 }
 
 func TestDetermineReplicationCommands_Mock(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("failed to create sqlmock: %v", err)
+	tests := []struct {
+		name                string
+		version             string
+		channel             string
+		channels            int // rows SHOW REPLICA STATUS returns; -1 if it must not run
+		stop, start, status string
+	}{
+		{"single channel", "8.4.2", "", 1, "STOP REPLICA", "START REPLICA", "SHOW REPLICA STATUS"},
+		{"default channel of a multi-source replica", "8.4.2", "", 2, "STOP REPLICA FOR CHANNEL ''", "START REPLICA FOR CHANNEL ''", "SHOW REPLICA STATUS FOR CHANNEL ''"},
+		{"named channel", "8.4.2", "orders", -1, "STOP REPLICA FOR CHANNEL 'orders'", "START REPLICA FOR CHANNEL 'orders'", "SHOW REPLICA STATUS FOR CHANNEL 'orders'"},
+		{"MariaDB default connection", "10.11.6-MariaDB", "", -1, "STOP SLAVE", "START SLAVE", "SHOW SLAVE STATUS"},
 	}
-	defer db.Close()
-
-	mock.ExpectQuery("SELECT VERSION").
-		WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.4.2"))
 
-	stop, start, status, err := determineReplicationCommands(context.Background(), db)
-	if err != nil {
-		t.Fatalf("determineReplicationCommands failed: %v", err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery("SELECT VERSION").
+				WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow(tt.version))
+			if tt.channels >= 0 {
+				rows := sqlmock.NewRows([]string{"Channel_Name"})
+				for i := 0; i < tt.channels; i++ {
+					rows.AddRow(fmt.Sprintf("channel%d", i))
+				}
+				mock.ExpectQuery("SHOW REPLICA STATUS").WillReturnRows(rows)
+			}
+
+			stop, start, status, err := determineReplicationCommands(context.Background(), db, tt.channel)
+			if err != nil {
+				t.Fatalf("determineReplicationCommands failed: %v", err)
+			}
+			if stop != tt.stop || start != tt.start || status != tt.status {
+				t.Errorf("expected %q / %q / %q, got %q / %q / %q", tt.stop, tt.start, tt.status, stop, start, status)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
 	}
-	if stop != "STOP REPLICA" || start != "START REPLICA" || status != "SHOW REPLICA STATUS" {
-		t.Errorf("expected REPLICA commands for 8.4.2, got %q / %q / %q", stop, start, status)
+}
+
+// expectSingleChannel mocks the replication status query that tells
+// determineReplicationCommands the replica has only the default channel.
+func expectSingleChannel(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SHOW REPLICA STATUS").WillReturnRows(sqlmock.NewRows([]string{"Channel_Name"}).AddRow(""))
+}
+
+func TestWithReplicationStopped_BinaryLoggingNotDisabled(t *testing.T) {
+	for _, changesData := range []bool{true, false} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+		expectSingleChannel(mock)
+		mock.ExpectExec("STOP REPLICA").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SET SESSION sql_log_bin = 0").WillReturnError(errors.New("Access denied; you need the SUPER privilege"))
+		if !changesData {
+			mock.ExpectExec("SET SESSION sql_log_bin = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec("START REPLICA").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		var out bytes.Buffer
+		applied := false
+		err = withReplicationStopped(context.Background(), &out, db, "", "replica", "", changesData, func(conn *sql.Conn) error {
+			applied = true
+			return errors.New("stop here")
+		})
+		if changesData {
+			if applied || err == nil || !strings.Contains(err.Error(), "failed to disable binary logging") {
+				t.Errorf("expected nothing applied and an error, got applied=%v, %v", applied, err)
+			}
+		} else if !applied || !strings.Contains(out.String(), "Warning: failed to disable binary logging") {
+			t.Errorf("expected a warning in the output and the apply to run, got applied=%v:\n%s", applied, out.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("changesData=%v: unmet expectations: %v", changesData, err)
+		}
 	}
 }
 
@@ -187,10 +295,37 @@ func TestVerifyReplicationStatus_ReplicaColumnNames(t *testing.T) {
 	}).AddRow("10.0.0.1", "Yes", "Yes", 0, "waiting", "uuid:1-5", "uuid:1-5")
 	mock.ExpectQuery("SHOW REPLICA STATUS").WillReturnRows(rows)
 
-	err = verifyReplicationStatus(context.Background(), db, "SHOW REPLICA STATUS", "replica", "")
+	err = verifyReplicationStatus(context.Background(), os.Stdout, db, "SHOW REPLICA STATUS", "replica", "")
+	if err != nil {
+		t.Fatalf("verifyReplicationStatus failed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestVerifyReplicationStatus_Channels(t *testing.T) {
+	db, mock, err := sqlmock.New()
 	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// One row per channel on a multi-source replica; the second is stopped.
+	rows := sqlmock.NewRows([]string{
+		"Source_Host", "Replica_IO_Running", "Replica_SQL_Running", "Channel_Name",
+	}).AddRow("primary1", "Yes", "Yes", "orders").AddRow("primary2", "Yes", "No", "billing")
+	mock.ExpectQuery("SHOW REPLICA STATUS").WillReturnRows(rows)
+
+	var out bytes.Buffer
+	if err := verifyReplicationStatus(context.Background(), &out, db, "SHOW REPLICA STATUS", "replica", ""); err != nil {
 		t.Fatalf("verifyReplicationStatus failed: %v", err)
 	}
+	for _, expected := range []string{"Channel_Name: orders", "Master_Host: primary2", "Replication issue on replica, channel(s): billing"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("unmet expectations: %v", err)
 	}
@@ -228,3 +363,67 @@ type timeoutError struct{}
 func (*timeoutError) Error() string   { return "i/o timeout" }
 func (*timeoutError) Timeout() bool   { return true }
 func (*timeoutError) Temporary() bool { return true }
+
+func TestCheckGtidSetOutcome_WritesToOutput(t *testing.T) {
+	db1, mock1, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db1.Close()
+	db2, mock2, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db2.Close()
+
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		mock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+	}
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT @@server_uuid")).WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(uuidA))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(uuidA + ":1-10"))
+	}
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_PURGED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(""))
+	}
+
+	var report bytes.Buffer
+	outcome, err := CheckGtidSetOutcome(context.Background(), db1, db2, "source", "replica-3:3307", Options{Output: &report})
+	if err != nil {
+		t.Fatalf("CheckGtidSetOutcome failed: %v", err)
+	}
+	if outcome != InSync {
+		t.Errorf("expected outcome %q, got %q", InSync, outcome)
+	}
+	for _, expected := range []string{"replica-3:3307", "No Errant Transactions"} {
+		if !strings.Contains(report.String(), expected) {
+			t.Errorf("expected the report to contain %q, got:\n%s", expected, report.String())
+		}
+	}
+}
+
+func TestCheckGtidSetSubset(t *testing.T) {
+	db1, mock1, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db1.Close()
+	db2, mock2, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db2.Close()
+
+	// The target's only errant transaction is empty, which CheckGtidSetOutcome
+	// rates ErrantWithoutDataChanges; it still leaves the check unresolved.
+	data := errantTransactionBinlog(t)
+	expectErrantTransactionCheck(t, mock1, mock2, data)
+
+	unresolved, err := CheckGtidSetSubset(context.Background(), db1, db2, "source", "target", Options{Output: io.Discard})
+	if err != nil {
+		t.Fatalf("CheckGtidSetSubset failed: %v", err)
+	}
+	if !unresolved {
+		t.Error("expected the errant transaction to leave the check unresolved")
+	}
+}
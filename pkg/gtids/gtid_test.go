@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -16,6 +17,16 @@ import (
 //   - TEST_MYSQL_HOST (default: 127.0.0.1)
 //   - TEST_MYSQL_PORT (default: 3306)
 func getTestDSN() string {
+	return getTestDSNForPort(os.Getenv("TEST_MYSQL_PORT"), "3306")
+}
+
+// getTaggedTestDSN returns the DSN of the MySQL 8.4 test server (mysql-tagged
+// in docker-compose.yml), on TEST_MYSQL_TAGGED_PORT (default: 3308).
+func getTaggedTestDSN() string {
+	return getTestDSNForPort(os.Getenv("TEST_MYSQL_TAGGED_PORT"), "3308")
+}
+
+func getTestDSNForPort(port, defaultPort string) string {
 	user := os.Getenv("TEST_MYSQL_USER")
 	if user == "" {
 		user = "root"
@@ -28,9 +39,8 @@ func getTestDSN() string {
 	if host == "" {
 		host = "127.0.0.1"
 	}
-	port := os.Getenv("TEST_MYSQL_PORT")
 	if port == "" {
-		port = "3306"
+		port = defaultPort
 	}
 	return fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql", user, password, host, port)
 }
@@ -103,11 +113,39 @@ func TestParseErrantTransactions(t *testing.T) {
 			expected: nil,
 			hasError: true,
 		},
+		{
+			name:  "tagged GTIDs (MySQL 8.3+)",
+			input: "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1:mytag:1-2",
+			expected: []string{
+				"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1",
+				"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:mytag:1",
+				"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:mytag:2",
+			},
+			hasError: false,
+		},
+		{
+			name:  "overlapping ranges - expanded once, in order",
+			input: "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:3-4:1-3,1D1FFF5A-C9BC-11ED-9C19-02A36D996B94:2",
+			expected: []string{
+				"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1",
+				"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:2",
+				"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:3",
+				"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:4",
+			},
+			hasError: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := parseErrantTransactions(tt.input)
+			seq, count, err := parseErrantTransactions(tt.input)
+			var result []string
+			if err == nil {
+				result = slices.Collect(seq)
+				if count != int64(len(result)) {
+					t.Errorf("count %d does not match %d exploded entries", count, len(result))
+				}
+			}
 
 			if tt.hasError && err == nil {
 				t.Errorf("expected error but got none")
@@ -140,6 +178,31 @@ func TestParseErrantTransactions(t *testing.T) {
 	}
 }
 
+// TestParseErrantTransactions_MillionsStreamed runs a set of a few million
+// transactions through the lazy expansion without collecting it.
+func TestParseErrantTransactions_MillionsStreamed(t *testing.T) {
+	const total = 3_000_000
+	seq, count, err := parseErrantTransactions(uuidA + ":1-2000000," + uuidB + ":1-1000000")
+	if err != nil {
+		t.Fatalf("parseErrantTransactions failed: %v", err)
+	}
+	if count != total {
+		t.Errorf("expected count %d, got %d", total, count)
+	}
+	var n int64
+	var last string
+	for entry := range seq {
+		n++
+		last = entry
+	}
+	if n != total {
+		t.Errorf("expected %d entries, got %d", total, n)
+	}
+	if expected := uuidB + ":1000000"; last != expected {
+		t.Errorf("expected last entry %s, got %s", expected, last)
+	}
+}
+
 func TestNewOracleGtidSet(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -190,6 +253,35 @@ func TestNewOracleGtidSet(t *testing.T) {
 			expected: nil,
 			hasError: true,
 		},
+		{
+			name:  "tagged GTIDs only",
+			input: "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:mytag:1-5",
+			expected: &OracleGtidSet{
+				GtidEntries: []*OracleGtidSetEntry{
+					{UUID: "1d1fff5a-c9bc-11ed-9c19-02a36d996b94", Tag: "mytag", Ranges: "1-5"},
+				},
+			},
+			hasError: false,
+		},
+		{
+			name:  "untagged and tagged ranges for one UUID",
+			input: "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-3:7:mytag:1-5:other_tag:9,2af7e535-9255-11f0-87f8-76ae10baffb1:1",
+			expected: &OracleGtidSet{
+				GtidEntries: []*OracleGtidSetEntry{
+					{UUID: "1d1fff5a-c9bc-11ed-9c19-02a36d996b94", Ranges: "1-3:7"},
+					{UUID: "1d1fff5a-c9bc-11ed-9c19-02a36d996b94", Tag: "mytag", Ranges: "1-5"},
+					{UUID: "1d1fff5a-c9bc-11ed-9c19-02a36d996b94", Tag: "other_tag", Ranges: "9"},
+					{UUID: "2af7e535-9255-11f0-87f8-76ae10baffb1", Ranges: "1"},
+				},
+			},
+			hasError: false,
+		},
+		{
+			name:     "tag without ranges",
+			input:    "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-3:mytag",
+			expected: nil,
+			hasError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -207,9 +299,8 @@ func TestNewOracleGtidSet(t *testing.T) {
 					t.Errorf("expected %d entries, got %d", len(tt.expected.GtidEntries), len(result.GtidEntries))
 				} else {
 					for i, expected := range tt.expected.GtidEntries {
-						if result.GtidEntries[i].UUID != expected.UUID || result.GtidEntries[i].Ranges != expected.Ranges {
-							t.Errorf("entry %d: expected {UUID: %s, Ranges: %s}, got {UUID: %s, Ranges: %s}",
-								i, expected.UUID, expected.Ranges, result.GtidEntries[i].UUID, result.GtidEntries[i].Ranges)
+						if *result.GtidEntries[i] != *expected {
+							t.Errorf("entry %d: expected %+v, got %+v", i, *expected, *result.GtidEntries[i])
 						}
 					}
 				}
@@ -269,6 +360,48 @@ func TestOracleGtidSetEntry_Explode(t *testing.T) {
 	}
 }
 
+func TestOracleGtidSet_StringFoldsTags(t *testing.T) {
+	input := "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-3:mytag:1-5,2af7e535-9255-11f0-87f8-76ae10baffb1:1"
+	set, err := NewOracleGtidSet(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := set.String(); got != input {
+		t.Errorf("expected %q, got %q", input, got)
+	}
+	entry := &OracleGtidSetEntry{UUID: "1d1fff5a-c9bc-11ed-9c19-02a36d996b94", Tag: "mytag", Ranges: "4"}
+	if got := entry.String(); got != "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:mytag:4" {
+		t.Errorf("unexpected entry string %q", got)
+	}
+	if _, err := NewOracleGtidSetEntry(input[:strings.Index(input, ",")]); err == nil {
+		t.Error("NewOracleGtidSetEntry should refuse text holding several tags")
+	}
+}
+
+func TestOracleGtidSetEntry_ExplodeSeqIsLazy(t *testing.T) {
+	// Exploding this eagerly would never finish; the sequence must only
+	// produce what the consumer asks for.
+	entry := &OracleGtidSetEntry{UUID: "test-uuid", Ranges: "1-9223372036854775806"}
+	var got []string
+	for e := range entry.ExplodeSeq() {
+		got = append(got, e.String())
+		if len(got) == 3 {
+			break
+		}
+	}
+	expected := []string{"test-uuid:1", "test-uuid:2", "test-uuid:3"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestOracleGtidSetEntry_ExplodeSeqStopsAtMaxInt64(t *testing.T) {
+	entry := &OracleGtidSetEntry{UUID: "test-uuid", Ranges: "9223372036854775806-9223372036854775807"}
+	if got := len(entry.Explode()); got != 2 {
+		t.Errorf("expected 2 entries at the top of the int64 range, got %d", got)
+	}
+}
+
 func TestReplicationCommandsForVersion(t *testing.T) {
 	tests := []struct {
 		version  string
@@ -298,6 +431,34 @@ func TestReplicationCommandsForVersion(t *testing.T) {
 	}
 }
 
+func TestReplicationCommandsForChannel(t *testing.T) {
+	tests := []struct {
+		version    string
+		channel    string
+		wantStop   string
+		wantStart  string
+		wantStatus string
+		wantErr    bool
+	}{
+		{"8.4.0", "orders", "STOP REPLICA FOR CHANNEL 'orders'", "START REPLICA FOR CHANNEL 'orders'", "SHOW REPLICA STATUS FOR CHANNEL 'orders'", false},
+		{"5.7.44-log", "orders", "STOP SLAVE FOR CHANNEL 'orders'", "START SLAVE FOR CHANNEL 'orders'", "SHOW SLAVE STATUS FOR CHANNEL 'orders'", false},
+		{"10.11.6-MariaDB", "orders", "STOP SLAVE 'orders'", "START SLAVE 'orders'", "SHOW SLAVE 'orders' STATUS", false},
+		{"8.4.0", "x'; DROP TABLE t; --", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version+"/"+tt.channel, func(t *testing.T) {
+			stop, start, status, err := replicationCommandsForChannel(tt.version, tt.channel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if stop != tt.wantStop || start != tt.wantStart || status != tt.wantStatus {
+				t.Errorf("got %q / %q / %q, expected %q / %q / %q", stop, start, status, tt.wantStop, tt.wantStart, tt.wantStatus)
+			}
+		})
+	}
+}
+
 // DatabaseInterface defines the database operations we need for testing
 type DatabaseInterface interface {
 	QueryRow(query string, args ...interface{}) *sql.Row
@@ -341,7 +502,7 @@ func TestGetServerInfo_Integration(t *testing.T) {
 	t.Logf("GTID_EXECUTED: %s", gtidExecuted)
 }
 
-func TestCheckErrantTransactions_Integration(t *testing.T) {
+func TestGtidSetSubtract_MatchesServer_Integration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
 	}
@@ -357,28 +518,33 @@ func TestCheckErrantTransactions_Integration(t *testing.T) {
 		t.Skip("Cannot ping test database:", err)
 	}
 
-	// Test with identical GTID sets (should return empty string)
-	errant, err := checkErrantTransactions(context.Background(), "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-10", "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-10", db)
-	if err != nil {
-		t.Fatalf("checkErrantTransactions failed: %v", err)
-	}
-
-	if errant != "" {
-		t.Errorf("Expected empty errant transactions for identical sets, got: %s", errant)
-	}
-
-	// Test with different GTID sets
-	errant, err = checkErrantTransactions(context.Background(), "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-10", "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-5", db)
-	if err != nil {
-		t.Fatalf("checkErrantTransactions failed: %v", err)
-	}
-
-	// Should find errant transactions (6-10 from the first set that aren't in the second)
-	if errant == "" {
-		t.Error("Expected to find errant transactions")
+	// The local subtraction must agree with the server's gtid_subtract.
+	cases := [][2]string{
+		{"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-10", "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-10"},
+		{"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-10", "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-5"},
+		{"1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-10:20-30", "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:5-25"},
+		{"1D1FFF5A-C9BC-11ED-9C19-02A36D996B94:1-3:4-6,2af7e535-9255-11f0-87f8-76ae10baffb1:7", "1d1fff5a-c9bc-11ed-9c19-02a36d996b94:2"},
+		{"2af7e535-9255-11f0-87f8-76ae10baffb1:1-5,1d1fff5a-c9bc-11ed-9c19-02a36d996b94:1-5", ""},
+	}
+	for _, c := range cases {
+		var server string
+		if err := db.QueryRow("SELECT gtid_subtract(?, ?)", c[0], c[1]).Scan(&server); err != nil {
+			t.Fatalf("gtid_subtract(%q, %q) failed: %v", c[0], c[1], err)
+		}
+		a, err := NewGtidSet(c[0])
+		if err != nil {
+			t.Fatalf("NewGtidSet(%q) failed: %v", c[0], err)
+		}
+		b, err := NewGtidSet(c[1])
+		if err != nil {
+			t.Fatalf("NewGtidSet(%q) failed: %v", c[1], err)
+		}
+		local := a.Subtract(b).String()
+		// The server separates entries with ",\n"; otherwise the text must match exactly.
+		if server = strings.ReplaceAll(server, "\n", ""); local != server {
+			t.Errorf("gtid_subtract(%q, %q): server %q, local %q", c[0], c[1], server, local)
+		}
 	}
-
-	t.Logf("Errant transactions: %s", errant)
 }
 
 func TestApplyGtidFixes_Integration(t *testing.T) {
@@ -407,7 +573,7 @@ func TestApplyGtidFixes_Integration(t *testing.T) {
 	}
 	defer conn.Close()
 
-	err = applyGtidEntries(ctx, conn, entries, "test")
+	err = applyGtidEntries(ctx, os.Stdout, conn, slices.Values(entries), "test")
 	if err != nil {
 		t.Fatalf("applyGtidEntries failed: %v", err)
 	}
@@ -429,7 +595,9 @@ func BenchmarkParseErrantTransactions(b *testing.B) {
 	for _, tc := range testCases {
 		b.Run(tc, func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				_, _ = parseErrantTransactions(tc)
+				seq, _, _ := parseErrantTransactions(tc)
+				for range seq {
+				}
 			}
 		})
 	}
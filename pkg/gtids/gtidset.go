@@ -0,0 +1,402 @@
+package gtids
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxGno is the largest transaction number MySQL accepts (GNO_END - 1).
+const maxGno = math.MaxInt64 - 1
+
+// Interval is an inclusive range of transaction numbers, e.g. 1-5.
+type Interval struct {
+	Start int64
+	End   int64
+}
+
+// String renders the interval the way MySQL does: "5" or "1-5".
+func (i Interval) String() string {
+	if i.Start == i.End {
+		return strconv.FormatInt(i.Start, 10)
+	}
+	return fmt.Sprintf("%d-%d", i.Start, i.End)
+}
+
+// parseGno parses a transaction number, rejecting 0 and anything past maxGno.
+func parseGno(text string) (int64, error) {
+	gno, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || gno < 1 || gno > maxGno {
+		return 0, fmt.Errorf("invalid transaction number %q", text)
+	}
+	return gno, nil
+}
+
+// Intervals parses the entry's ranges into sorted, merged intervals.
+// Like MySQL, a reversed interval such as "5-3" is ignored rather than rejected.
+func (oge *OracleGtidSetEntry) Intervals() ([]Interval, error) {
+	var result []Interval
+	for _, interval := range strings.Split(oge.Ranges, ":") {
+		interval = strings.TrimSpace(interval)
+		var start, end int64
+		var err error
+		if submatch := multiValueInterval.FindStringSubmatch(interval); submatch != nil {
+			if start, err = parseGno(submatch[1]); err != nil {
+				return nil, err
+			}
+			if end, err = parseGno(submatch[2]); err != nil {
+				return nil, err
+			}
+		} else if singleValueInterval.MatchString(interval) {
+			if start, err = parseGno(interval); err != nil {
+				return nil, err
+			}
+			end = start
+		} else {
+			return nil, fmt.Errorf("cannot parse interval %q in %s", interval, oge.String())
+		}
+		if end >= start {
+			result = append(result, Interval{Start: start, End: end})
+		}
+	}
+	return normalizeIntervals(result), nil
+}
+
+// normalizeIntervals sorts intervals and merges overlapping or adjacent ones.
+func normalizeIntervals(intervals []Interval) []Interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(intervals)
+	slices.SortFunc(sorted, func(a, b Interval) int {
+		if a.Start != b.Start {
+			return compareInt64(a.Start, b.Start)
+		}
+		return compareInt64(a.End, b.End)
+	})
+	merged := sorted[:1]
+	for _, next := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if next.Start <= last.End+1 {
+			last.End = max(last.End, next.End)
+		} else {
+			merged = append(merged, next)
+		}
+	}
+	return merged
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// subtractIntervals returns the parts of a not covered by b. Both inputs must be normalized.
+func subtractIntervals(a, b []Interval) []Interval {
+	var result []Interval
+	j := 0
+	for _, cur := range a {
+		for j < len(b) && b[j].End < cur.Start {
+			j++
+		}
+		for k := j; k < len(b) && b[k].Start <= cur.End; k++ {
+			if b[k].Start > cur.Start {
+				result = append(result, Interval{Start: cur.Start, End: b[k].Start - 1})
+			}
+			cur.Start = b[k].End + 1
+			if cur.Start > cur.End {
+				break
+			}
+		}
+		if cur.Start <= cur.End {
+			result = append(result, cur)
+		}
+	}
+	return result
+}
+
+// intersectIntervals returns the parts covered by both a and b. Both inputs must be normalized.
+func intersectIntervals(a, b []Interval) []Interval {
+	var result []Interval
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		start := max(a[i].Start, b[j].Start)
+		end := min(a[i].End, b[j].End)
+		if start <= end {
+			result = append(result, Interval{Start: start, End: end})
+		}
+		if a[i].End < b[j].End {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+// GtidSet is a parsed GTID set: for each UUID, a sorted list of disjoint,
+// non-adjacent intervals — the shape MySQL keeps in memory — so set algebra
+// can run locally with the same results as the server's GTID functions.
+// A GtidSet is immutable; every operation returns a new set.
+// Tagged GTIDs (MySQL 8.3+) are kept under "uuid:tag", apart from the
+// untagged transactions of the same UUID, exactly as MySQL tracks them.
+type GtidSet struct {
+	intervals map[string][]Interval // keyed by lowercase "uuid" or "uuid:tag", never empty
+}
+
+// NewGtidSet parses a GTID set such as the value of @@GLOBAL.gtid_executed.
+// UUIDs are compared case-insensitively and intervals are merged, so
+// "A:1-3,a:4-5" and "a:1-5" are the same set.
+func NewGtidSet(gtidSet string) (*GtidSet, error) {
+	oracleGtidSet, err := NewOracleGtidSet(gtidSet)
+	if err != nil {
+		return nil, err
+	}
+	return oracleGtidSet.GtidSet()
+}
+
+// GtidSet parses the ranges of every entry into a GtidSet.
+func (ogs *OracleGtidSet) GtidSet() (*GtidSet, error) {
+	res := &GtidSet{intervals: map[string][]Interval{}}
+	for _, entry := range ogs.GtidEntries {
+		intervals, err := entry.Intervals()
+		if err != nil {
+			return nil, err
+		}
+		res.add(gtidSetKey(entry.UUID, entry.Tag), intervals)
+	}
+	return res, nil
+}
+
+// gtidSetKey returns the GtidSet key for a UUID and optional tag.
+func gtidSetKey(uuid, tag string) string {
+	if tag == "" {
+		return strings.ToLower(uuid)
+	}
+	return strings.ToLower(uuid + ":" + tag)
+}
+
+// add merges normalized intervals into the set. Only used while building a new set.
+func (s *GtidSet) add(uuid string, intervals []Interval) {
+	if len(intervals) == 0 {
+		return
+	}
+	if existing, ok := s.intervals[uuid]; ok {
+		intervals = normalizeIntervals(append(slices.Clone(existing), intervals...))
+	}
+	s.intervals[uuid] = intervals
+}
+
+// UUIDs returns the UUIDs present in the set in MySQL's order: by UUID, the
+// untagged transactions first, then each tag alphabetically. Tagged
+// transactions are listed as "uuid:tag".
+func (s *GtidSet) UUIDs() []string {
+	uuids := make([]string, 0, len(s.intervals))
+	for uuid := range s.intervals {
+		uuids = append(uuids, uuid)
+	}
+	slices.SortFunc(uuids, func(a, b string) int {
+		uuidA, tagA, _ := strings.Cut(a, ":")
+		uuidB, tagB, _ := strings.Cut(b, ":")
+		if c := strings.Compare(uuidA, uuidB); c != 0 {
+			return c
+		}
+		return strings.Compare(tagA, tagB)
+	})
+	return uuids
+}
+
+// Intervals returns the intervals held for uuid (or "uuid:tag"), or nil if it has none.
+func (s *GtidSet) Intervals(uuid string) []Interval {
+	return slices.Clone(s.intervals[strings.ToLower(uuid)])
+}
+
+// IsEmpty reports whether the set holds no transactions.
+func (s *GtidSet) IsEmpty() bool {
+	return len(s.intervals) == 0
+}
+
+// Count returns the number of transactions in the set. Several UUIDs can
+// hold more than fits in an int64; the count then saturates at math.MaxInt64.
+func (s *GtidSet) Count() (n int64) {
+	for _, count := range s.CountByUUID() {
+		n = addCounts(n, count)
+	}
+	return n
+}
+
+// CountByUUID returns the number of transactions held for each UUID
+// (tagged transactions are counted under "uuid:tag"), saturating like Count.
+func (s *GtidSet) CountByUUID() map[string]int64 {
+	counts := make(map[string]int64, len(s.intervals))
+	for uuid, intervals := range s.intervals {
+		for _, interval := range intervals {
+			counts[uuid] = addCounts(counts[uuid], interval.End-interval.Start+1)
+		}
+	}
+	return counts
+}
+
+// addCounts adds two non-negative transaction counts, saturating at
+// math.MaxInt64 instead of overflowing.
+func addCounts(a, b int64) int64 {
+	if b > math.MaxInt64-a {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// Gaps returns the holes inside each UUID's intervals: the transactions
+// between its first and last one that the set does not hold. For example
+// the gaps of "uuid:1-100:105-200" are "uuid:101-104".
+func (s *GtidSet) Gaps() *GtidSet {
+	res := &GtidSet{intervals: map[string][]Interval{}}
+	for uuid, intervals := range s.intervals {
+		var holes []Interval
+		for i := 1; i < len(intervals); i++ {
+			holes = append(holes, Interval{Start: intervals[i-1].End + 1, End: intervals[i].Start - 1})
+		}
+		if len(holes) > 0 {
+			res.intervals[uuid] = holes
+		}
+	}
+	return res
+}
+
+// Summary describes the size of the set for humans, e.g.
+// "5 errant transactions across 2 UUIDs"; kind may be empty. Tags of one UUID
+// count as one UUID. A count that saturated is shown as "more than
+// 9223372036854775806".
+func (s *GtidSet) Summary(kind string) string {
+	uuids := map[string]bool{}
+	for key := range s.intervals {
+		uuid, _, _ := strings.Cut(key, ":")
+		uuids[uuid] = true
+	}
+	count := s.Count()
+	transactions, uuidNoun := "transactions", "UUIDs"
+	if count == 1 {
+		transactions = "transaction"
+	}
+	if len(uuids) == 1 {
+		uuidNoun = "UUID"
+	}
+	number := strconv.FormatInt(count, 10)
+	if count == math.MaxInt64 {
+		number = "more than " + strconv.FormatInt(math.MaxInt64-1, 10)
+	}
+	return strings.Join(strings.Fields(fmt.Sprintf("%s %s %s across %d %s", number, kind, transactions, len(uuids), uuidNoun)), " ")
+}
+
+// Contains reports whether the single transaction uuid:gno is in the set;
+// pass "uuid:tag" as uuid for a tagged transaction.
+func (s *GtidSet) Contains(uuid string, gno int64) bool {
+	intervals := s.intervals[strings.ToLower(uuid)]
+	i, _ := slices.BinarySearchFunc(intervals, gno, func(interval Interval, gno int64) int {
+		return compareInt64(interval.End, gno)
+	})
+	return i < len(intervals) && intervals[i].Start <= gno
+}
+
+// Union returns the transactions in either set (MySQL's GTID union).
+func (s *GtidSet) Union(other *GtidSet) *GtidSet {
+	res := &GtidSet{intervals: map[string][]Interval{}}
+	for uuid, intervals := range s.intervals {
+		res.intervals[uuid] = intervals
+	}
+	for uuid, intervals := range other.intervals {
+		res.add(uuid, intervals)
+	}
+	return res
+}
+
+// Subtract returns the transactions in s that are not in other, like GTID_SUBTRACT(s, other).
+func (s *GtidSet) Subtract(other *GtidSet) *GtidSet {
+	res := &GtidSet{intervals: map[string][]Interval{}}
+	for uuid, intervals := range s.intervals {
+		if remaining := subtractIntervals(intervals, other.intervals[uuid]); len(remaining) > 0 {
+			res.intervals[uuid] = remaining
+		}
+	}
+	return res
+}
+
+// Intersect returns the transactions present in both sets.
+func (s *GtidSet) Intersect(other *GtidSet) *GtidSet {
+	res := &GtidSet{intervals: map[string][]Interval{}}
+	for uuid, intervals := range s.intervals {
+		if common := intersectIntervals(intervals, other.intervals[uuid]); len(common) > 0 {
+			res.intervals[uuid] = common
+		}
+	}
+	return res
+}
+
+// IsSubset reports whether every transaction in s is also in other, like GTID_SUBSET(s, other).
+func (s *GtidSet) IsSubset(other *GtidSet) bool {
+	return s.Subtract(other).IsEmpty()
+}
+
+// Equal reports whether both sets hold exactly the same transactions.
+func (s *GtidSet) Equal(other *GtidSet) bool {
+	if len(s.intervals) != len(other.intervals) {
+		return false
+	}
+	for uuid, intervals := range s.intervals {
+		if !slices.Equal(intervals, other.intervals[uuid]) {
+			return false
+		}
+	}
+	return true
+}
+
+// OracleGtidSet converts the set back to its entry representation, one entry
+// per UUID and tag, in canonical order.
+func (s *GtidSet) OracleGtidSet() *OracleGtidSet {
+	res := &OracleGtidSet{}
+	for _, key := range s.UUIDs() {
+		uuid, tag, _ := strings.Cut(key, ":")
+		ranges := []string{}
+		for _, interval := range s.intervals[key] {
+			ranges = append(ranges, interval.String())
+		}
+		res.GtidEntries = append(res.GtidEntries, &OracleGtidSetEntry{UUID: uuid, Tag: tag, Ranges: strings.Join(ranges, ":")})
+	}
+	return res
+}
+
+// String renders the set in MySQL's canonical form: UUIDs sorted, intervals
+// merged and ascending, tags after the untagged ranges of their UUID.
+// Entries are separated by "," (MySQL uses ",\n").
+func (s *GtidSet) String() string {
+	return s.OracleGtidSet().String()
+}
+
+// Normalize rewrites the set in MySQL's canonical form: lowercase UUIDs in
+// sorted order, one entry per UUID and tag, intervals merged and ascending,
+// and entries without any transaction dropped. Sets read from user input,
+// SHOW REPLICA STATUS and backups then compare and print identically.
+func (ogs *OracleGtidSet) Normalize() error {
+	gtidSet, err := ogs.GtidSet()
+	if err != nil {
+		return err
+	}
+	ogs.GtidEntries = gtidSet.OracleGtidSet().GtidEntries
+	return nil
+}
+
+// NormalizeGtidSet returns gtidSet in MySQL's canonical form.
+func NormalizeGtidSet(gtidSet string) (string, error) {
+	parsed, err := NewGtidSet(gtidSet)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
@@ -0,0 +1,290 @@
+package gtids
+
+import (
+	"math"
+	"testing"
+)
+
+const (
+	uuidA = "1d1fff5a-c9bc-11ed-9c19-02a36d996b94"
+	uuidB = "2af7e535-9255-11f0-87f8-76ae10baffb1"
+)
+
+func mustGtidSet(t testing.TB, text string) *GtidSet {
+	t.Helper()
+	set, err := NewGtidSet(text)
+	if err != nil {
+		t.Fatalf("NewGtidSet(%q) failed: %v", text, err)
+	}
+	return set
+}
+
+func TestNewGtidSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		hasError bool
+	}{
+		{name: "empty string", input: "", expected: ""},
+		{name: "single transaction", input: uuidA + ":7", expected: uuidA + ":7"},
+		{name: "adjacent intervals merge", input: uuidA + ":1-5:6-10", expected: uuidA + ":1-10"},
+		{name: "overlapping intervals merge", input: uuidA + ":1-5:3-8:20", expected: uuidA + ":1-8:20"},
+		{name: "unsorted intervals", input: uuidA + ":20-30:1-2", expected: uuidA + ":1-2:20-30"},
+		{name: "duplicate UUID across entries", input: uuidA + ":1-3," + uuidA + ":4-5", expected: uuidA + ":1-5"},
+		{name: "UUIDs are case-insensitive", input: "1D1FFF5A-C9BC-11ED-9C19-02A36D996B94:1," + uuidA + ":2", expected: uuidA + ":1-2"},
+		{name: "UUIDs are sorted", input: uuidB + ":1," + uuidA + ":1", expected: uuidA + ":1," + uuidB + ":1"},
+		{name: "MySQL multi-line output", input: uuidA + ":1-3,\n" + uuidB + ":1-5", expected: uuidA + ":1-3," + uuidB + ":1-5"},
+		{name: "reversed interval is ignored like MySQL", input: uuidA + ":5-3," + uuidB + ":1", expected: uuidB + ":1"},
+		{name: "tags follow the untagged ranges", input: uuidA + ":zeta:1," + uuidA + ":alpha:2-3," + uuidA + ":1", expected: uuidA + ":1:alpha:2-3:zeta:1"},
+		{name: "tags are case-insensitive", input: uuidA + ":MyTag:1," + uuidA + ":mytag:2", expected: uuidA + ":mytag:1-2"},
+		{name: "zero is not a transaction number", input: uuidA + ":0", hasError: true},
+		{name: "overflow past int64", input: uuidA + ":1-9223372036854775807", hasError: true},
+		{name: "garbage interval", input: uuidA + ":abc", hasError: true},
+		{name: "missing colon", input: uuidA, hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := NewGtidSet(tt.input)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("expected error but got none (set %q)", set)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := set.String(); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGtidSet_Algebra(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      string
+		union     string
+		subtract  string
+		intersect string
+		subset    bool
+	}{
+		{
+			name:      "identical",
+			a:         uuidA + ":1-10",
+			b:         uuidA + ":1-10",
+			union:     uuidA + ":1-10",
+			subtract:  "",
+			intersect: uuidA + ":1-10",
+			subset:    true,
+		},
+		{
+			name:      "errant tail",
+			a:         uuidA + ":1-10",
+			b:         uuidA + ":1-5",
+			union:     uuidA + ":1-10",
+			subtract:  uuidA + ":6-10",
+			intersect: uuidA + ":1-5",
+		},
+		{
+			name:      "hole punched in the middle",
+			a:         uuidA + ":1-10:20-30",
+			b:         uuidA + ":5-25",
+			union:     uuidA + ":1-30",
+			subtract:  uuidA + ":1-4:26-30",
+			intersect: uuidA + ":5-10:20-25",
+		},
+		{
+			name:      "disjoint UUIDs",
+			a:         uuidA + ":1-3",
+			b:         uuidB + ":1-3",
+			union:     uuidA + ":1-3," + uuidB + ":1-3",
+			subtract:  uuidA + ":1-3",
+			intersect: "",
+		},
+		{
+			name:      "empty left side",
+			a:         "",
+			b:         uuidA + ":1-3",
+			union:     uuidA + ":1-3",
+			subtract:  "",
+			intersect: "",
+			subset:    true,
+		},
+		{
+			name:      "many small intervals against one large",
+			a:         uuidA + ":1:3:5:7:9",
+			b:         uuidA + ":2-8",
+			union:     uuidA + ":1-9",
+			subtract:  uuidA + ":1:9",
+			intersect: uuidA + ":3:5:7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := mustGtidSet(t, tt.a), mustGtidSet(t, tt.b)
+			if got := a.Union(b).String(); got != tt.union {
+				t.Errorf("Union: expected %q, got %q", tt.union, got)
+			}
+			if got := a.Subtract(b).String(); got != tt.subtract {
+				t.Errorf("Subtract: expected %q, got %q", tt.subtract, got)
+			}
+			if got := a.Intersect(b).String(); got != tt.intersect {
+				t.Errorf("Intersect: expected %q, got %q", tt.intersect, got)
+			}
+			if got := a.IsSubset(b); got != tt.subset {
+				t.Errorf("IsSubset: expected %v, got %v", tt.subset, got)
+			}
+			// Inputs are never modified by an operation.
+			if a.String() != mustGtidSet(t, tt.a).String() || b.String() != mustGtidSet(t, tt.b).String() {
+				t.Errorf("operands were modified: %q / %q", a, b)
+			}
+		})
+	}
+}
+
+func TestGtidSet_Contains(t *testing.T) {
+	set := mustGtidSet(t, uuidA+":1-5:10:20-30")
+	for gno, want := range map[int64]bool{1: true, 5: true, 6: false, 10: true, 11: false, 25: true, 31: false} {
+		if got := set.Contains(uuidA, gno); got != want {
+			t.Errorf("Contains(%d): expected %v, got %v", gno, want, got)
+		}
+	}
+	if !set.Contains("1D1FFF5A-C9BC-11ED-9C19-02A36D996B94", 3) {
+		t.Error("Contains should be case-insensitive on the UUID")
+	}
+	if set.Contains(uuidB, 1) {
+		t.Error("Contains reported a transaction for an unknown UUID")
+	}
+}
+
+func TestGtidSet_TaggedAlgebra(t *testing.T) {
+	// A tagged transaction is distinct from the untagged one with the same number.
+	target := mustGtidSet(t, uuidA+":1-10:mytag:1-3")
+	source := mustGtidSet(t, uuidA+":1-10")
+	if got := target.Subtract(source).String(); got != uuidA+":mytag:1-3" {
+		t.Errorf("expected only the tagged transactions to be errant, got %q", got)
+	}
+	if !target.Contains(uuidA+":MYTAG", 2) || target.Contains(uuidA+":mytag", 4) {
+		t.Error("Contains mishandled a tagged transaction")
+	}
+	if source.Subtract(target).String() != "" {
+		t.Error("untagged transactions should be covered")
+	}
+}
+
+func TestGtidSet_Equal(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{uuidA + ":1-5:6-10", uuidA + ":1-10", true},
+		{uuidA + ":1," + uuidB + ":1", uuidB + ":1," + uuidA + ":1", true},
+		{uuidA + ":1-10", uuidA + ":1-11", false},
+		{uuidA + ":1-10", uuidA + ":1-10," + uuidB + ":1", false},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		if got := mustGtidSet(t, tt.a).Equal(mustGtidSet(t, tt.b)); got != tt.equal {
+			t.Errorf("Equal(%q, %q): expected %v, got %v", tt.a, tt.b, tt.equal, got)
+		}
+	}
+}
+
+func TestOracleGtidSet_Normalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "already canonical", input: uuidA + ":1-10," + uuidB + ":1", expected: uuidA + ":1-10," + uuidB + ":1"},
+		{name: "mixed case and unsorted", input: "2AF7E535-9255-11F0-87F8-76AE10BAFFB1:1,1D1FFF5A-C9BC-11ED-9C19-02A36D996B94:1", expected: uuidA + ":1," + uuidB + ":1"},
+		{name: "duplicate UUIDs and overlaps", input: uuidA + ":5-10," + uuidA + ":1-6:12", expected: uuidA + ":1-10:12"},
+		{name: "entry without transactions dropped", input: uuidA + ":7-3," + uuidB + ":2", expected: uuidB + ":2"},
+		{name: "tags folded after the untagged ranges", input: uuidA + ":T:2," + uuidA + ":1", expected: uuidA + ":1:t:2"},
+		{name: "empty", input: " ", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := NewOracleGtidSet(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := set.Normalize(); err != nil {
+				t.Fatalf("Normalize failed: %v", err)
+			}
+			if got := set.String(); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+			normalized, err := NormalizeGtidSet(tt.input)
+			if err != nil || normalized != tt.expected {
+				t.Errorf("NormalizeGtidSet: expected %q, got %q (%v)", tt.expected, normalized, err)
+			}
+		})
+	}
+
+	set, _ := NewOracleGtidSet(uuidA + ":1-x")
+	if err := set.Normalize(); err == nil {
+		t.Error("expected Normalize to reject a malformed interval")
+	}
+}
+
+func BenchmarkGtidSet_Subtract(b *testing.B) {
+	a := mustGtidSet(b, uuidA+":1-1000000:1000002-2000000,"+uuidB+":1-500")
+	other := mustGtidSet(b, uuidA+":1-1999990,"+uuidB+":1-500")
+	for i := 0; i < b.N; i++ {
+		_ = a.Subtract(other)
+	}
+}
+
+func TestGtidSet_CountAndGaps(t *testing.T) {
+	set := mustGtidSet(t, uuidA+":1-100:105-200:300,"+uuidB+":1-5:mytag:1-2")
+	if got := set.Count(); got != 100+96+1+5+2 {
+		t.Errorf("unexpected Count %d", got)
+	}
+	counts := set.CountByUUID()
+	if counts[uuidA] != 197 || counts[uuidB] != 5 || counts[uuidB+":mytag"] != 2 || len(counts) != 3 {
+		t.Errorf("unexpected CountByUUID %v", counts)
+	}
+	if got := set.Gaps().String(); got != uuidA+":101-104:201-299" {
+		t.Errorf("unexpected Gaps %q", got)
+	}
+	if got := set.Summary("errant"); got != "204 errant transactions across 2 UUIDs" {
+		t.Errorf("unexpected Summary %q", got)
+	}
+	if got := mustGtidSet(t, uuidA+":7").Summary(""); got != "1 transaction across 1 UUID" {
+		t.Errorf("unexpected Summary %q", got)
+	}
+	if empty := mustGtidSet(t, ""); empty.Count() != 0 || !empty.Gaps().IsEmpty() {
+		t.Error("empty set should have no transactions and no gaps")
+	}
+}
+
+func TestGtidSet_CountIsExact(t *testing.T) {
+	// Counting must not explode the set: this range would take years to iterate.
+	set := mustGtidSet(t, uuidA+":1-9223372036854775806")
+	if got := set.Count(); got != maxGno {
+		t.Errorf("expected %d, got %d", int64(maxGno), got)
+	}
+}
+
+func TestGtidSet_CountSaturates(t *testing.T) {
+	// Two full-range UUIDs hold 2*(2^63-2) transactions, past int64.
+	set := mustGtidSet(t, uuidA+":1-9223372036854775806,"+uuidB+":1-9223372036854775806")
+	if got := set.Count(); got != math.MaxInt64 {
+		t.Errorf("expected the count to saturate at %d, got %d", int64(math.MaxInt64), got)
+	}
+	for uuid, count := range set.CountByUUID() {
+		if count != maxGno {
+			t.Errorf("expected %d for %s, got %d", int64(maxGno), uuid, count)
+		}
+	}
+	if got := set.Summary("errant"); got != "more than 9223372036854775806 errant transactions across 2 UUIDs" {
+		t.Errorf("unexpected Summary %q", got)
+	}
+}
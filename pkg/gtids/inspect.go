@@ -0,0 +1,261 @@
+package gtids
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// tableMapPattern reads a Table_map event's Info: "table_id: 90 (shop.orders)".
+	tableMapPattern = regexp.MustCompile(`table_id: (\d+) \(([^)]*)\)`)
+	// rowsEventPattern reads the table id from a rows event's Info: "table_id: 90 flags: STMT_END_F".
+	rowsEventPattern = regexp.MustCompile(`table_id: (\d+)`)
+	// statementTablePattern finds the table a DDL or DML statement works on,
+	// e.g. "orders" in "ALTER TABLE orders ..." or "DELETE FROM shop.orders ...".
+	statementTablePattern = regexp.MustCompile("(?i)\\b(?:TABLE|INTO|UPDATE|FROM|ON)\\s+(?:IF\\s+(?:NOT\\s+)?EXISTS\\s+)?(`[^`]+`(?:\\.`[^`]+`)?|[\\w$]+(?:\\.[\\w$]+)?)")
+	// leadingCommentPattern strips comments before a statement's first keyword.
+	leadingCommentPattern = regexp.MustCompile(`^(?:\s*/\*.*?\*/)*\s*`)
+)
+
+// Impact classifies what an errant transaction did, from least to most harmful.
+type Impact int
+
+const (
+	// ImpactEmpty is a transaction without statements or row changes, e.g.
+	// one injected by an earlier -fix.
+	ImpactEmpty Impact = iota
+	// ImpactAdmin is a transaction that changed no table data: FLUSH, ANALYZE,
+	// OPTIMIZE, account management and the like.
+	ImpactAdmin
+	// ImpactDDL is a schema change (TRUNCATE included).
+	ImpactDDL
+	// ImpactDML is a transaction that changed rows. Statements that are not
+	// recognized are assumed to be DML.
+	ImpactDML
+)
+
+func (i Impact) String() string {
+	switch i {
+	case ImpactEmpty:
+		return "empty"
+	case ImpactAdmin:
+		return "administrative"
+	case ImpactDDL:
+		return "DDL"
+	default:
+		return "DML"
+	}
+}
+
+// ChangesData reports whether the transaction changed schemas or rows.
+func (i Impact) ChangesData() bool {
+	return i >= ImpactDDL
+}
+
+// statementImpacts classifies statements by their leading keywords; two-word
+// entries take precedence.
+var statementImpacts = map[string]Impact{
+	"INSERT": ImpactDML, "UPDATE": ImpactDML, "DELETE": ImpactDML, "REPLACE": ImpactDML, "LOAD": ImpactDML,
+	"CREATE": ImpactDDL, "ALTER": ImpactDDL, "DROP": ImpactDDL, "RENAME": ImpactDDL, "TRUNCATE": ImpactDDL,
+	"FLUSH": ImpactAdmin, "ANALYZE": ImpactAdmin, "OPTIMIZE": ImpactAdmin, "REPAIR": ImpactAdmin, "CHECK": ImpactAdmin,
+	"GRANT": ImpactAdmin, "REVOKE": ImpactAdmin, "INSTALL": ImpactAdmin, "UNINSTALL": ImpactAdmin,
+	"CREATE USER": ImpactAdmin, "ALTER USER": ImpactAdmin, "DROP USER": ImpactAdmin, "RENAME USER": ImpactAdmin,
+	"CREATE ROLE": ImpactAdmin, "DROP ROLE": ImpactAdmin, "SET PASSWORD": ImpactAdmin,
+}
+
+// classifyStatement returns the impact of a single statement.
+func classifyStatement(statement string) Impact {
+	words := strings.Fields(strings.ToUpper(leadingCommentPattern.ReplaceAllString(statement, "")))
+	if len(words) >= 2 {
+		if impact, ok := statementImpacts[words[0]+" "+words[1]]; ok {
+			return impact
+		}
+	}
+	if len(words) >= 1 {
+		if impact, ok := statementImpacts[words[0]]; ok {
+			return impact
+		}
+	}
+	return ImpactDML
+}
+
+// statementTable returns the table a statement works on as schema.table,
+// qualified with the default schema when the statement names none, or ""
+// if it names no table.
+func statementTable(schema, statement string) string {
+	m := statementTablePattern.FindStringSubmatch(statement)
+	if m == nil {
+		return ""
+	}
+	table := strings.ReplaceAll(m[1], "`", "")
+	if !strings.Contains(table, ".") && schema != "" {
+		table = strings.Trim(schema, "`") + "." + table
+	}
+	return table
+}
+
+// rowsEventOperations maps rows event types, as SHOW BINLOG EVENTS names
+// them, to the change they make.
+var rowsEventOperations = map[string]string{
+	"Write_rows":          "insert",
+	"Write_rows_v1":       "insert",
+	"Update_rows":         "update",
+	"Update_rows_v1":      "update",
+	"Update_rows_partial": "update",
+	"Delete_rows":         "delete",
+	"Delete_rows_v1":      "delete",
+}
+
+// TableChange summarizes the rows events of one transaction for one table
+// and operation. SHOW BINLOG EVENTS does not expose row images, so rows are
+// counted only from the binary log files themselves (see countChangeRows).
+type TableChange struct {
+	Table     string // schema.table
+	Operation string // insert, update or delete
+	Events    int
+	Bytes     uint64
+	Rows      int64 // rows changed (updates count once), or -1 if unknown
+}
+
+func (c TableChange) String() string {
+	noun := "events"
+	if c.Events == 1 {
+		noun = "event"
+	}
+	rows := "rows unknown"
+	if c.Rows >= 0 {
+		rows = fmt.Sprintf("%d rows", c.Rows)
+		if c.Rows == 1 {
+			rows = "1 row"
+		}
+	}
+	return fmt.Sprintf("%s on %s (%d %s, %s, %d bytes)", c.Operation, c.Table, c.Events, noun, rows, c.Bytes)
+}
+
+// TransactionContents is what one binlog transaction did: the statements it
+// logged (statement-based Query events, or Rows_query events when
+// binlog_rows_query_log_events is on), its row changes per table, and the
+// resulting classification.
+type TransactionContents struct {
+	Statements []string
+	Changes    []TableChange
+	Impact     Impact
+	Tables     []string // schema.table names touched by DDL or DML, sorted
+}
+
+// Describe summarizes the classification, e.g. "DML on shop.audit, shop.orders".
+func (c *TransactionContents) Describe() string {
+	if len(c.Tables) == 0 {
+		return c.Impact.String()
+	}
+	return c.Impact.String() + " on " + strings.Join(c.Tables, ", ")
+}
+
+// transactionContents reads the statements and row changes out of tx's events.
+func transactionContents(tx *BinlogTransaction) *TransactionContents {
+	res := &TransactionContents{}
+	tables := map[string]string{}
+	changes := map[[2]string]int{}
+	touched := map[string]bool{}
+	for _, event := range tx.Events {
+		switch event.EventType {
+		case "Query":
+			switch strings.ToUpper(strings.TrimSpace(event.Info)) {
+			case "BEGIN", "COMMIT", "ROLLBACK":
+				continue
+			}
+			schema, statement := parseQueryEvent(event.Info)
+			res.Statements = append(res.Statements, queryEventStatement(event.Info))
+			impact := classifyStatement(statement)
+			res.Impact = max(res.Impact, impact)
+			if impact.ChangesData() {
+				if table := statementTable(schema, statement); table != "" {
+					touched[table] = true
+				}
+			}
+		case "Rows_query":
+			res.Statements = append(res.Statements, strings.TrimPrefix(event.Info, "# "))
+		case "Table_map":
+			if m := tableMapPattern.FindStringSubmatch(event.Info); m != nil {
+				tables[m[1]] = m[2]
+			}
+		default:
+			operation, ok := rowsEventOperations[event.EventType]
+			if !ok {
+				continue
+			}
+			table := "unknown table"
+			if m := rowsEventPattern.FindStringSubmatch(event.Info); m != nil {
+				if name, ok := tables[m[1]]; ok {
+					table = name
+				}
+			}
+			key := [2]string{table, operation}
+			i, seen := changes[key]
+			if !seen {
+				i = len(res.Changes)
+				changes[key] = i
+				res.Changes = append(res.Changes, TableChange{Table: table, Operation: operation, Rows: -1})
+			}
+			res.Changes[i].Events++
+			res.Changes[i].Bytes += event.EndLogPos - event.Pos
+			res.Impact = ImpactDML
+			touched[table] = true
+		}
+	}
+	for table := range touched {
+		res.Tables = append(res.Tables, table)
+	}
+	slices.Sort(res.Tables)
+	return res
+}
+
+// countChangeRows fills in the rows of contents' changes, decoded from tx's
+// events in its file in binlogDir. Rows stay unknown if it fails.
+func countChangeRows(binlogDir string, tx *BinlogTransaction, contents *TransactionContents) error {
+	rows, err := countTransactionRows(binlogDir, tx)
+	if err != nil {
+		return err
+	}
+	for i, change := range contents.Changes {
+		contents.Changes[i].Rows = rows[[2]string{change.Table, change.Operation}]
+	}
+	return nil
+}
+
+// parseQueryEvent splits a Query event's Info into the default schema
+// ("`db`", from the "use `db`; " prefix SHOW BINLOG EVENTS adds) and the statement.
+func parseQueryEvent(info string) (schema, statement string) {
+	if rest, ok := strings.CutPrefix(info, "use "); ok {
+		if schema, statement, ok := strings.Cut(rest, "; "); ok {
+			return schema, statement
+		}
+	}
+	return "", info
+}
+
+// queryEventStatement renders a Query event's statement with its default
+// schema as a comment.
+func queryEventStatement(info string) string {
+	if schema, statement := parseQueryEvent(info); schema != "" {
+		return statement + " /* in " + schema + " */"
+	}
+	return info
+}
+
+// printTransactionContents prints what a transaction did, indented under its location.
+func printTransactionContents(w io.Writer, contents *TransactionContents) {
+	if len(contents.Statements) == 0 && len(contents.Changes) == 0 {
+		fmt.Fprintln(w, "      (empty transaction)")
+		return
+	}
+	for _, statement := range contents.Statements {
+		fmt.Fprintln(w, "      statement:", statement)
+	}
+	for _, change := range contents.Changes {
+		fmt.Fprintln(w, "      rows:", change)
+	}
+}
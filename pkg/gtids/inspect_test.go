@@ -0,0 +1,138 @@
+package gtids
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTransactionContents(t *testing.T) {
+	tests := []struct {
+		name       string
+		events     []BinlogEvent
+		statements []string
+		changes    []string
+	}{
+		{
+			name: "row-based with Rows_query",
+			events: []BinlogEvent{
+				{EventType: "Gtid", Pos: 197, EndLogPos: 276},
+				{EventType: "Query", Pos: 276, EndLogPos: 351, Info: "BEGIN"},
+				{EventType: "Rows_query", Pos: 351, EndLogPos: 420, Info: "# UPDATE orders SET status = 'x' WHERE id < 3"},
+				{EventType: "Table_map", Pos: 420, EndLogPos: 480, Info: "table_id: 90 (shop.orders)"},
+				{EventType: "Update_rows", Pos: 480, EndLogPos: 600, Info: "table_id: 90"},
+				{EventType: "Update_rows", Pos: 600, EndLogPos: 700, Info: "table_id: 90 flags: STMT_END_F"},
+				{EventType: "Table_map", Pos: 700, EndLogPos: 760, Info: "table_id: 91 (shop.audit)"},
+				{EventType: "Write_rows", Pos: 760, EndLogPos: 800, Info: "table_id: 91 flags: STMT_END_F"},
+				{EventType: "Xid", Pos: 800, EndLogPos: 831, Info: "COMMIT /* xid=7 */"},
+			},
+			statements: []string{"UPDATE orders SET status = 'x' WHERE id < 3"},
+			changes: []string{
+				"update on shop.orders (2 events, rows unknown, 220 bytes)",
+				"insert on shop.audit (1 event, rows unknown, 40 bytes)",
+			},
+		},
+		{
+			name: "statement-based DDL",
+			events: []BinlogEvent{
+				{EventType: "Gtid"},
+				{EventType: "Query", Info: "use `shop`; ALTER TABLE orders ADD COLUMN note TEXT"},
+			},
+			statements: []string{"ALTER TABLE orders ADD COLUMN note TEXT /* in `shop` */"},
+		},
+		{
+			name: "empty transaction from -fix",
+			events: []BinlogEvent{
+				{EventType: "Gtid"},
+				{EventType: "Query", Info: "BEGIN"},
+				{EventType: "Query", Info: "COMMIT"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contents := transactionContents(&BinlogTransaction{Events: tt.events})
+			if len(contents.Statements) != len(tt.statements) {
+				t.Fatalf("expected statements %q, got %q", tt.statements, contents.Statements)
+			}
+			for i, statement := range tt.statements {
+				if contents.Statements[i] != statement {
+					t.Errorf("expected statement %q, got %q", statement, contents.Statements[i])
+				}
+			}
+			if len(contents.Changes) != len(tt.changes) {
+				t.Fatalf("expected changes %q, got %v", tt.changes, contents.Changes)
+			}
+			for i, change := range tt.changes {
+				if got := contents.Changes[i].String(); got != change {
+					t.Errorf("expected change %q, got %q", change, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTransactionContents_Classification(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []BinlogEvent
+		expected string
+	}{
+		{name: "empty", events: []BinlogEvent{{EventType: "Query", Info: "BEGIN"}, {EventType: "Query", Info: "COMMIT"}}, expected: "empty"},
+		{name: "flush", events: []BinlogEvent{{EventType: "Query", Info: "FLUSH TABLES"}}, expected: "administrative"},
+		{name: "account management", events: []BinlogEvent{{EventType: "Query", Info: "CREATE USER 'app'@'%' IDENTIFIED WITH 'caching_sha2_password'"}}, expected: "administrative"},
+		{name: "analyze with comment", events: []BinlogEvent{{EventType: "Query", Info: "use `shop`; /* cron */ ANALYZE TABLE orders"}}, expected: "administrative"},
+		{name: "DDL in default schema", events: []BinlogEvent{{EventType: "Query", Info: "use `shop`; CREATE TABLE IF NOT EXISTS `orders_new` (id int)"}}, expected: "DDL on shop.orders_new"},
+		{name: "truncate is DDL", events: []BinlogEvent{{EventType: "Query", Info: "TRUNCATE TABLE shop.orders"}}, expected: "DDL on shop.orders"},
+		{name: "statement-based DML", events: []BinlogEvent{{EventType: "Query", Info: "BEGIN"}, {EventType: "Query", Info: "use `shop`; DELETE FROM orders WHERE id = 1"}}, expected: "DML on shop.orders"},
+		{name: "row-based DML", events: []BinlogEvent{
+			{EventType: "Table_map", Info: "table_id: 90 (shop.orders)"},
+			{EventType: "Delete_rows", Info: "table_id: 90 flags: STMT_END_F"},
+		}, expected: "DML on shop.orders"},
+		{name: "unknown statement is assumed DML", events: []BinlogEvent{{EventType: "Query", Info: "CALL shop.cleanup()"}}, expected: "DML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transactionContents(&BinlogTransaction{Events: tt.events}).Describe(); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCountChangeRows(t *testing.T) {
+	data := errantTransactionBinlog(t,
+		ordersTableMap(t),
+		ordersRows(t, UpdateRowsEventType, ordersUpdate),
+		ordersRows(t, WriteRowsEventType, "03 07  00 07000000 03 6e6577 0000  00 08000000 00 0000"),
+	)
+	tx := locateTestTransactions(t, data).Transactions[0]
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "binlog.000001"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	contents := transactionContents(tx)
+	if err := countChangeRows(dir, tx, contents); err != nil {
+		t.Fatalf("countChangeRows failed: %v", err)
+	}
+	var changes []string
+	for _, change := range contents.Changes {
+		changes = append(changes, change.String())
+	}
+	expected := []string{
+		fmt.Sprintf("update on shop.orders (1 event, 1 row, %d bytes)", contents.Changes[0].Bytes),
+		fmt.Sprintf("insert on shop.orders (1 event, 2 rows, %d bytes)", contents.Changes[1].Bytes),
+	}
+	if !slices.Equal(changes, expected) {
+		t.Errorf("expected %q, got %q", expected, changes)
+	}
+
+	if err := countChangeRows(t.TempDir(), tx, transactionContents(tx)); err == nil {
+		t.Error("expected an error when the binary log file is missing")
+	}
+}
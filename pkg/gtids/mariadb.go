@@ -0,0 +1,346 @@
+package gtids
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MariaDB GTIDs are domain-server-sequence triples ("0-1-100") instead of
+// uuid:ranges. A position such as @@gtid_current_pos holds the last GTID of
+// each replication domain, and sequence numbers only grow within a domain,
+// so comparing two servers means comparing them domain by domain.
+
+// MariadbGtid is a single MariaDB GTID.
+type MariadbGtid struct {
+	Domain   uint32
+	ServerID uint32
+	Sequence uint64
+}
+
+// NewMariadbGtid parses a "domain-server-sequence" triple.
+func NewMariadbGtid(text string) (MariadbGtid, error) {
+	text = strings.TrimSpace(text)
+	tokens := strings.Split(text, "-")
+	if len(tokens) != 3 {
+		return MariadbGtid{}, fmt.Errorf("cannot parse MariaDB GTID from %q", text)
+	}
+	domain, err1 := strconv.ParseUint(tokens[0], 10, 32)
+	serverID, err2 := strconv.ParseUint(tokens[1], 10, 32)
+	sequence, err3 := strconv.ParseUint(tokens[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return MariadbGtid{}, fmt.Errorf("cannot parse MariaDB GTID from %q", text)
+	}
+	return MariadbGtid{Domain: uint32(domain), ServerID: uint32(serverID), Sequence: sequence}, nil
+}
+
+func (g MariadbGtid) String() string {
+	return fmt.Sprintf("%d-%d-%d", g.Domain, g.ServerID, g.Sequence)
+}
+
+// MariadbGtidList is a comma-separated list of MariaDB GTIDs, as found in
+// @@gtid_binlog_pos, @@gtid_slave_pos, @@gtid_current_pos or @@gtid_binlog_state.
+type MariadbGtidList []MariadbGtid
+
+// NewMariadbGtidList parses a GTID list; an empty string is an empty list.
+func NewMariadbGtidList(text string) (MariadbGtidList, error) {
+	var list MariadbGtidList
+	for _, token := range strings.Split(text, ",") {
+		if strings.TrimSpace(token) == "" {
+			continue
+		}
+		gtid, err := NewMariadbGtid(token)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, gtid)
+	}
+	return list, nil
+}
+
+func (l MariadbGtidList) String() string {
+	tokens := []string{}
+	for _, gtid := range l {
+		tokens = append(tokens, gtid.String())
+	}
+	return strings.Join(tokens, ",")
+}
+
+// lastPerDomain returns the highest-sequence GTID of each domain.
+func (l MariadbGtidList) lastPerDomain() map[uint32]MariadbGtid {
+	last := map[uint32]MariadbGtid{}
+	for _, gtid := range l {
+		if current, ok := last[gtid.Domain]; !ok || gtid.Sequence > current.Sequence {
+			last[gtid.Domain] = gtid
+		}
+	}
+	return last
+}
+
+// MariadbDomainDiff describes one domain in which a target and its source
+// disagree. Source or Target is nil when that server has never seen the domain.
+type MariadbDomainDiff struct {
+	Domain uint32
+	Source *MariadbGtid
+	Target *MariadbGtid
+}
+
+func (d MariadbDomainDiff) String() string {
+	switch {
+	case d.Source == nil:
+		return fmt.Sprintf("domain %d: target at %s (domain unknown to source)", d.Domain, d.Target)
+	case d.Target == nil:
+		return fmt.Sprintf("domain %d: source at %s (domain unknown to target)", d.Domain, d.Source)
+	}
+	return fmt.Sprintf("domain %d: target at %s, source at %s", d.Domain, d.Target, d.Source)
+}
+
+// CompareMariadbGtids compares a target's position with its source's, domain
+// by domain. A domain is errant when the target is ahead of the source, has
+// the same sequence from a different server (diverged history), or is unknown
+// to the source; it is missing when the target is behind or lacks the domain.
+func CompareMariadbGtids(source, target MariadbGtidList) (errant, missing []MariadbDomainDiff) {
+	sourceLast, targetLast := source.lastPerDomain(), target.lastPerDomain()
+	domains := []uint32{}
+	for domain := range sourceLast {
+		domains = append(domains, domain)
+	}
+	for domain := range targetLast {
+		if _, ok := sourceLast[domain]; !ok {
+			domains = append(domains, domain)
+		}
+	}
+	slices.Sort(domains)
+
+	for _, domain := range domains {
+		s, inSource := sourceLast[domain]
+		t, inTarget := targetLast[domain]
+		diff := MariadbDomainDiff{Domain: domain}
+		if inSource {
+			diff.Source = &s
+		}
+		if inTarget {
+			diff.Target = &t
+		}
+		switch {
+		case !inSource:
+			errant = append(errant, diff)
+		case !inTarget:
+			missing = append(missing, diff)
+		case t.Sequence > s.Sequence, t.Sequence == s.Sequence && t.ServerID != s.ServerID:
+			errant = append(errant, diff)
+		case t.Sequence < s.Sequence:
+			missing = append(missing, diff)
+		}
+	}
+	return errant, missing
+}
+
+// mariadbServerInfo holds the GTID positions of a MariaDB server.
+type mariadbServerInfo struct {
+	serverID   string
+	binlogPos  string
+	slavePos   string
+	currentPos string
+}
+
+// getMariadbServerInfo retrieves server_id and the GTID positions of a MariaDB server.
+func getMariadbServerInfo(ctx context.Context, db *sql.DB) (info mariadbServerInfo, err error) {
+	err = retryDatabaseOperation(ctx, func() error {
+		return db.QueryRowContext(ctx, "SELECT @@server_id, @@GLOBAL.gtid_binlog_pos, @@GLOBAL.gtid_slave_pos, @@GLOBAL.gtid_current_pos").
+			Scan(&info.serverID, &info.binlogPos, &info.slavePos, &info.currentPos)
+	}, 3)
+	if err != nil {
+		return info, fmt.Errorf("failed to get MariaDB GTID positions: %w", err)
+	}
+	return info, nil
+}
+
+// mariadbRealignedSlavePos is the gtid_slave_pos a replica with errant domains
+// should resume from: its own replicated position, minus domains the source
+// has never seen (the source would refuse them with error 1236).
+func mariadbRealignedSlavePos(slavePos, sourcePos MariadbGtidList) MariadbGtidList {
+	sourceLast := sourcePos.lastPerDomain()
+	realigned := MariadbGtidList{}
+	for _, gtid := range slavePos {
+		if _, ok := sourceLast[gtid.Domain]; ok {
+			realigned = append(realigned, gtid)
+		}
+	}
+	return realigned
+}
+
+// mariadbSlavePosStatements returns the sequence that points the replication
+// connection channel ("" for the default one) of a MariaDB replica at slavePos
+// and makes it connect by gtid_slave_pos, which (unlike gtid_current_pos)
+// ignores transactions the replica wrote to its own binlog, and the statement
+// showing that connection's status. The list is built from parsed GTIDs and a
+// validated connection name, so it is safe to interpolate.
+func mariadbSlavePosStatements(version, channel string, slavePos MariadbGtidList) (statements []string, statusCmd string, err error) {
+	stopCmd, startCmd, statusCmd, err := replicationCommandsForChannel(version, channel)
+	if err != nil {
+		return nil, "", err
+	}
+	return []string{
+		stopCmd,
+		"SET GLOBAL gtid_slave_pos = '" + slavePos.String() + "'",
+		"CHANGE MASTER '" + channel + "' TO MASTER_USE_GTID = slave_pos",
+		startCmd,
+	}, statusCmd, nil
+}
+
+// applyMariadbSlavePos runs mariadbSlavePosStatements on a pinned connection.
+// START SLAVE is attempted even if an earlier statement fails or ctx is cancelled.
+func applyMariadbSlavePos(ctx context.Context, w io.Writer, db *sql.DB, version, channel string, slavePos MariadbGtidList, fixLocation string) error {
+	statements, statusCmd, err := mariadbSlavePosStatements(version, channel, slavePos)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	startCmd := statements[len(statements)-1]
+	applyErr := func() error {
+		for _, stmt := range statements[:len(statements)-1] {
+			fmt.Fprintf(w, "Executing on %s: %s\n", fixLocation, stmt)
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("%s failed: %w", stmt, err)
+			}
+		}
+		return nil
+	}()
+
+	fmt.Fprintf(w, "Executing on %s: %s\n", fixLocation, startCmd)
+	if _, err := conn.ExecContext(context.WithoutCancel(ctx), startCmd); err != nil {
+		if applyErr != nil {
+			return fmt.Errorf("realigning %s failed (%v) and replication could not be restarted: %w", fixLocation, applyErr, err)
+		}
+		return fmt.Errorf("failed to start replication on %s: %w", fixLocation, err)
+	}
+	if applyErr != nil {
+		return fmt.Errorf("failed to realign %s: %w", fixLocation, applyErr)
+	}
+
+	fmt.Fprintln(w, "Waiting for replication to initialize...")
+	if err := sleepCtx(ctx, 2*time.Second); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Verifying replication status on %s...\n", fixLocation)
+	return verifyReplicationStatus(ctx, w, db, statusCmd, fixLocation, "")
+}
+
+// dryRunMariadbSlavePos prints what applyMariadbSlavePos would execute.
+func dryRunMariadbSlavePos(w io.Writer, version, channel string, slavePos MariadbGtidList) error {
+	statements, _, err := mariadbSlavePosStatements(version, channel, slavePos)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, yellow("[dry-run]"), "Would execute on replica (single pinned session):")
+	for _, stmt := range statements {
+		fmt.Fprintf(w, "    %s;\n", stmt)
+	}
+	return nil
+}
+
+// checkMariadbGtidSubset is CheckGtidSetOutcome for MariaDB. MariaDB has no
+// GTID_NEXT to commit an empty transaction under a given GTID, so errant GTIDs
+// are not injected on the source; fixes instead move the replica's
+// gtid_slave_pos, MariaDB's own mechanism for realigning or skipping. A
+// realigned replica stays unresolved: its errant events are still in its
+// gtid_current_pos and binary log. Fixes act on the replication connection
+// opts.Channel only; targetVersion picks its statements.
+func checkMariadbGtidSubset(ctx context.Context, w io.Writer, db1 *sql.DB, db2 *sql.DB, source string, target string, targetVersion string, opts Options) (unresolved bool, err error) {
+	sourceInfo, err := getMariadbServerInfo(ctx, db1)
+	if err != nil {
+		return false, fmt.Errorf("failed to get source server info: %w", err)
+	}
+	targetInfo, err := getMariadbServerInfo(ctx, db2)
+	if err != nil {
+		return false, fmt.Errorf("failed to get target server info: %w", err)
+	}
+
+	fmt.Fprintln(w, blue("[+]"), "Source ->", source, "gtid_binlog_pos:", sourceInfo.binlogPos)
+	fmt.Fprintln(w, blue("[+]"), "server_id:", sourceInfo.serverID)
+	fmt.Fprintln(w, yellow("[+]"), "Target ->", target, "gtid_current_pos:", targetInfo.currentPos, "gtid_slave_pos:", targetInfo.slavePos)
+	fmt.Fprintln(w, yellow("[+]"), "server_id:", targetInfo.serverID)
+
+	sourcePos, err := NewMariadbGtidList(sourceInfo.binlogPos)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse source gtid_binlog_pos: %w", err)
+	}
+	targetPos, err := NewMariadbGtidList(targetInfo.currentPos)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse target gtid_current_pos: %w", err)
+	}
+	slavePos, err := NewMariadbGtidList(targetInfo.slavePos)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse target gtid_slave_pos: %w", err)
+	}
+
+	errant, missing := CompareMariadbGtids(sourcePos, targetPos)
+	if len(errant) == 0 {
+		fmt.Fprintln(w, green("[+]"), "No Errant Transactions")
+	} else {
+		unresolved = true
+		for _, diff := range errant {
+			fmt.Fprintln(w, red("[-]"), "Errant Transactions:", diff)
+		}
+
+		switch {
+		case opts.Fix:
+			return unresolved, fmt.Errorf("errant GTIDs cannot be injected on a MariaDB source, which has no GTID_NEXT; use -fix-replica to realign the replica")
+		case opts.FixReplica:
+			realigned := mariadbRealignedSlavePos(slavePos, sourcePos)
+			fmt.Fprintln(w, yellow("[i]"), "The errant events stay in the replica's binlog; the replica is realigned to replicate by gtid_slave_pos.")
+			if opts.DryRun {
+				if err := dryRunMariadbSlavePos(w, targetVersion, opts.Channel, realigned); err != nil {
+					return unresolved, err
+				}
+				break
+			}
+			prompt := fmt.Sprintf("About to set gtid_slave_pos='%s' on the REPLICA %s (replication will be stopped and restarted).", realigned, target)
+			if !confirmAction(w, prompt, opts.AssumeYes) {
+				fmt.Fprintln(w, yellow("[i]"), "Skipped realigning the replica.")
+				break
+			}
+			if err := applyMariadbSlavePos(ctx, w, db2, targetVersion, opts.Channel, realigned, "replica"); err != nil {
+				return unresolved, err
+			}
+			fmt.Fprintln(w, yellow("[i]"), "Realigned the replica; the errant events are still in its gtid_current_pos and binlog, so the check stays unresolved.")
+		}
+	}
+
+	if opts.FixMissingReplica {
+		if len(missing) == 0 {
+			fmt.Fprintln(w, green("[+]"), "No Missing GTIDs")
+			return unresolved, nil
+		}
+		for _, diff := range missing {
+			fmt.Fprintln(w, red("[-]"), "Missing GTIDs:", diff)
+		}
+		fmt.Fprintln(w, red("[!]"), "WARNING: moving gtid_slave_pos to the source's position skips the missing")
+		fmt.Fprintln(w, red("[!]"), "transactions WITHOUT applying their data — the source will never resend them.")
+		fmt.Fprintln(w, red("[!]"), "The skipped transactions' data must be synced separately (e.g. data-diff).")
+
+		if opts.DryRun {
+			return true, dryRunMariadbSlavePos(w, targetVersion, opts.Channel, sourcePos)
+		}
+		prompt := fmt.Sprintf("About to set gtid_slave_pos='%s' on the REPLICA %s WITHOUT applying the missing transactions.", sourcePos, target)
+		if !confirmAction(w, prompt, opts.AssumeYes) {
+			fmt.Fprintln(w, yellow("[i]"), "Skipped moving gtid_slave_pos on replica.")
+			return true, nil
+		}
+		if err := applyMariadbSlavePos(ctx, w, db2, targetVersion, opts.Channel, sourcePos, "replica"); err != nil {
+			return unresolved, fmt.Errorf("failed to apply missing GTID fixes: %w", err)
+		}
+	}
+	return unresolved, nil
+}
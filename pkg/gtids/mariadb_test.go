@@ -0,0 +1,243 @@
+package gtids
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestNewMariadbGtidList(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected MariadbGtidList
+		hasError bool
+	}{
+		{name: "empty string", input: "", expected: nil},
+		{name: "single domain", input: "0-1-100", expected: MariadbGtidList{{Domain: 0, ServerID: 1, Sequence: 100}}},
+		{
+			name:  "several domains with whitespace",
+			input: "0-1-100,\n 5-2-7",
+			expected: MariadbGtidList{
+				{Domain: 0, ServerID: 1, Sequence: 100},
+				{Domain: 5, ServerID: 2, Sequence: 7},
+			},
+		},
+		{name: "MySQL GTID", input: uuidA + ":1-5", hasError: true},
+		{name: "missing sequence", input: "0-1", hasError: true},
+		{name: "negative number", input: "0--1-5", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := NewMariadbGtidList(tt.input)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(list, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, list)
+			}
+			if list.String() != regexp.MustCompile(`\s`).ReplaceAllString(tt.input, "") {
+				t.Errorf("String() did not round-trip: %q", list.String())
+			}
+		})
+	}
+}
+
+func TestCompareMariadbGtids(t *testing.T) {
+	tests := []struct {
+		name           string
+		source, target string
+		errant         []string
+		missing        []string
+	}{
+		{name: "in sync", source: "0-1-100", target: "0-1-100"},
+		{name: "target behind", source: "0-1-100", target: "0-1-90", missing: []string{"domain 0: target at 0-1-90, source at 0-1-100"}},
+		{name: "target ahead with local writes", source: "0-1-100", target: "0-2-101", errant: []string{"domain 0: target at 0-2-101, source at 0-1-100"}},
+		{name: "same sequence from another server", source: "0-1-100", target: "0-2-100", errant: []string{"domain 0: target at 0-2-100, source at 0-1-100"}},
+		{name: "domain unknown to source", source: "0-1-100", target: "0-1-100,7-2-3", errant: []string{"domain 7: target at 7-2-3 (domain unknown to source)"}},
+		{name: "domain unknown to target", source: "0-1-100,1-3-9", target: "0-1-100", missing: []string{"domain 1: source at 1-3-9 (domain unknown to target)"}},
+		{name: "binlog state uses the last GTID per domain", source: "0-1-100,0-3-120", target: "0-1-100,0-3-120"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewMariadbGtidList(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target, err := NewMariadbGtidList(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			errant, missing := CompareMariadbGtids(source, target)
+			toStrings := func(diffs []MariadbDomainDiff) (out []string) {
+				for _, d := range diffs {
+					out = append(out, d.String())
+				}
+				return out
+			}
+			if got := toStrings(errant); !slices.Equal(got, tt.errant) {
+				t.Errorf("errant: expected %v, got %v", tt.errant, got)
+			}
+			if got := toStrings(missing); !slices.Equal(got, tt.missing) {
+				t.Errorf("missing: expected %v, got %v", tt.missing, got)
+			}
+		})
+	}
+}
+
+func TestMariadbRealignedSlavePos(t *testing.T) {
+	slavePos, _ := NewMariadbGtidList("0-1-100,7-2-3")
+	sourcePos, _ := NewMariadbGtidList("0-1-120")
+	realigned := mariadbRealignedSlavePos(slavePos, sourcePos)
+	if realigned.String() != "0-1-100" {
+		t.Errorf("expected the unknown domain to be dropped, got %q", realigned)
+	}
+
+	tests := []struct {
+		channel    string
+		statements []string
+		status     string
+	}{
+		{
+			channel: "",
+			statements: []string{
+				"STOP SLAVE ''",
+				"SET GLOBAL gtid_slave_pos = '0-1-100'",
+				"CHANGE MASTER '' TO MASTER_USE_GTID = slave_pos",
+				"START SLAVE ''",
+			},
+			status: "SHOW SLAVE '' STATUS",
+		},
+		{
+			channel: "orders",
+			statements: []string{
+				"STOP SLAVE 'orders'",
+				"SET GLOBAL gtid_slave_pos = '0-1-100'",
+				"CHANGE MASTER 'orders' TO MASTER_USE_GTID = slave_pos",
+				"START SLAVE 'orders'",
+			},
+			status: "SHOW SLAVE 'orders' STATUS",
+		},
+	}
+	for _, tt := range tests {
+		statements, status, err := mariadbSlavePosStatements("10.11.6-MariaDB", tt.channel, realigned)
+		if err != nil {
+			t.Fatalf("channel %q: unexpected error: %v", tt.channel, err)
+		}
+		if !slices.Equal(statements, tt.statements) || status != tt.status {
+			t.Errorf("channel %q: expected %v / %q, got %v / %q", tt.channel, tt.statements, tt.status, statements, status)
+		}
+	}
+	if _, _, err := mariadbSlavePosStatements("10.11.6-MariaDB", "bad'name", realigned); err == nil {
+		t.Error("expected an invalid connection name to be refused")
+	}
+}
+
+func TestCheckGtidSetOutcome_DispatchesToMariaDB(t *testing.T) {
+	db1, mock1, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db1.Close()
+	db2, mock2, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db2.Close()
+
+	positions := regexp.QuoteMeta("SELECT @@server_id, @@GLOBAL.gtid_binlog_pos, @@GLOBAL.gtid_slave_pos, @@GLOBAL.gtid_current_pos")
+	columns := []string{"@@server_id", "gtid_binlog_pos", "gtid_slave_pos", "gtid_current_pos"}
+	mock1.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("10.11.6-MariaDB"))
+	mock2.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("10.11.6-MariaDB-log"))
+	mock1.ExpectQuery(positions).WillReturnRows(sqlmock.NewRows(columns).AddRow("1", "0-1-100", "", "0-1-100"))
+	mock2.ExpectQuery(positions).WillReturnRows(sqlmock.NewRows(columns).AddRow("2", "0-2-101", "0-1-100", "0-2-101"))
+
+	outcome, err := CheckGtidSetOutcome(context.Background(), db1, db2, "source", "target", Options{})
+	if err != nil {
+		t.Fatalf("CheckGtidSetOutcome failed: %v", err)
+	}
+	if outcome != Unresolved {
+		t.Error("expected the replica's local write to be reported as errant")
+	}
+	if err := mock1.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet source expectations: %v", err)
+	}
+	if err := mock2.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet target expectations: %v", err)
+	}
+}
+
+func TestCheckGtidSetOutcome_MariaDBRealignedReplicaStaysUnresolved(t *testing.T) {
+	db1, mock1, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db1.Close()
+	db2, mock2, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db2.Close()
+
+	positions := regexp.QuoteMeta("SELECT @@server_id, @@GLOBAL.gtid_binlog_pos, @@GLOBAL.gtid_slave_pos, @@GLOBAL.gtid_current_pos")
+	columns := []string{"@@server_id", "gtid_binlog_pos", "gtid_slave_pos", "gtid_current_pos"}
+	mock1.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("10.11.6-MariaDB"))
+	mock2.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("10.11.6-MariaDB-log"))
+	mock1.ExpectQuery(positions).WillReturnRows(sqlmock.NewRows(columns).AddRow("1", "0-1-100", "", "0-1-100"))
+	mock2.ExpectQuery(positions).WillReturnRows(sqlmock.NewRows(columns).AddRow("2", "0-2-101", "0-1-100", "0-2-101"))
+	statements, status, _ := mariadbSlavePosStatements("10.11.6-MariaDB-log", "orders", MariadbGtidList{{Domain: 0, ServerID: 1, Sequence: 100}})
+	for _, stmt := range statements {
+		mock2.ExpectExec(regexp.QuoteMeta(stmt)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock2.ExpectQuery(regexp.QuoteMeta(status)).WillReturnRows(sqlmock.NewRows([]string{"Slave_IO_Running", "Slave_SQL_Running"}).AddRow("Yes", "Yes"))
+
+	var out bytes.Buffer
+	outcome, err := CheckGtidSetOutcome(context.Background(), db1, db2, "source", "target", Options{FixReplica: true, AssumeYes: true, Channel: "orders", Output: &out})
+	if err != nil {
+		t.Fatalf("CheckGtidSetOutcome failed: %v", err)
+	}
+	if outcome != Unresolved {
+		t.Errorf("expected the realigned replica to stay %q, got %q", Unresolved, outcome)
+	}
+	if !strings.Contains(out.String(), "errant events are still in its gtid_current_pos") {
+		t.Errorf("expected the output to say the errant events remain:\n%s", out.String())
+	}
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	}
+}
+
+func TestCheckGtidSetOutcome_RefusesMixedFlavors(t *testing.T) {
+	db1, mock1, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db1.Close()
+	db2, mock2, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db2.Close()
+
+	mock1.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+	mock2.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("10.11.6-MariaDB"))
+
+	if _, err := CheckGtidSetOutcome(context.Background(), db1, db2, "source", "target", Options{}); err == nil {
+		t.Error("expected an error comparing MySQL with MariaDB")
+	}
+}
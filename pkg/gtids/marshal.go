@@ -0,0 +1,174 @@
+package gtids
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Text and JSON forms of GTID sets, so they can be embedded in config files,
+// API payloads and test fixtures.
+//
+// OracleGtidSet and OracleGtidSetEntry marshal to their MySQL text form,
+// e.g. "uuid:1-5:11", which keeps entries and ranges as given. GtidSet
+// marshals to the structured form, an object mapping each UUID (or
+// "uuid:tag") to its [start, end] intervals:
+//
+//	{"1d1fff5a-c9bc-11ed-9c19-02a36d996b94": [[1, 5], [11, 11]]}
+//
+// OracleGtidSet.MarshalStructuredJSON writes an OracleGtidSet in the
+// structured form, merged and sorted. Every UnmarshalJSON accepts either form.
+//
+// The marshal methods take values, so sets held by value in structs, slices
+// and maps marshal the same as pointers to them.
+
+// MarshalText returns the entry in MySQL's text form.
+func (oge OracleGtidSetEntry) MarshalText() ([]byte, error) {
+	return []byte(oge.String()), nil
+}
+
+// UnmarshalText parses a single entry such as "uuid:1-5:11".
+func (oge *OracleGtidSetEntry) UnmarshalText(text []byte) error {
+	entry, err := NewOracleGtidSetEntry(string(text))
+	if err != nil {
+		return err
+	}
+	*oge = *entry
+	return nil
+}
+
+// MarshalText returns the set in MySQL's text form, entries in their current order.
+func (ogs OracleGtidSet) MarshalText() ([]byte, error) {
+	return []byte(ogs.String()), nil
+}
+
+// UnmarshalText parses a GTID set such as the value of @@GLOBAL.gtid_executed.
+func (ogs *OracleGtidSet) UnmarshalText(text []byte) error {
+	parsed, err := NewOracleGtidSet(string(text))
+	if err != nil {
+		return err
+	}
+	ogs.GtidEntries = parsed.GtidEntries
+	return nil
+}
+
+// MarshalJSON renders the set as a JSON string in MySQL's text form, entries
+// in their current order.
+func (ogs OracleGtidSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ogs.String())
+}
+
+// MarshalStructuredJSON renders the set in the structured form
+// ({"uuid": [[1, 5]]}), merged and in canonical order.
+func (ogs OracleGtidSet) MarshalStructuredJSON() ([]byte, error) {
+	gtidSet, err := ogs.GtidSet()
+	if err != nil {
+		return nil, err
+	}
+	return gtidSet.MarshalJSON()
+}
+
+// UnmarshalJSON accepts the text form ("uuid:1-5") or the structured form
+// ({"uuid": [[1, 5]]}); the latter is read in canonical form.
+func (ogs *OracleGtidSet) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(trimmed, []byte("null")):
+		return nil
+	case bytes.HasPrefix(trimmed, []byte(`"`)):
+		var text string
+		if err := json.Unmarshal(trimmed, &text); err != nil {
+			return err
+		}
+		return ogs.UnmarshalText([]byte(text))
+	}
+	var gtidSet GtidSet
+	if err := gtidSet.UnmarshalJSON(trimmed); err != nil {
+		return err
+	}
+	ogs.GtidEntries = gtidSet.OracleGtidSet().GtidEntries
+	return nil
+}
+
+// MarshalJSON renders the interval as [start, end].
+func (i Interval) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]int64{i.Start, i.End})
+}
+
+// UnmarshalJSON reads an interval written as [start, end].
+func (i *Interval) UnmarshalJSON(data []byte) error {
+	var bounds []int64
+	if err := json.Unmarshal(data, &bounds); err != nil {
+		return fmt.Errorf("interval must be [start, end]: %w", err)
+	}
+	if len(bounds) != 2 {
+		return fmt.Errorf("interval must be [start, end], got %d values", len(bounds))
+	}
+	i.Start, i.End = bounds[0], bounds[1]
+	return nil
+}
+
+// MarshalText returns the set in MySQL's canonical text form.
+func (s GtidSet) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses a GTID set such as the value of @@GLOBAL.gtid_executed.
+func (s *GtidSet) UnmarshalText(text []byte) error {
+	parsed, err := NewGtidSet(string(text))
+	if err != nil {
+		return err
+	}
+	*s = *parsed
+	return nil
+}
+
+// MarshalJSON renders the set in the structured form, keys in sorted order.
+func (s GtidSet) MarshalJSON() ([]byte, error) {
+	intervals := s.intervals
+	if intervals == nil {
+		intervals = map[string][]Interval{}
+	}
+	return json.Marshal(intervals)
+}
+
+// UnmarshalJSON accepts the text form ("uuid:1-5") or the structured form
+// ({"uuid": [[1, 5]]}). Structured intervals are validated like parsed ones:
+// transaction numbers must be 1..9223372036854775806 and start <= end.
+func (s *GtidSet) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(trimmed, []byte("null")):
+		return nil
+	case bytes.HasPrefix(trimmed, []byte(`"`)):
+		var text string
+		if err := json.Unmarshal(trimmed, &text); err != nil {
+			return err
+		}
+		return s.UnmarshalText([]byte(text))
+	}
+
+	var structured map[string][]Interval
+	if err := json.Unmarshal(trimmed, &structured); err != nil {
+		return fmt.Errorf("GTID set must be a string or an object of intervals: %w", err)
+	}
+	res := &GtidSet{intervals: map[string][]Interval{}}
+	for key, intervals := range structured {
+		uuid, tag, tagged := strings.Cut(key, ":")
+		if strings.TrimSpace(uuid) == "" || strings.ContainsAny(uuid, ", \t\n") {
+			return fmt.Errorf("unexpected UUID: %q", uuid)
+		}
+		if tagged && !gtidTagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q for %s", tag, uuid)
+		}
+		for _, interval := range intervals {
+			if interval.Start < 1 || interval.End > maxGno || interval.Start > interval.End {
+				return fmt.Errorf("invalid interval [%d, %d] for %s", interval.Start, interval.End, key)
+			}
+		}
+		res.add(gtidSetKey(uuid, tag), normalizeIntervals(intervals))
+	}
+	*s = *res
+	return nil
+}
@@ -0,0 +1,189 @@
+package gtids
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOracleGtidSet_JSONRoundTrip(t *testing.T) {
+	type config struct {
+		Executed *OracleGtidSet      `json:"executed"`
+		Entry    *OracleGtidSetEntry `json:"entry"`
+	}
+	executed, _ := NewOracleGtidSet(uuidA + ":1-5:11," + uuidB + ":1-3:mytag:7")
+	entry, _ := NewOracleGtidSetEntry(uuidA + ":1-5")
+
+	data, err := json.Marshal(config{Executed: executed, Entry: entry})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `{"executed":"` + uuidA + `:1-5:11,` + uuidB + `:1-3:mytag:7","entry":"` + uuidA + `:1-5"}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	var decoded config
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Executed.String() != executed.String() || decoded.Entry.String() != entry.String() {
+		t.Errorf("round trip mismatch: %q / %q", decoded.Executed, decoded.Entry)
+	}
+
+	// The text form keeps entries as given, where a GtidSet would merge them.
+	unmerged, _ := NewOracleGtidSet(uuidB + ":5-6," + uuidA + ":3:1-2")
+	data, err = json.Marshal(unmerged)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if expected := `"` + uuidB + `:5-6,` + uuidA + `:3:1-2"`; string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+}
+
+func TestGtidSet_JSONStructuredForm(t *testing.T) {
+	set := mustGtidSet(t, uuidB+":1-3,"+uuidA+":1-5:11:mytag:7")
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `{"` + uuidA + `":[[1,5],[11,11]],"` + uuidA + `:mytag":[[7,7]],"` + uuidB + `":[[1,3]]}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	var decoded GtidSet
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !decoded.Equal(set) {
+		t.Errorf("round trip mismatch: %q", decoded.String())
+	}
+
+	if data, _ := json.Marshal(mustGtidSet(t, "")); string(data) != "{}" {
+		t.Errorf("empty set should marshal to {}, got %s", data)
+	}
+}
+
+func TestGtidSet_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		hasError bool
+	}{
+		{name: "text form", input: `"` + uuidA + `:1-3:4-5"`, expected: uuidA + ":1-5"},
+		{name: "structured form is normalized", input: `{"1D1FFF5A-C9BC-11ED-9C19-02A36D996B94": [[4, 5], [1, 3]]}`, expected: uuidA + ":1-5"},
+		{name: "structured tag", input: `{"` + uuidA + `:MyTag": [[2, 2]]}`, expected: uuidA + ":mytag:2"},
+		{name: "empty object", input: `{}`, expected: ""},
+		{name: "reversed interval", input: `{"` + uuidA + `": [[5, 3]]}`, hasError: true},
+		{name: "transaction number zero", input: `{"` + uuidA + `": [[0, 3]]}`, hasError: true},
+		{name: "interval with one bound", input: `{"` + uuidA + `": [[3]]}`, hasError: true},
+		{name: "invalid tag", input: `{"` + uuidA + `:-bad": [[1, 1]]}`, hasError: true},
+		{name: "malformed text form", input: `"` + uuidA + `:abc"`, hasError: true},
+		{name: "number", input: `42`, hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var set GtidSet
+			err := json.Unmarshal([]byte(tt.input), &set)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("expected error but got none (set %q)", set.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := set.String(); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+
+			// OracleGtidSet accepts the same inputs.
+			var oracle OracleGtidSet
+			if err := json.Unmarshal([]byte(tt.input), &oracle); err != nil {
+				t.Fatalf("OracleGtidSet: unexpected error: %v", err)
+			}
+			if normalized, _ := NormalizeGtidSet(oracle.String()); normalized != tt.expected {
+				t.Errorf("OracleGtidSet: expected %q, got %q", tt.expected, normalized)
+			}
+		})
+	}
+}
+
+func TestGtidSet_TextMarshaling(t *testing.T) {
+	var set GtidSet
+	if err := set.UnmarshalText([]byte(uuidB + ":2," + uuidA + ":1")); err != nil {
+		t.Fatalf("UnmarshalText failed: %v", err)
+	}
+	text, _ := set.MarshalText()
+	if string(text) != uuidA+":1,"+uuidB+":2" {
+		t.Errorf("unexpected text %q", text)
+	}
+
+	var entry OracleGtidSetEntry
+	if err := entry.UnmarshalText([]byte(uuidA + ":1-2:a:3")); err == nil {
+		t.Error("expected an error for an entry with more than one tag group")
+	}
+}
+
+func TestMarshal_ValuesInStructsAndMaps(t *testing.T) {
+	type config struct {
+		Executed OracleGtidSet        `json:"executed"`
+		Entry    OracleGtidSetEntry   `json:"entry"`
+		Set      GtidSet              `json:"set"`
+		ByHost   map[string]GtidSet   `json:"by_host"`
+		Entries  []OracleGtidSetEntry `json:"entries"`
+	}
+	executed, _ := NewOracleGtidSet(uuidA + ":1-5")
+	entry, _ := NewOracleGtidSetEntry(uuidB + ":3")
+	set := mustGtidSet(t, uuidA+":1-2")
+
+	data, err := json.Marshal(config{
+		Executed: *executed,
+		Entry:    *entry,
+		Set:      *set,
+		ByHost:   map[string]GtidSet{"replica1": *set},
+		Entries:  []OracleGtidSetEntry{*entry},
+	})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `{"executed":"` + uuidA + `:1-5","entry":"` + uuidB + `:3","set":{"` + uuidA + `":[[1,2]]},` +
+		`"by_host":{"replica1":{"` + uuidA + `":[[1,2]]}},"entries":["` + uuidB + `:3"]}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	var decoded config
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	byHost := decoded.ByHost["replica1"]
+	if decoded.Executed.String() != executed.String() || decoded.Entry.String() != entry.String() ||
+		!decoded.Set.Equal(set) || !byHost.Equal(set) {
+		t.Errorf("round trip mismatch: %+v", decoded)
+	}
+}
+
+func TestOracleGtidSet_MarshalStructuredJSON(t *testing.T) {
+	ogs, _ := NewOracleGtidSet(uuidB + ":5-6," + uuidA + ":3:1-2:mytag:7")
+	data, err := ogs.MarshalStructuredJSON()
+	if err != nil {
+		t.Fatalf("MarshalStructuredJSON failed: %v", err)
+	}
+	expected := `{"` + uuidA + `":[[1,3]],"` + uuidA + `:mytag":[[7,7]],"` + uuidB + `":[[5,6]]}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	var decoded OracleGtidSet
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if expected := uuidA + ":1-3:mytag:7," + uuidB + ":5-6"; decoded.String() != expected {
+		t.Errorf("expected %s, got %s", expected, decoded.String())
+	}
+}
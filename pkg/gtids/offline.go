@@ -0,0 +1,46 @@
+package gtids
+
+import (
+	"fmt"
+	"io"
+)
+
+// CheckGtidSetsOffline compares two GTID sets without any database connection
+// and writes the same errant/missing report as CheckGtidSetOutcome to w. source
+// and target only label the output. Missing transactions are reported for context
+// but, as in CheckGtidSetOutcome's check mode, only errant ones leave the sets
+// Unresolved — a replica that is merely behind is not drift. Without the
+// target's binary logs errant transactions cannot be classified, so the
+// outcome is never ErrantWithoutDataChanges.
+func CheckGtidSetsOffline(w io.Writer, source, target, sourceGtidSet, targetGtidSet string) (outcome Outcome, err error) {
+	sourceSet, err := NewGtidSetStrict(sourceGtidSet)
+	if err != nil {
+		return InSync, fmt.Errorf("failed to parse source GTID set: %w", err)
+	}
+	targetSet, err := NewGtidSetStrict(targetGtidSet)
+	if err != nil {
+		return InSync, fmt.Errorf("failed to parse target GTID set: %w", err)
+	}
+
+	fmt.Fprintln(w, blue("[+]"), "Source ->", source, "gtid_executed:", sourceSet)
+	fmt.Fprintln(w, yellow("[+]"), "Target ->", target, "gtid_executed:", targetSet)
+	printGtidSetGaps(w, "source", sourceSet)
+	printGtidSetGaps(w, "target", targetSet)
+
+	errant := targetSet.Subtract(sourceSet)
+	if errant.IsEmpty() {
+		fmt.Fprintln(w, green("[+]"), "No Errant Transactions:", errant)
+	} else {
+		outcome = Unresolved
+		fmt.Fprintln(w, red("[-]"), errant.Summary("errant"))
+		fmt.Fprintln(w, red("[-]"), "Errant Transactions:", errant)
+	}
+
+	if missing := sourceSet.Subtract(targetSet); missing.IsEmpty() {
+		fmt.Fprintln(w, green("[+]"), "No Missing GTIDs")
+	} else {
+		fmt.Fprintln(w, red("[-]"), missing.Summary("missing"))
+		fmt.Fprintln(w, red("[-]"), "Missing GTIDs:", missing)
+	}
+	return outcome, nil
+}
@@ -0,0 +1,46 @@
+package gtids
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCheckGtidSetsOffline(t *testing.T) {
+	tests := []struct {
+		name           string
+		source, target string
+		outcome        Outcome
+		report         string // a line the report must contain
+		hasError       bool
+	}{
+		{name: "in sync", source: uuidA + ":1-10", target: uuidA + ":1-10", report: "No Errant Transactions"},
+		{name: "replica behind is not drift", source: uuidA + ":1-10", target: uuidA + ":1-5", report: "Missing GTIDs: " + uuidA + ":6-10"},
+		{name: "errant on replica", source: uuidA + ":1-10", target: uuidA + ":1-10," + uuidB + ":1", outcome: Unresolved, report: "Errant Transactions: " + uuidB + ":1"},
+		{name: "both errant and missing", source: uuidA + ":1-10", target: uuidA + ":1-5:11", outcome: Unresolved, report: "Gaps in target gtid_executed: " + uuidA + ":6-10"},
+		{name: "malformed source", source: "garbage", target: uuidA + ":1", hasError: true},
+		{name: "malformed target", source: uuidA + ":1", target: uuidA + ":x", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			outcome, err := CheckGtidSetsOffline(&out, "source", "target", tt.source, tt.target)
+			if tt.hasError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if outcome != tt.outcome {
+				t.Errorf("expected outcome %q, got %q", tt.outcome, outcome)
+			}
+			if !strings.Contains(out.String(), tt.report) {
+				t.Errorf("expected the report to contain %q, got:\n%s", tt.report, out.String())
+			}
+		})
+	}
+}
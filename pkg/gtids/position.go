@@ -0,0 +1,112 @@
+package gtids
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BinlogPosition is a file:position coordinate in a server's binary logs, as
+// used by CHANGE REPLICATION SOURCE TO without auto-positioning.
+type BinlogPosition struct {
+	File string
+	Pos  uint64
+}
+
+// String renders the position as file:pos.
+func (p BinlogPosition) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Pos)
+}
+
+// ParseBinlogPosition parses "file:pos", e.g. "binlog.000042:1234".
+func ParseBinlogPosition(s string) (BinlogPosition, error) {
+	file, posText, found := strings.Cut(strings.TrimSpace(s), ":")
+	if !found || !binlogFilePattern.MatchString(file) {
+		return BinlogPosition{}, fmt.Errorf("invalid binary log position %q: expected file:pos", s)
+	}
+	pos, err := strconv.ParseUint(posText, 10, 64)
+	if err != nil || pos < 4 {
+		return BinlogPosition{}, fmt.Errorf("invalid binary log position %q: pos must be a number of at least 4", s)
+	}
+	return BinlogPosition{File: file, Pos: pos}, nil
+}
+
+// GtidPosition returns where the transaction gtid ("uuid[:tag]:gno") starts in
+// db's binary logs: the coordinates to replicate from so that it is the next
+// transaction executed.
+func GtidPosition(ctx context.Context, db *sql.DB, gtid string) (BinlogPosition, error) {
+	if _, _, ok := splitGtid(gtid); !ok {
+		return BinlogPosition{}, fmt.Errorf("invalid GTID %q: expected uuid[:tag]:gno", gtid)
+	}
+	set, err := NewGtidSet(gtid)
+	if err != nil {
+		return BinlogPosition{}, fmt.Errorf("invalid GTID %q: %w", gtid, err)
+	}
+	locations, err := LocateGtids(ctx, db, set)
+	if err != nil {
+		return BinlogPosition{}, err
+	}
+	switch {
+	case !locations.Purged.IsEmpty():
+		return BinlogPosition{}, fmt.Errorf("%s was purged from the binary logs", gtid)
+	case len(locations.Transactions) == 0:
+		return BinlogPosition{}, fmt.Errorf("%s is not in the binary logs (not executed on this server, or added with SET GLOBAL gtid_purged)", gtid)
+	}
+	tx := locations.Transactions[0]
+	return BinlogPosition{File: tx.File, Pos: tx.StartPos}, nil
+}
+
+// GtidSetAtPosition returns the GTID set db had executed once it had written
+// its binary logs up to pos: the file's Previous_gtids plus the transactions
+// that end at or before pos. pos must lie between transactions, and must not
+// be past what the server has written so far.
+func GtidSetAtPosition(ctx context.Context, db *sql.DB, pos BinlogPosition) (*GtidSet, error) {
+	files, err := listBinaryLogs(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(files, pos.File) {
+		return nil, fmt.Errorf("%s is not one of the server's binary logs (purged, or from another server?)", pos.File)
+	}
+	current, err := getBinaryLogInfo(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if pos.File == current.File && pos.Pos > current.Pos {
+		return nil, fmt.Errorf("%s is past the end of the binary logs (currently %s)", pos, current)
+	}
+
+	executed, err := previousGtids(ctx, db, pos.File)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string][]Interval{}
+	var inside *BinlogTransaction
+	err = scanBinlogTransactions(ctx, db, pos.File, func(tx *BinlogTransaction) bool {
+		if tx.StartPos >= pos.Pos {
+			return false
+		}
+		if tx.EndPos > pos.Pos {
+			inside = tx
+			return false
+		}
+		if key, gno, ok := splitGtid(tx.GTID); ok {
+			found[key] = append(found[key], Interval{Start: gno, End: gno})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if inside != nil {
+		return nil, fmt.Errorf("%s is inside transaction %s (%s), not between transactions", pos, inside.GTID, inside.Location())
+	}
+	contained := &GtidSet{intervals: map[string][]Interval{}}
+	for key, intervals := range found {
+		contained.add(key, normalizeIntervals(intervals))
+	}
+	return executed.Union(contained), nil
+}
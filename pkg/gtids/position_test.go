@@ -0,0 +1,146 @@
+package gtids
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParseBinlogPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"binlog.000042:1234", "binlog.000042:1234", true},
+		{" mysql-bin.000001:4\n", "mysql-bin.000001:4", true},
+		{"binlog.000042", "", false},
+		{"binlog.000042:", "", false},
+		{"binlog.000042:3", "", false},
+		{"binlog.000042:-1", "", false},
+		{"bin'log:1234", "", false},
+	}
+	for _, tt := range tests {
+		pos, err := ParseBinlogPosition(tt.input)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseBinlogPosition(%q): expected valid=%v, got error %v", tt.input, tt.valid, err)
+			continue
+		}
+		if tt.valid && pos.String() != tt.expected {
+			t.Errorf("ParseBinlogPosition(%q) = %q, expected %q", tt.input, pos, tt.expected)
+		}
+	}
+}
+
+// expectTwoBinaryLogs mocks SHOW BINARY LOGS listing binlog.000001 and
+// binlog.000002.
+func expectTwoBinaryLogs(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SHOW BINARY LOGS").WillReturnRows(sqlmock.NewRows([]string{"Log_name", "File_size"}).
+		AddRow("binlog.000001", 1000).
+		AddRow("binlog.000002", 1010))
+}
+
+// secondBinaryLog holds uuidA:14-16 after uuidA:1-10.
+func secondBinaryLog() [][]any {
+	events := append(headerEvents(uuidA+":1-10"), gtidEvents(197, uuidA+":14")...)
+	events = append(events, gtidEvents(468, uuidA+":15")...)
+	return append(events, gtidEvents(739, uuidA+":16")...)
+}
+
+func TestGtidPosition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	expectTwoBinaryLogs(mock)
+	expectBinlogEvents(mock, "binlog.000001", " LIMIT 3", headerEvents(uuidA+":1-3")...)
+	expectBinlogEvents(mock, "binlog.000002", " LIMIT 3", headerEvents(uuidA+":1-10")...)
+	expectBinlogEvents(mock, "binlog.000002", "", secondBinaryLog()...)
+
+	pos, err := GtidPosition(context.Background(), db, uuidA+":15")
+	if err != nil {
+		t.Fatalf("GtidPosition failed: %v", err)
+	}
+	if pos.String() != "binlog.000002:468" {
+		t.Errorf("expected binlog.000002:468, got %s", pos)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestGtidPosition_Purged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	expectTwoBinaryLogs(mock)
+	expectBinlogEvents(mock, "binlog.000001", " LIMIT 3", headerEvents(uuidA+":1-3")...)
+
+	_, err = GtidPosition(context.Background(), db, uuidA+":2")
+	if err == nil || !strings.Contains(err.Error(), "purged") {
+		t.Errorf("expected a purged error, got %v", err)
+	}
+	if _, err := GtidPosition(context.Background(), db, uuidA); err == nil {
+		t.Error("expected a GTID without a transaction number to be rejected")
+	}
+}
+
+func TestGtidSetAtPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		pos      BinlogPosition
+		expected string
+		error    string
+	}{
+		{name: "between transactions", pos: BinlogPosition{"binlog.000002", 739}, expected: uuidA + ":1-10:14-15"},
+		{name: "before the first transaction", pos: BinlogPosition{"binlog.000002", 197}, expected: uuidA + ":1-10"},
+		{name: "end of the file", pos: BinlogPosition{"binlog.000002", 1010}, expected: uuidA + ":1-10:14-16"},
+		{name: "inside a transaction", pos: BinlogPosition{"binlog.000002", 500}, error: "inside transaction " + uuidA + ":15"},
+		{name: "past the end", pos: BinlogPosition{"binlog.000002", 2000}, error: "past the end"},
+		{name: "unknown file", pos: BinlogPosition{"binlog.000009", 4}, error: "not one of the server's binary logs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			expectTwoBinaryLogs(mock)
+			if tt.pos.File == "binlog.000002" {
+				mock.ExpectQuery("SHOW BINARY LOG STATUS").WillReturnRows(sqlmock.NewRows([]string{"File", "Position", "Executed_Gtid_Set"}).
+					AddRow("binlog.000002", 1010, uuidA+":1-16"))
+			}
+			if tt.pos.Pos <= 1010 {
+				expectBinlogEvents(mock, "binlog.000002", " LIMIT 3", headerEvents(uuidA+":1-10")...)
+				expectBinlogEvents(mock, "binlog.000002", "", secondBinaryLog()...)
+			}
+
+			set, err := GtidSetAtPosition(context.Background(), db, tt.pos)
+			if tt.error != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.error) {
+					t.Fatalf("expected an error containing %q, got %v", tt.error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GtidSetAtPosition failed: %v", err)
+			}
+			if set.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, set)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
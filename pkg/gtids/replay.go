@@ -0,0 +1,467 @@
+package gtids
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxReplayRows caps the rows translated per errant transaction;
+// larger changes are better reconciled with a data diff.
+const maxReplayRows = 1000
+
+// replayColumn is a column of a replayed table, from the target's
+// information_schema.
+type replayColumn struct {
+	name      string
+	unsigned  bool
+	generated bool
+	primary   bool
+}
+
+// replayTable is a table whose row changes are translated to SQL.
+type replayTable struct {
+	name    string // `schema`.`table`
+	columns []replayColumn
+}
+
+// replayPlan is the SQL that redoes (on the source, under its own GTID) or
+// reverts (on the target) one errant transaction's row changes.
+type replayPlan struct {
+	GTID       string
+	Location   string
+	Statements []string
+}
+
+// quoteIdentifier quotes a schema, table or column name with backticks.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// loadReplayTable reads a table's columns, in binlog order, from the target.
+func loadReplayTable(ctx context.Context, db *sql.DB, schema, table string) (*replayTable, error) {
+	rows, err := db.QueryContext(ctx, "SELECT COLUMN_NAME, COLUMN_TYPE, COLUMN_KEY, EXTRA FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s.%s: %w", schema, table, err)
+	}
+	defer rows.Close()
+
+	res := &replayTable{name: quoteIdentifier(schema) + "." + quoteIdentifier(table)}
+	for rows.Next() {
+		var name, columnType, key, extra string
+		if err := rows.Scan(&name, &columnType, &key, &extra); err != nil {
+			return nil, err
+		}
+		res.columns = append(res.columns, replayColumn{
+			name:      name,
+			unsigned:  strings.Contains(strings.ToLower(columnType), "unsigned"),
+			generated: strings.Contains(strings.ToUpper(extra), "GENERATED"),
+			primary:   key == "PRI",
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(res.columns) == 0 {
+		return nil, fmt.Errorf("table %s.%s does not exist on the target", schema, table)
+	}
+	return res, nil
+}
+
+// renderSQLValue renders a decoded column value as a SQL literal. Strings of
+// printable ASCII are quoted for readability; anything else is a hex literal,
+// which keeps the exact bytes whatever the column's character set.
+func renderSQLValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case sqlLiteral:
+		return string(v), nil
+	case []byte:
+		for _, c := range v {
+			if c < 0x20 || c > 0x7e || c == '\\' {
+				return "X'" + hex.EncodeToString(v) + "'", nil
+			}
+		}
+		return "'" + strings.ReplaceAll(string(v), "'", "''") + "'", nil
+	case unsupportedValue:
+		if v.columnType == mysqlTypeJSON {
+			return "", fmt.Errorf("JSON values cannot be replayed")
+		}
+		return "", fmt.Errorf("spatial values cannot be replayed")
+	}
+	return "", fmt.Errorf("unexpected value %T", value)
+}
+
+// assignments renders the columns of an after image as names and values.
+// Generated columns are left for the server to compute.
+func (t *replayTable) assignments(image *rowImage) (names, values []string, err error) {
+	for i, column := range t.columns {
+		if !image.present[i] || column.generated {
+			continue
+		}
+		value, err := renderSQLValue(image.values[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("column %s: %w", column.name, err)
+		}
+		names = append(names, quoteIdentifier(column.name))
+		values = append(values, value)
+	}
+	return names, values, nil
+}
+
+// where renders the condition matching the row of a before image. Every
+// column that can be compared exactly is matched, so the statement only
+// applies to a row exactly as the errant transaction found (or left) it. Floating-point
+// and JSON or spatial values cannot be compared exactly; when any is skipped,
+// or the image is not complete (binlog_row_image=MINIMAL), the condition must
+// include the whole primary key to identify a single row.
+func (t *replayTable) where(image *rowImage) (string, error) {
+	var conditions []string
+	complete, hasKey, keyMatched := true, false, true
+	for i, column := range t.columns {
+		if column.primary {
+			hasKey = true
+		}
+		if !image.present[i] {
+			complete = false
+			keyMatched = keyMatched && !column.primary
+			continue
+		}
+		if column.generated {
+			continue
+		}
+		switch image.values[i].(type) {
+		case float32, float64, unsupportedValue:
+			complete = false
+			keyMatched = keyMatched && !column.primary
+			continue
+		}
+		value, err := renderSQLValue(image.values[i])
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, quoteIdentifier(column.name)+" <=> "+value)
+	}
+	if !complete && (!hasKey || !keyMatched) {
+		return "", fmt.Errorf("cannot identify the changed row of %s: its before image is incomplete or has inexact values, and does not include a primary key", t.name)
+	}
+	if len(conditions) == 0 {
+		return "", fmt.Errorf("cannot identify the changed row of %s", t.name)
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// statement translates one row change to an INSERT, UPDATE or DELETE that
+// affects exactly one row.
+func (t *replayTable) statement(change rowChange) (string, error) {
+	switch {
+	case change.before == nil:
+		names, values, err := t.assignments(change.after)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.name, strings.Join(names, ", "), strings.Join(values, ", ")), nil
+	case change.after == nil:
+		where, err := t.where(change.before)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT 1", t.name, where), nil
+	default:
+		names, values, err := t.assignments(change.after)
+		if err != nil {
+			return "", err
+		}
+		where, err := t.where(change.before)
+		if err != nil {
+			return "", err
+		}
+		set := make([]string, len(names))
+		for i := range names {
+			set[i] = names[i] + " = " + values[i]
+		}
+		return fmt.Sprintf("UPDATE %s SET %s WHERE %s LIMIT 1", t.name, strings.Join(set, ", "), where), nil
+	}
+}
+
+// undoStatement translates one row change to the statement that reverts it:
+// a DELETE of an inserted row, an INSERT of a deleted one, or an UPDATE back to
+// the before image. Restoring needs the complete before image, so tables
+// logged with binlog_row_image=MINIMAL cannot be reverted.
+func (t *replayTable) undoStatement(change rowChange) (string, error) {
+	if change.before != nil && slices.Contains(change.before.present, false) {
+		return "", fmt.Errorf("cannot restore a row of %s: its before image is incomplete (binlog_row_image is not FULL)", t.name)
+	}
+	switch {
+	case change.before == nil:
+		return t.statement(rowChange{before: change.after})
+	case change.after == nil:
+		return t.statement(rowChange{after: change.before})
+	default:
+		return t.statement(rowChange{before: change.after, after: change.before})
+	}
+}
+
+// planReplay translates the events of an errant transaction to SQL that
+// redoes its row changes or, with undo, reverts them (newest first). Only
+// row-based changes are translated: statements (DDL, or DML logged in
+// statement format, whose result may not be deterministic) are refused, as is
+// anything else that cannot be reproduced exactly. tables caches the target's
+// table definitions across transactions.
+func planReplay(ctx context.Context, db *sql.DB, tx *BinlogTransaction, events []*DecodedEvent, tables map[string]*replayTable, undo bool) (*replayPlan, error) {
+	plan := &replayPlan{GTID: tx.GTID, Location: tx.Location()}
+	verb := "replayed"
+	if undo {
+		verb = "undone"
+	}
+	tableMaps := map[uint64]*TableMapEvent{}
+	rows := 0
+	for _, event := range events {
+		switch data := event.Data.(type) {
+		case *GtidEvent:
+			if !strings.EqualFold(data.GTID(), tx.GTID) {
+				return nil, fmt.Errorf("the binary log holds %s at %d, not %s (is it the target's?)", data.GTID(), event.Pos, tx.GTID)
+			}
+		case *QueryEvent:
+			switch strings.ToUpper(strings.TrimSpace(data.Query)) {
+			case "BEGIN", "COMMIT":
+				continue
+			}
+			return nil, fmt.Errorf("%s statement %q cannot be %s: only row-based changes can", classifyStatement(data.Query), data.Query, verb)
+		case *TableMapEvent:
+			tableMaps[data.TableID] = data
+		case *RowsEvent:
+			if data.Type == PartialUpdateRowsEventType {
+				return nil, fmt.Errorf("partial JSON updates (binlog_row_value_options=PARTIAL_JSON) cannot be %s", verb)
+			}
+			tm, ok := tableMaps[data.TableID]
+			if !ok {
+				return nil, fmt.Errorf("%s event for unknown table id %d", data.Type, data.TableID)
+			}
+			key := tm.Schema + "." + tm.Table
+			table, ok := tables[key]
+			if !ok {
+				var err error
+				if table, err = loadReplayTable(ctx, db, tm.Schema, tm.Table); err != nil {
+					return nil, err
+				}
+				tables[key] = table
+			}
+			if len(table.columns) != len(tm.columnTypes) {
+				return nil, fmt.Errorf("%s has %d columns on the target but %d in the binary log (altered since?)", table.name, len(table.columns), len(tm.columnTypes))
+			}
+			unsigned := make([]bool, len(table.columns))
+			for i, column := range table.columns {
+				unsigned[i] = column.unsigned
+			}
+			changes, err := data.decodeRows(tm, unsigned)
+			if err != nil {
+				return nil, fmt.Errorf("%s event at %d: %w", data.Type, event.Pos, err)
+			}
+			if rows += len(changes); rows > maxReplayRows {
+				return nil, fmt.Errorf("more than %d rows changed; reconcile the data with a diff instead", maxReplayRows)
+			}
+			for _, change := range changes {
+				statement, err := table.statement(change)
+				if undo {
+					statement, err = table.undoStatement(change)
+				}
+				if err != nil {
+					return nil, err
+				}
+				plan.Statements = append(plan.Statements, statement)
+			}
+		case *RowsQueryEvent, *XidEvent:
+		case *TransactionPayloadEvent:
+			return nil, fmt.Errorf("compressed transactions (binlog_transaction_compression=ON) cannot be %s", verb)
+		case *UndecodedGtidEvent:
+			return nil, fmt.Errorf("its Gtid_tagged event at %d could not be decoded: %w", event.Pos, data.Err)
+		default:
+			return nil, fmt.Errorf("%s events cannot be %s", event.Header.Type, verb)
+		}
+	}
+	if undo {
+		slices.Reverse(plan.Statements)
+	}
+	return plan, nil
+}
+
+// printReplayPlans prints the statements applyReplayPlans would execute.
+func printReplayPlans(w io.Writer, plans []*replayPlan) {
+	fmt.Fprintln(w, "    SET SESSION time_zone = '+00:00';")
+	for _, plan := range plans {
+		fmt.Fprintf(w, "    -- %s from %s\n", plan.GTID, plan.Location)
+		fmt.Fprintf(w, "    SET GTID_NEXT='%s'; BEGIN;\n", plan.GTID)
+		for _, statement := range plan.Statements {
+			fmt.Fprintf(w, "    %s;\n", statement)
+		}
+		fmt.Fprintln(w, "    COMMIT;")
+	}
+	fmt.Fprintln(w, "    SET GTID_NEXT='AUTOMATIC';")
+	fmt.Fprintln(w, "    SET SESSION time_zone = DEFAULT;")
+}
+
+// applyReplayPlans runs each plan as one transaction under its GTID on a
+// single pinned connection. Every statement must affect exactly one row, or
+// the transaction is rolled back: the source's data differs from what the
+// target changed. GTID_NEXT is always reset to AUTOMATIC, even on failure.
+func applyReplayPlans(ctx context.Context, w io.Writer, db *sql.DB, plans []*replayPlan) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	return withUTCTimeZone(ctx, conn, func() (err error) {
+		defer func() {
+			if _, resetErr := conn.ExecContext(context.WithoutCancel(ctx), "SET GTID_NEXT='AUTOMATIC'"); resetErr != nil && err == nil {
+				err = fmt.Errorf("failed to reset GTID_NEXT to AUTOMATIC: %w", resetErr)
+			}
+		}()
+		for _, plan := range plans {
+			if err := applyReplayPlan(ctx, conn, plan); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Replayed %s on source: %d statement(s)\n", plan.GTID, len(plan.Statements))
+		}
+		return nil
+	})
+}
+
+// withUTCTimeZone runs fn with the session time zone set to UTC, in which
+// TIMESTAMP values are rendered, and resets it afterwards, even on failure.
+func withUTCTimeZone(ctx context.Context, conn *sql.Conn, fn func() error) (err error) {
+	if _, err := conn.ExecContext(ctx, "SET SESSION time_zone = '+00:00'"); err != nil {
+		return fmt.Errorf("failed to set the session time zone: %w", err)
+	}
+	defer func() {
+		if _, resetErr := conn.ExecContext(context.WithoutCancel(ctx), "SET SESSION time_zone = DEFAULT"); resetErr != nil && err == nil {
+			err = fmt.Errorf("failed to reset the session time zone: %w", resetErr)
+		}
+	}()
+	return fn()
+}
+
+// applyReplayPlan runs one plan as a transaction under its GTID.
+func applyReplayPlan(ctx context.Context, conn *sql.Conn, plan *replayPlan) error {
+	if !gtidEntryPattern.MatchString(plan.GTID) {
+		return fmt.Errorf("refusing to apply invalid GTID entry %q", plan.GTID)
+	}
+	if _, err := conn.ExecContext(ctx, "SET GTID_NEXT='"+plan.GTID+"'"); err != nil {
+		return fmt.Errorf("failed to set GTID_NEXT for %s: %w", plan.GTID, err)
+	}
+	return execPlanStatements(ctx, conn, plan)
+}
+
+// execPlanStatements runs a plan's statements in one transaction. Each must
+// affect exactly one row, or the transaction is rolled back.
+func execPlanStatements(ctx context.Context, conn *sql.Conn, plan *replayPlan) (err error) {
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		return fmt.Errorf("failed to begin transaction for %s: %w", plan.GTID, err)
+	}
+	defer func() {
+		if err != nil {
+			if _, rollbackErr := conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK"); rollbackErr != nil {
+				err = fmt.Errorf("%w (and rolling back failed: %v)", err, rollbackErr)
+			}
+		}
+	}()
+	for _, statement := range plan.Statements {
+		res, err := conn.ExecContext(ctx, statement)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", plan.GTID, statement, err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n != 1 {
+			return fmt.Errorf("%s: %s affected %d rows, not 1 (the data differs); rolled back", plan.GTID, statement, n)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("failed to commit transaction for %s: %w", plan.GTID, err)
+	}
+	return nil
+}
+
+// planErrantTransactions translates every errant transaction, located in the
+// target's binary logs and read from its files in binlogDir, to SQL that
+// redoes or, with undo, reverts its row changes. Undo plans are ordered newest
+// first. locations is where printErrantLocations found them (nil if it could
+// not); every errant transaction must have been found.
+func planErrantTransactions(ctx context.Context, db *sql.DB, errantSet *GtidSet, locations *GtidLocations, binlogDir string, undo bool) ([]*replayPlan, error) {
+	if locations == nil {
+		return nil, fmt.Errorf("errant transactions could not be located in the target's binary logs")
+	}
+	located := &GtidSet{intervals: map[string][]Interval{}}
+	for _, tx := range locations.Transactions {
+		if key, gno, ok := splitGtid(tx.GTID); ok {
+			located.add(key, []Interval{{Start: gno, End: gno}})
+		}
+	}
+	if missing := errantSet.Subtract(located); !missing.IsEmpty() {
+		return nil, fmt.Errorf("errant transactions not in the target's binary logs: %s", missing)
+	}
+
+	tables := map[string]*replayTable{}
+	var plans []*replayPlan
+	for _, tx := range locations.Transactions {
+		events, err := readBinlogRange(filepath.Join(binlogDir, tx.File), tx.StartPos, tx.EndPos)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from %s: %w", tx.GTID, binlogDir, err)
+		}
+		plan, err := planReplay(ctx, db, tx, events, tables, undo)
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", tx.GTID, tx.Location(), err)
+		}
+		plans = append(plans, plan)
+	}
+	if undo {
+		slices.Reverse(plans)
+	}
+	return plans, nil
+}
+
+// replayErrantTransactions recreates the errant transactions' row changes on
+// the source, each under its own GTID, from the target's binary log files in
+// opts.BinlogDir. Every transaction is translated before anything is applied,
+// so one that cannot be replayed faithfully leaves the source untouched.
+// It returns true once the changes are applied.
+func replayErrantTransactions(ctx context.Context, w io.Writer, db1, db2 *sql.DB, source string, errantSet *GtidSet, locations *GtidLocations, opts Options) (resolved bool, err error) {
+	plans, err := planErrantTransactions(ctx, db2, errantSet, locations, opts.BinlogDir, false)
+	if err != nil {
+		return false, fmt.Errorf("cannot replay errant transactions: %w", err)
+	}
+	statements := 0
+	for _, plan := range plans {
+		statements += len(plan.Statements)
+	}
+
+	if opts.DryRun {
+		fmt.Fprintln(w, yellow("[dry-run]"), "Would execute on source (single pinned session):")
+		printReplayPlans(w, plans)
+		return false, nil
+	}
+	prompt := fmt.Sprintf("About to replay %d errant transaction(s) (%d statement(s)) on the SOURCE %s under their original GTIDs.", len(plans), statements, source)
+	if !confirmAction(w, prompt, opts.AssumeYes) {
+		fmt.Fprintln(w, yellow("[i]"), "Skipped replaying errant transactions on source.")
+		return false, nil
+	}
+	fmt.Fprintln(w, "Replaying errant transactions on source...")
+	if err := applyReplayPlans(ctx, w, db1, plans); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,313 @@
+package gtids
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+type testEvent struct {
+	eventType BinlogEventType
+	body      []byte
+}
+
+// ordersTableMap maps table id 90 to shop.orders (id INT, status VARCHAR(10), note TEXT).
+func ordersTableMap(t *testing.T) testEvent {
+	body := binary.LittleEndian.AppendUint64(nil, 90)[:6]
+	body = append(body, 1, 0, 4)
+	body = append(body, "shop\x00\x06orders\x00"...)
+	return testEvent{TableMapEventType, append(body, fixtureBytes(t, "03 030ffc 03 2800 02 06")...)}
+}
+
+// ordersRows is a v2 rows event on table id 90; rows is the column count,
+// bitmaps and row images in hex.
+func ordersRows(t *testing.T, eventType BinlogEventType, rows string) testEvent {
+	return testEvent{eventType, fixtureBytes(t, "5a0000000000 0100 0200 "+rows)}
+}
+
+// errantTransactionBinlog writes a binary log file holding one transaction,
+// uuidB:1, with the given events between its BEGIN and its Xid.
+func errantTransactionBinlog(t *testing.T, events ...testEvent) []byte {
+	b := newBinlogBuilder(true)
+	b.add(PreviousGtidsEventType, previousGtidsBody(t, uuidA+":1-10"))
+	b.add(GtidEventType, gtidBody(t, uuidB, 1, time.Date(2026, 3, 12, 3, 12, 0, 0, time.UTC)))
+	b.add(QueryEventType, queryBody("shop", "BEGIN"))
+	for _, event := range events {
+		b.add(event.eventType, event.body)
+	}
+	b.add(XidEventType, binary.LittleEndian.AppendUint64(nil, 77))
+	return b.buf.Bytes()
+}
+
+func decodeTestBinlog(t *testing.T, data []byte) []*DecodedEvent {
+	t.Helper()
+	reader, err := NewBinlogReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewBinlogReader failed: %v", err)
+	}
+	var events []*DecodedEvent
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+// showBinlogEventsRows renders decoded events as SHOW BINLOG EVENTS rows.
+func showBinlogEventsRows(events []*DecodedEvent) [][]any {
+	var rows [][]any
+	for _, event := range events {
+		info := ""
+		switch data := event.Data.(type) {
+		case *PreviousGtidsEvent:
+			info = data.Set.String()
+		case *GtidEvent:
+			info = "SET @@SESSION.GTID_NEXT= '" + data.GTID() + "'"
+		case *QueryEvent:
+			info = data.Query
+		case *TableMapEvent:
+			info = fmt.Sprintf("table_id: %d (%s.%s)", data.TableID, data.Schema, data.Table)
+		case *RowsEvent:
+			info = fmt.Sprintf("table_id: %d flags: STMT_END_F", data.TableID)
+		}
+		rows = append(rows, []any{event.Pos, event.Header.Type.String(), event.Header.NextPos, info})
+	}
+	return rows
+}
+
+func ordersColumns(primary string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"COLUMN_NAME", "COLUMN_TYPE", "COLUMN_KEY", "EXTRA"}).
+		AddRow("id", "int", primary, "").
+		AddRow("status", "varchar(10)", "", "").
+		AddRow("note", "text", "", "")
+}
+
+func TestPlanReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   func(t *testing.T) []testEvent
+		columns  *sqlmock.Rows // the target's columns of shop.orders, if looked up
+		expected []string
+		error    string
+	}{
+		{
+			name: "update with full row images",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{ordersTableMap(t), ordersRows(t, UpdateRowsEventType, ordersUpdate)}
+			},
+			columns:  ordersColumns("PRI"),
+			expected: []string{"UPDATE `shop`.`orders` SET `id` = 7, `status` = 'paid', `note` = 'ok' WHERE `id` <=> 7 AND `status` <=> 'new' AND `note` <=> NULL LIMIT 1"},
+		},
+		{
+			name: "insert quotes ASCII and hex-encodes other bytes",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{ordersTableMap(t), ordersRows(t, WriteRowsEventType, "03 07  00 07000000 04 69742773 0200 c3a9  00 08000000 00 0000")}
+			},
+			columns: ordersColumns("PRI"),
+			expected: []string{
+				"INSERT INTO `shop`.`orders` (`id`, `status`, `note`) VALUES (7, 'it''s', X'c3a9')",
+				"INSERT INTO `shop`.`orders` (`id`, `status`, `note`) VALUES (8, '', '')",
+			},
+		},
+		{
+			name: "delete with a minimal before image",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{ordersTableMap(t), ordersRows(t, DeleteRowsEventType, "03 01  00 07000000")}
+			},
+			columns:  ordersColumns("PRI"),
+			expected: []string{"DELETE FROM `shop`.`orders` WHERE `id` <=> 7 LIMIT 1"},
+		},
+		{
+			name: "minimal before image without a primary key",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{ordersTableMap(t), ordersRows(t, DeleteRowsEventType, "03 01  00 07000000")}
+			},
+			columns: ordersColumns(""),
+			error:   "cannot identify the changed row",
+		},
+		{
+			name: "table altered since",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{ordersTableMap(t), ordersRows(t, DeleteRowsEventType, "03 01  00 07000000")}
+			},
+			columns: sqlmock.NewRows([]string{"COLUMN_NAME", "COLUMN_TYPE", "COLUMN_KEY", "EXTRA"}).AddRow("id", "int", "PRI", ""),
+			error:   "altered since",
+		},
+		{
+			name: "DDL",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{{QueryEventType, queryBody("shop", "ALTER TABLE orders ADD COLUMN x INT")}}
+			},
+			error: "DDL statement",
+		},
+		{
+			name: "statement-based DML",
+			events: func(t *testing.T) []testEvent {
+				return []testEvent{{QueryEventType, queryBody("shop", "UPDATE orders SET status = UUID()")}}
+			},
+			error: "only row-based changes can",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+			if tt.columns != nil {
+				mock.ExpectQuery("information_schema.COLUMNS").WithArgs("shop", "orders").WillReturnRows(tt.columns)
+			}
+
+			events := decodeTestBinlog(t, errantTransactionBinlog(t, tt.events(t)...))
+			tx := &BinlogTransaction{GTID: uuidB + ":1", File: "binlog.000001"}
+			plan, err := planReplay(context.Background(), db, tx, events[2:], map[string]*replayTable{}, false)
+			if tt.error != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.error) {
+					t.Fatalf("expected an error containing %q, got %v", tt.error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planReplay failed: %v", err)
+			}
+			if strings.Join(plan.Statements, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected statements\n%s\ngot\n%s", strings.Join(tt.expected, "\n"), strings.Join(plan.Statements, "\n"))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+// ordersUpdate changes shop.orders row 7 from (7, 'new', NULL) to (7, 'paid', 'ok').
+const ordersUpdate = "03 07 07  04 07000000 03 6e6577  00 07000000 04 70616964 0200 6f6b"
+
+// expectErrantTransactionCheck writes data, a binary log holding the errant
+// transaction uuidB:1 on shop.orders, to a directory and mocks a check that
+// finds and locates it there, up to reading the table's columns.
+func expectErrantTransactionCheck(t *testing.T, mock1, mock2 sqlmock.Sqlmock, data []byte) (binlogDir string) {
+	return expectPurgedErrantTransactionCheck(t, mock1, mock2, data, "")
+}
+
+// expectPurgedErrantTransactionCheck is expectErrantTransactionCheck for a
+// target that also executed the errant transactions purged, already gone
+// from its binary logs.
+func expectPurgedErrantTransactionCheck(t *testing.T, mock1, mock2 sqlmock.Sqlmock, data []byte, purged string) (binlogDir string) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "binlog.000001"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rows := showBinlogEventsRows(decodeTestBinlog(t, data))
+
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		mock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+	}
+	mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@server_uuid")).WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(uuidA))
+	mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(uuidA + ":1-10"))
+	mock2.ExpectQuery(regexp.QuoteMeta("SELECT @@server_uuid")).WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(uuidB))
+	executed := uuidA + ":1-10," + uuidB + ":1"
+	if purged != "" {
+		executed += "," + purged
+	}
+	mock2.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(executed))
+	mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_PURGED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(""))
+	mock2.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_PURGED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(purged))
+	mock2.ExpectQuery("SHOW BINARY LOGS").WillReturnRows(sqlmock.NewRows([]string{"Log_name", "File_size"}).AddRow("binlog.000001", len(data)))
+	expectBinlogEvents(mock2, "binlog.000001", " LIMIT 3", rows[:3]...)
+	expectBinlogEvents(mock2, "binlog.000001", "", rows...)
+	mock2.ExpectQuery("information_schema.COLUMNS").WithArgs("shop", "orders").WillReturnRows(ordersColumns("PRI"))
+	return dir
+}
+
+func TestCheckGtidSetOutcome_ReplaysErrantTransactions(t *testing.T) {
+	data := errantTransactionBinlog(t, ordersTableMap(t), ordersRows(t, UpdateRowsEventType, ordersUpdate))
+	update := "UPDATE `shop`.`orders` SET `id` = 7, `status` = 'paid', `note` = 'ok' WHERE `id` <=> 7 AND `status` <=> 'new' AND `note` <=> NULL LIMIT 1"
+
+	for _, dryRun := range []bool{true, false} {
+		db1, mock1, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db1.Close()
+		db2, mock2, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db2.Close()
+
+		dir := expectErrantTransactionCheck(t, mock1, mock2, data)
+		if !dryRun {
+			mock1.ExpectExec(regexp.QuoteMeta("SET SESSION time_zone = '+00:00'")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock1.ExpectExec(regexp.QuoteMeta("SET GTID_NEXT='" + uuidB + ":1'")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock1.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock1.ExpectExec(regexp.QuoteMeta(update)).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock1.ExpectExec("COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock1.ExpectExec(regexp.QuoteMeta("SET GTID_NEXT='AUTOMATIC'")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock1.ExpectExec(regexp.QuoteMeta("SET SESSION time_zone = DEFAULT")).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+
+		outcome, err := CheckGtidSetOutcome(context.Background(), db1, db2, "source", "target", Options{
+			Replay: true, BinlogDir: dir, DryRun: dryRun, AssumeYes: true,
+		})
+		if err != nil {
+			t.Fatalf("dryRun=%v: CheckGtidSetOutcome failed: %v", dryRun, err)
+		}
+		expected := InSync
+		if dryRun {
+			expected = Unresolved
+		}
+		if outcome != expected {
+			t.Errorf("dryRun=%v: expected outcome %q, got %q", dryRun, expected, outcome)
+		}
+		for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("dryRun=%v: unmet expectations: %v", dryRun, err)
+			}
+		}
+	}
+}
+
+func TestApplyReplayPlans_RollsBackWhenTheSourceDiffers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("SET SESSION time_zone = '+00:00'")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("SET GTID_NEXT='" + uuidB + ":1'")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("SET GTID_NEXT='AUTOMATIC'")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("SET SESSION time_zone = DEFAULT")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = applyReplayPlans(context.Background(), os.Stdout, db, []*replayPlan{{
+		GTID:       uuidB + ":1",
+		Statements: []string{"DELETE FROM `shop`.`orders` WHERE `id` <=> 7 LIMIT 1"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "affected 0 rows") {
+		t.Errorf("expected the replay to fail on 0 affected rows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
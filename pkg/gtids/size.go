@@ -0,0 +1,182 @@
+package gtids
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// TableSize is how much of a set of transactions went to one table.
+type TableSize struct {
+	Table  string // schema.table
+	Events int    // rows events
+	Bytes  uint64 // size of the rows events in the binary logs
+	Rows   int64  // rows changed (updates count once), or -1 if unknown
+}
+
+// DriftSize is the binary log footprint of a set of transactions, to tell a
+// few stray writes from drift that is cheaper to fix by re-cloning.
+type DriftSize struct {
+	Transactions int
+	Events       int    // events of all kinds, Gtid events included
+	Bytes        uint64 // size of the transactions in the binary logs
+	Statements   int    // DDL and statement-based DML, whose rows are unknown
+	Tables       []TableSize
+}
+
+// measureTransactions adds up the events of located transactions. Rows events
+// are broken down per table; SHOW BINLOG EVENTS does not expose row images, so
+// rows are counted only when binlogDir holds the server's binary log files.
+func measureTransactions(locations *GtidLocations, binlogDir string) (*DriftSize, error) {
+	size := &DriftSize{}
+	byTable := map[string]*TableSize{}
+	for _, tx := range locations.Transactions {
+		size.Transactions++
+		size.Events += len(tx.Events)
+		size.Bytes += tx.EndPos - tx.StartPos
+		for _, event := range tx.Events {
+			if event.EventType != "Query" {
+				continue
+			}
+			switch strings.ToUpper(strings.TrimSpace(event.Info)) {
+			case "BEGIN", "COMMIT", "ROLLBACK":
+				continue
+			}
+			if _, statement := parseQueryEvent(event.Info); classifyStatement(statement).ChangesData() {
+				size.Statements++
+			}
+		}
+		contents := transactionContents(tx)
+		for _, change := range contents.Changes {
+			table, ok := byTable[change.Table]
+			if !ok {
+				table = &TableSize{Table: change.Table, Rows: -1}
+				if binlogDir != "" {
+					table.Rows = 0
+				}
+				byTable[change.Table] = table
+			}
+			table.Events += change.Events
+			table.Bytes += change.Bytes
+		}
+		if binlogDir == "" || len(contents.Changes) == 0 {
+			continue
+		}
+		rows, err := countTransactionRows(binlogDir, tx)
+		if errors.Is(err, errCompressedTransaction) {
+			for _, change := range contents.Changes {
+				byTable[change.Table].Rows = -1
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to count the rows of %s (%s): %w", tx.GTID, tx.Location(), err)
+		}
+		for key, n := range rows {
+			if table, ok := byTable[key[0]]; ok && table.Rows >= 0 {
+				table.Rows += n
+			}
+		}
+	}
+	for _, table := range byTable {
+		size.Tables = append(size.Tables, *table)
+	}
+	slices.SortFunc(size.Tables, func(a, b TableSize) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(a.Table, b.Table))
+	})
+	return size, nil
+}
+
+// errCompressedTransaction is returned for a transaction whose events are
+// compressed in a Transaction_payload event, which are not decompressed.
+var errCompressedTransaction = errors.New("compressed transaction")
+
+// countTransactionRows reads tx from its file in binlogDir and counts the rows
+// its rows events change, per schema.table and operation (as TableChange
+// names them).
+func countTransactionRows(binlogDir string, tx *BinlogTransaction) (map[[2]string]int64, error) {
+	events, err := readBinlogRange(filepath.Join(binlogDir, tx.File), tx.StartPos, tx.EndPos)
+	if err != nil {
+		return nil, err
+	}
+	rows := map[[2]string]int64{}
+	tableMaps := map[uint64]*TableMapEvent{}
+	for _, event := range events {
+		switch data := event.Data.(type) {
+		case *TransactionPayloadEvent:
+			return nil, errCompressedTransaction
+		case *TableMapEvent:
+			tableMaps[data.TableID] = data
+		case *RowsEvent:
+			tm, ok := tableMaps[data.TableID]
+			if !ok {
+				return nil, fmt.Errorf("rows event at %d without a Table_map", event.Pos)
+			}
+			changes, err := data.decodeRows(tm, nil)
+			if err != nil {
+				return nil, err
+			}
+			rows[[2]string{tm.Schema + "." + tm.Table, rowsEventOperations[data.Type.String()]}] += int64(len(changes))
+		}
+	}
+	return rows, nil
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.5 MiB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// printDriftSize prints size, for the kind ("errant" or "missing") of
+// transactions it measures.
+func printDriftSize(w io.Writer, kind string, size *DriftSize) {
+	fmt.Fprintf(w, "%s Size of %s transactions: %d transaction(s), %d events, %s in the binary logs\n",
+		yellow("[i]"), kind, size.Transactions, size.Events, formatBytes(size.Bytes))
+	for _, table := range size.Tables {
+		rows := "rows unknown"
+		if table.Rows >= 0 {
+			rows = fmt.Sprintf("%d rows", table.Rows)
+		}
+		fmt.Fprintf(w, "      %s: %d events, %s, %s\n", table.Table, table.Events, formatBytes(table.Bytes), rows)
+	}
+	if size.Statements > 0 {
+		fmt.Fprintf(w, "      %d DDL or statement-based DML statement(s), rows unknown\n", size.Statements)
+	}
+}
+
+// printSetSize prints the size of set, located in db's binary logs unless
+// locations already says where it is. Sizing is informational, so failures
+// are reported rather than returned.
+func printSetSize(ctx context.Context, w io.Writer, db *sql.DB, kind string, set *GtidSet, locations *GtidLocations, binlogDir string) {
+	if locations == nil {
+		var err error
+		if locations, err = LocateGtids(ctx, db, set); err != nil {
+			fmt.Fprintln(w, yellow("[!]"), "Could not size the", kind, "transactions:", err)
+			return
+		}
+	}
+	size, err := measureTransactions(locations, binlogDir)
+	if err != nil {
+		fmt.Fprintln(w, yellow("[!]"), "Could not count the rows of the", kind, "transactions:", err)
+		size, _ = measureTransactions(locations, "")
+	}
+	printDriftSize(w, kind, size)
+	if unsized := locations.Purged.Union(locations.NotFound); !unsized.IsEmpty() {
+		fmt.Fprintln(w, yellow("[!]"), "Not included (purged, or not in the binary logs):", unsized)
+	}
+}
@@ -0,0 +1,92 @@
+package gtids
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n        uint64
+		expected string
+	}{
+		{0, "0 bytes"},
+		{1023, "1023 bytes"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 40, "3.0 TiB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.expected {
+			t.Errorf("formatBytes(%d) = %q, expected %q", tt.n, got, tt.expected)
+		}
+	}
+}
+
+// locateTestTransactions groups the events of a binary log file, as
+// SHOW BINLOG EVENTS would list them, into located transactions.
+func locateTestTransactions(t *testing.T, data []byte) *GtidLocations {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	expectBinlogEvents(mock, "binlog.000001", "", showBinlogEventsRows(decodeTestBinlog(t, data))...)
+
+	locations := &GtidLocations{Purged: &GtidSet{intervals: map[string][]Interval{}}, NotFound: &GtidSet{intervals: map[string][]Interval{}}}
+	err = scanBinlogTransactions(context.Background(), db, "binlog.000001", func(tx *BinlogTransaction) bool {
+		locations.Transactions = append(locations.Transactions, tx)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("scanBinlogTransactions failed: %v", err)
+	}
+	return locations
+}
+
+func TestMeasureTransactions(t *testing.T) {
+	data := errantTransactionBinlog(t,
+		ordersTableMap(t),
+		ordersRows(t, UpdateRowsEventType, ordersUpdate),
+		ordersRows(t, WriteRowsEventType, "03 07  00 07000000 03 6e6577 0000  00 08000000 00 0000"),
+		testEvent{QueryEventType, queryBody("shop", "DELETE FROM audit WHERE id < 5")},
+	)
+	locations := locateTestTransactions(t, data)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "binlog.000001"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, binlogDir := range []string{"", dir} {
+		size, err := measureTransactions(locations, binlogDir)
+		if err != nil {
+			t.Fatalf("measureTransactions(%q) failed: %v", binlogDir, err)
+		}
+		tx := locations.Transactions[0]
+		if size.Transactions != 1 || size.Events != len(tx.Events) || size.Bytes != tx.EndPos-tx.StartPos {
+			t.Errorf("unexpected totals %+v for %s", size, tx.Location())
+		}
+		if size.Statements != 1 {
+			t.Errorf("expected 1 statement, got %d", size.Statements)
+		}
+		if len(size.Tables) != 1 {
+			t.Fatalf("expected 1 table, got %+v", size.Tables)
+		}
+		expectedRows := int64(-1)
+		if binlogDir != "" {
+			expectedRows = 3
+		}
+		if table := size.Tables[0]; table.Table != "shop.orders" || table.Events != 2 || table.Bytes == 0 || table.Rows != expectedRows {
+			t.Errorf("binlogDir %q: unexpected table size %+v, expected 2 events and %d rows", binlogDir, table, expectedRows)
+		}
+	}
+
+	if _, err := measureTransactions(locations, t.TempDir()); err == nil {
+		t.Error("expected an error when the binary log file is missing")
+	}
+}
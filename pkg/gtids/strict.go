@@ -0,0 +1,159 @@
+package gtids
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// uuidPattern matches a textual server UUID in 8-4-4-4-12 form.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}(?:-[0-9a-fA-F]{4}){3}-[0-9a-fA-F]{12}$`)
+
+// ParseError reports where strict parsing of a GTID set failed. Offset is the
+// byte offset of Token in the input.
+type ParseError struct {
+	Offset int
+	Token  string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid GTID set at offset %d (%q): %s", e.Offset, e.Token, e.Reason)
+}
+
+// NewOracleGtidSetStrict parses a GTID set like NewOracleGtidSet but rejects
+// anything MySQL would not print itself: malformed UUIDs, empty elements or
+// intervals ("a:1,,b:2", "a::1", "a:1:"), reversed or out-of-order intervals,
+// transaction numbers of 0 or past 9223372036854775806, and tags without
+// intervals. Whitespace is allowed only around the commas. Errors are
+// *ParseError values pointing at the offending token.
+func NewOracleGtidSetStrict(gtidSet string) (*OracleGtidSet, error) {
+	res := &OracleGtidSet{}
+	if strings.TrimSpace(gtidSet) == "" {
+		return res, nil
+	}
+	offset := 0
+	elements := strings.Split(gtidSet, ",")
+	for i, element := range elements {
+		lead := len(element) - len(strings.TrimLeftFunc(element, unicode.IsSpace))
+		body := strings.TrimSpace(element)
+		if body == "" {
+			// Point at the comma that has nothing on one side of it.
+			commaOffset := offset - 1
+			if i == 0 {
+				commaOffset = len(element)
+			}
+			return nil, &ParseError{Offset: commaOffset, Token: ",", Reason: "stray separator"}
+		}
+		entries, err := parseStrictElement(body, offset+lead)
+		if err != nil {
+			return nil, err
+		}
+		res.GtidEntries = append(res.GtidEntries, entries...)
+		offset += len(element) + 1
+	}
+	return res, nil
+}
+
+// NewGtidSetStrict parses a GTID set with NewOracleGtidSetStrict's rules.
+func NewGtidSetStrict(gtidSet string) (*GtidSet, error) {
+	oracleGtidSet, err := NewOracleGtidSetStrict(gtidSet)
+	if err != nil {
+		return nil, err
+	}
+	return oracleGtidSet.GtidSet()
+}
+
+// parseStrictElement parses one comma-separated element starting at offset,
+// e.g. "uuid:1-5:11:mytag:1-3", into one entry per tag.
+func parseStrictElement(element string, offset int) (entries []*OracleGtidSetEntry, err error) {
+	tokens := strings.Split(element, ":")
+	uuid := tokens[0]
+	if !uuidPattern.MatchString(uuid) {
+		return nil, &ParseError{Offset: offset, Token: uuid, Reason: "invalid UUID"}
+	}
+	if len(tokens) == 1 {
+		return nil, &ParseError{Offset: offset, Token: element, Reason: "missing transaction intervals"}
+	}
+
+	tag, tagOffset := "", 0
+	var ranges []string
+	var previousEnd int64
+	flush := func() {
+		if len(ranges) > 0 {
+			entries = append(entries, &OracleGtidSetEntry{UUID: uuid, Tag: tag, Ranges: strings.Join(ranges, ":")})
+		}
+	}
+
+	pos := offset + len(uuid) + 1
+	for _, token := range tokens[1:] {
+		switch {
+		case token == "":
+			return nil, &ParseError{Offset: pos - 1, Token: ":", Reason: "stray separator"}
+		case gtidTagPattern.MatchString(token):
+			if tag != "" && len(ranges) == 0 {
+				return nil, &ParseError{Offset: tagOffset, Token: tag, Reason: "tag without intervals"}
+			}
+			flush()
+			tag, tagOffset, ranges, previousEnd = token, pos, nil, 0
+		default:
+			interval, err := parseStrictInterval(token, pos)
+			if err != nil {
+				return nil, err
+			}
+			if interval.Start <= previousEnd {
+				return nil, &ParseError{Offset: pos, Token: token, Reason: "interval overlaps or precedes the previous one"}
+			}
+			previousEnd = interval.End
+			ranges = append(ranges, token)
+		}
+		pos += len(token) + 1
+	}
+	if tag != "" && len(ranges) == 0 {
+		return nil, &ParseError{Offset: tagOffset, Token: tag, Reason: "tag without intervals"}
+	}
+	flush()
+	return entries, nil
+}
+
+// parseStrictInterval parses "N" or "N-M" found at offset.
+func parseStrictInterval(token string, offset int) (Interval, error) {
+	startText, endText, isRange := strings.Cut(token, "-")
+	start, err := parseStrictGno(startText, offset)
+	if err != nil {
+		return Interval{}, err
+	}
+	if !isRange {
+		return Interval{Start: start, End: start}, nil
+	}
+	end, err := parseStrictGno(endText, offset+len(startText)+1)
+	if err != nil {
+		return Interval{}, err
+	}
+	if end < start {
+		return Interval{}, &ParseError{Offset: offset, Token: token, Reason: "reversed interval"}
+	}
+	return Interval{Start: start, End: end}, nil
+}
+
+// parseStrictGno parses a transaction number found at offset.
+func parseStrictGno(text string, offset int) (int64, error) {
+	if text == "" {
+		return 0, &ParseError{Offset: offset, Token: text, Reason: "missing transaction number"}
+	}
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return 0, &ParseError{Offset: offset, Token: text, Reason: "transaction number is not a decimal number"}
+		}
+	}
+	gno, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, &ParseError{Offset: offset, Token: text, Reason: "transaction number overflows int64"}
+	}
+	if gno < 1 || gno > maxGno {
+		return 0, &ParseError{Offset: offset, Token: text, Reason: fmt.Sprintf("transaction number must be between 1 and %d", int64(maxGno))}
+	}
+	return gno, nil
+}
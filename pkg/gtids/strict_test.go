@@ -0,0 +1,150 @@
+package gtids
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewGtidSetStrict(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		hasError bool
+		offset   int
+		token    string
+	}{
+		{name: "empty", input: "  ", expected: ""},
+		{name: "canonical", input: uuidA + ":1-5:11," + uuidB + ":1", expected: uuidA + ":1-5:11," + uuidB + ":1"},
+		{name: "MySQL multi-line output", input: uuidA + ":1-3,\n" + uuidB + ":1-5", expected: uuidA + ":1-3," + uuidB + ":1-5"},
+		{name: "tagged", input: uuidA + ":1-3:mytag:1-5:other:2", expected: uuidA + ":1-3:mytag:1-5:other:2"},
+		{name: "tag only", input: uuidA + ":mytag:4", expected: uuidA + ":mytag:4"},
+		{name: "adjacent intervals", input: uuidA + ":1-5:6", expected: uuidA + ":1-6"},
+		{name: "invalid UUID", input: "not-a-uuid:1", hasError: true, offset: 0, token: "not-a-uuid"},
+		{name: "invalid second UUID", input: uuidA + ":1, 1d1fff5a:2", hasError: true, offset: 40, token: "1d1fff5a"},
+		{name: "missing intervals", input: uuidA, hasError: true, offset: 0, token: uuidA},
+		{name: "reversed interval", input: uuidA + ":1-2:5-3", hasError: true, offset: 41, token: "5-3"},
+		{name: "out of order", input: uuidA + ":10-20:5", hasError: true, offset: 43, token: "5"},
+		{name: "overlapping", input: uuidA + ":1-10:10-12", hasError: true, offset: 42, token: "10-12"},
+		{name: "garbage interval", input: uuidA + ":1x", hasError: true, offset: 37, token: "1x"},
+		{name: "open-ended interval", input: uuidA + ":1-", hasError: true, offset: 39, token: ""},
+		{name: "zero", input: uuidA + ":0", hasError: true, offset: 37, token: "0"},
+		{name: "past max transaction number", input: uuidA + ":1-9223372036854775807", hasError: true, offset: 39, token: "9223372036854775807"},
+		{name: "overflows int64", input: uuidA + ":99999999999999999999", hasError: true, offset: 37, token: "99999999999999999999"},
+		{name: "double colon", input: uuidA + "::1", hasError: true, offset: 36, token: ":"},
+		{name: "trailing colon", input: uuidA + ":1:", hasError: true, offset: 38, token: ":"},
+		{name: "double comma", input: uuidA + ":1,," + uuidB + ":1", hasError: true, offset: 38, token: ","},
+		{name: "leading comma", input: "," + uuidA + ":1", hasError: true, offset: 0, token: ","},
+		{name: "trailing comma", input: uuidA + ":1,", hasError: true, offset: 38, token: ","},
+		{name: "tag without intervals", input: uuidA + ":1:mytag:other:2", hasError: true, offset: 39, token: "mytag"},
+		{name: "trailing tag", input: uuidA + ":1:mytag", hasError: true, offset: 39, token: "mytag"},
+		{name: "space inside element", input: uuidA + ": 1", hasError: true, offset: 37, token: " 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := NewGtidSetStrict(tt.input)
+			if !tt.hasError {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got := set.String(); got != tt.expected {
+					t.Errorf("expected %q, got %q", tt.expected, got)
+				}
+				return
+			}
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected a *ParseError, got %v", err)
+			}
+			if parseErr.Offset != tt.offset || parseErr.Token != tt.token {
+				t.Errorf("expected offset %d token %q, got %v", tt.offset, tt.token, parseErr)
+			}
+		})
+	}
+}
+
+func TestNewGtidSetStrict_AgreesWithLenientParser(t *testing.T) {
+	// Whatever strict parsing accepts, the lenient parser reads the same way.
+	input := uuidB + ":1-3:7:t:1," + strings.ToUpper(uuidA) + ":2-9"
+	strict, err := NewGtidSetStrict(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strict.Equal(mustGtidSet(t, input)) {
+		t.Errorf("strict %q differs from lenient %q", strict, mustGtidSet(t, input))
+	}
+}
+
+func FuzzNewOracleGtidSetStrict(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		uuidA + ":1-5:11",
+		uuidA + ":1-3,\n" + uuidB + ":1-5:mytag:2",
+		uuidA + ":5-3",
+		uuidA + "::1,,",
+		uuidA + ":1-9223372036854775806",
+		uuidA + ":99999999999999999999",
+		"garbage",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		set, err := NewOracleGtidSetStrict(input)
+		if err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("error is not a *ParseError: %v", err)
+			}
+			if parseErr.Offset < 0 || parseErr.Offset > len(input) || !strings.HasPrefix(input[parseErr.Offset:], parseErr.Token) {
+				t.Fatalf("error %v does not point into %q", parseErr, input)
+			}
+			return
+		}
+
+		// Accepted input always converts, and its canonical form is accepted too.
+		gtidSet, err := set.GtidSet()
+		if err != nil {
+			t.Fatalf("strictly parsed %q but GtidSet failed: %v", input, err)
+		}
+		reparsed, err := NewGtidSetStrict(gtidSet.String())
+		if err != nil {
+			t.Fatalf("canonical form %q rejected: %v", gtidSet, err)
+		}
+		if !reparsed.Equal(gtidSet) {
+			t.Fatalf("round trip changed %q to %q", gtidSet, reparsed)
+		}
+		for entry := range set.ExplodeSeq() {
+			if !gtidEntryPattern.MatchString(entry.String()) {
+				t.Fatalf("exploded entry %q would be rejected by the fix paths", entry)
+			}
+			break
+		}
+	})
+}
+
+func FuzzGtidSet_UnmarshalBinary(f *testing.F) {
+	for _, fixture := range binaryGtidSetFixtures {
+		data, _ := hex.DecodeString(strings.Join(strings.Fields(fixture.hex), ""))
+		f.Add(data)
+	}
+	f.Add([]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var set GtidSet
+		if err := set.UnmarshalBinary(data); err != nil {
+			return
+		}
+		encoded, err := set.MarshalBinary()
+		if err != nil {
+			t.Fatalf("decoded set %q does not encode: %v", set.String(), err)
+		}
+		var again GtidSet
+		if err := again.UnmarshalBinary(encoded); err != nil || !again.Equal(&set) {
+			t.Fatalf("re-encoding %q is not stable: %v", set.String(), err)
+		}
+	})
+}
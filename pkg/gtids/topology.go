@@ -0,0 +1,246 @@
+package gtids
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+)
+
+// TopologyNode is a server in a replication tree, compared with its direct
+// source (the node above it).
+type TopologyNode struct {
+	Host, Port string
+	// FromProcesslist is set when the source only knew this replica as the
+	// client of a Binlog Dump thread (see DiscoverReplicas).
+	FromProcesslist bool
+	UUID            string
+	Executed        *GtidSet
+	Purged          *GtidSet
+	// Errant holds the transactions executed here but not on the source.
+	// Because each replica is compared with its direct source, the errant
+	// transactions of an intermediate replica replicate down as ordinary
+	// transactions and are reported on that intermediate only.
+	Errant *GtidSet
+	// Lagging holds the transactions on the source not applied here yet,
+	// which the source's binary logs can still deliver; Missing holds those
+	// the source already purged.
+	Lagging *GtidSet
+	Missing *GtidSet
+	// Duplicate names the node this server was already crawled as, under
+	// another source or as an ancestor in a circular topology. Duplicates are
+	// neither compared nor crawled again.
+	Duplicate string
+	Err       error
+	Replicas  []*TopologyNode
+}
+
+// Label is the node's host:port.
+func (n *TopologyNode) Label() string {
+	return net.JoinHostPort(n.Host, n.Port)
+}
+
+// Outcome rates the node against its source: errant transactions are
+// Unresolved (ErrantPurged if this server already purged some), as are
+// missing ones that replication can no longer deliver. Lagging is in sync.
+func (n *TopologyNode) Outcome() Outcome {
+	switch {
+	case n.Errant != nil && !n.Errant.IsEmpty():
+		if n.Purged != nil && !n.Errant.Intersect(n.Purged).IsEmpty() {
+			return ErrantPurged
+		}
+		return Unresolved
+	case n.Missing != nil && !n.Missing.IsEmpty():
+		return Unresolved
+	}
+	return InSync
+}
+
+// Worst returns the worst outcome in the tree below and including n, and
+// whether any node could not be checked.
+func (n *TopologyNode) Worst() (outcome Outcome, failed bool) {
+	outcome, failed = n.Outcome(), n.Err != nil
+	for _, replica := range n.Replicas {
+		o, f := replica.Worst()
+		outcome, failed = max(outcome, o), failed || f
+	}
+	return outcome, failed
+}
+
+// CrawlTopology connects to host:port and, depth first, to every replica below
+// it, found with DiscoverReplicas; a replica that does not report its port is
+// assumed to listen on replicaPort. connect opens each server's connection
+// (ConnectToDatabase outside tests). A server that cannot be checked gets Err
+// and is not crawled further; the rest of the tree still is.
+func CrawlTopology(ctx context.Context, host, port, replicaPort string, connect func(ctx context.Context, host, port string) (*sql.DB, error)) *TopologyNode {
+	c := &topologyCrawler{replicaPort: replicaPort, connect: connect, seen: map[string]*TopologyNode{}}
+	root := &TopologyNode{Host: host, Port: port}
+	c.crawl(ctx, root, nil, nil)
+	return root
+}
+
+type topologyCrawler struct {
+	replicaPort string
+	connect     func(ctx context.Context, host, port string) (*sql.DB, error)
+	seen        map[string]*TopologyNode // by server_uuid
+}
+
+// crawl reads node and compares it with source (nil for the root), whose
+// connection sourceDB is still open, then crawls node's replicas.
+func (c *topologyCrawler) crawl(ctx context.Context, node, source *TopologyNode, sourceDB *sql.DB) {
+	db, err := c.connect(ctx, node.Host, node.Port)
+	if err != nil {
+		node.Err = err
+		return
+	}
+	defer db.Close()
+
+	if node.Err = c.read(ctx, db, node); node.Err != nil {
+		return
+	}
+	if seen, ok := c.seen[node.UUID]; ok {
+		node.Duplicate = seen.Label()
+		return
+	}
+	c.seen[node.UUID] = node
+
+	if source != nil {
+		// The source is read again after this server: a transaction it
+		// committed in between may already have replicated here and must not
+		// look errant. Missing ones are judged against the earlier read,
+		// which this server had time to catch up with.
+		var executed string
+		err := retryDatabaseOperation(ctx, func() error {
+			return sourceDB.QueryRowContext(ctx, "SELECT @@GLOBAL.GTID_EXECUTED").Scan(&executed)
+		}, 3)
+		if err != nil {
+			node.Err = fmt.Errorf("failed to re-read the gtid_executed of source %s: %w", source.Label(), err)
+			return
+		}
+		sourceNow, err := NewGtidSet(executed)
+		if err != nil {
+			node.Err = fmt.Errorf("failed to parse the gtid_executed of source %s: %w", source.Label(), err)
+			return
+		}
+		node.Errant = node.Executed.Subtract(sourceNow)
+		missing := source.Executed.Subtract(node.Executed)
+		node.Missing = missing.Intersect(source.Purged)
+		node.Lagging = missing.Subtract(source.Purged)
+	}
+
+	replicas, fromProcesslist, err := DiscoverReplicas(ctx, db)
+	if err != nil {
+		node.Err = fmt.Errorf("failed to discover replicas: %w", err)
+		return
+	}
+	seenHere := map[string]bool{}
+	for _, replica := range replicas {
+		child := &TopologyNode{Host: replica.Host, Port: replica.Port, FromProcesslist: fromProcesslist}
+		if child.Port == "" {
+			child.Port = c.replicaPort
+		}
+		if seenHere[child.Label()] {
+			continue
+		}
+		seenHere[child.Label()] = true
+		node.Replicas = append(node.Replicas, child)
+		c.crawl(ctx, child, node, db)
+	}
+}
+
+// read fills in node's server_uuid, gtid_executed and gtid_purged.
+func (c *topologyCrawler) read(ctx context.Context, db *sql.DB, node *TopologyNode) error {
+	version, err := getServerVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if isMariaDB(version) {
+		return fmt.Errorf("MariaDB GTIDs are not supported in a topology (%s)", version)
+	}
+	uuid, executed, err := getServerInfo(ctx, db)
+	if err != nil {
+		return err
+	}
+	purged, err := getGtidPurged(ctx, db)
+	if err != nil {
+		return err
+	}
+	node.UUID = uuid
+	if node.Executed, err = NewGtidSet(executed); err != nil {
+		return fmt.Errorf("failed to parse gtid_executed: %w", err)
+	}
+	if node.Purged, err = NewGtidSet(purged); err != nil {
+		return fmt.Errorf("failed to parse gtid_purged: %w", err)
+	}
+	return nil
+}
+
+// RenderTopology prints the tree below root, one server per line with its
+// status against its source:
+//
+//	primary:3306  top of the tree, 1200 executed transactions across 1 UUID
+//	├── relay:3306  in sync
+//	│   └── leaf1:3306  lagging: 3 transactions across 1 UUID (uuid:1198-1200)
+//	└── leaf2:3306  errant: 1 transaction across 1 UUID (uuid:5)
+func RenderTopology(w io.Writer, root *TopologyNode) {
+	fmt.Fprintf(w, "%s  %s\n", root.Label(), root.status(true))
+	renderReplicas(w, root, "")
+}
+
+func renderReplicas(w io.Writer, node *TopologyNode, prefix string) {
+	for i, replica := range node.Replicas {
+		branch, indent := "├── ", "│   "
+		if i == len(node.Replicas)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s  %s\n", prefix, branch, replica.Label(), replica.status(false))
+		renderReplicas(w, replica, prefix+indent)
+	}
+}
+
+// status describes the node for its line of the tree.
+func (n *TopologyNode) status(root bool) string {
+	var status string
+	shownErr := false
+	switch {
+	case n.Duplicate != "":
+		status = "already in the tree as " + n.Duplicate
+	case root && n.Executed != nil:
+		status = "top of the tree, " + n.Executed.Summary("executed")
+	case n.Errant == nil:
+		// Never compared with its source.
+		status, shownErr = red("error: "+n.Err.Error()), true
+	default:
+		for _, part := range []struct {
+			set   *GtidSet
+			label string
+			color func(a ...interface{}) string
+		}{
+			{n.Errant, "errant", red},
+			{n.Missing, "missing, purged from its source's binary logs", red},
+			{n.Lagging, "lagging", yellow},
+		} {
+			if part.set.IsEmpty() {
+				continue
+			}
+			if status != "" {
+				status += "; "
+			}
+			status += fmt.Sprintf("%s: %s (%s)", part.color(part.label), part.set.Summary(""), part.set)
+		}
+		if n.Outcome() == ErrantPurged {
+			status += red(" [errant already purged here]")
+		}
+		if status == "" {
+			status = green("in sync")
+		}
+	}
+	if n.Err != nil && !shownErr {
+		status += "; " + red("error: "+n.Err.Error())
+	}
+	if n.FromProcesslist {
+		status += " (seen as a Binlog Dump client)"
+	}
+	return status
+}
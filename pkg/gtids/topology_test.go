@@ -0,0 +1,182 @@
+package gtids
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectTopologyNode mocks the queries that read one server of a topology and
+// list its replicas with SHOW REPLICAS.
+func expectTopologyNode(mock sqlmock.Sqlmock, uuid, executed, purged string, replicas ...Replica) {
+	mock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT @@server_uuid")).WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(uuid))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(executed))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_PURGED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(purged))
+	mock.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+	rows := sqlmock.NewRows([]string{"Server_Id", "Host", "Port", "Source_Id", "Replica_UUID"})
+	for i, replica := range replicas {
+		rows.AddRow(i+2, replica.Host, replica.Port, 1, replica.UUID)
+	}
+	mock.ExpectQuery("SHOW REPLICAS").WillReturnRows(rows)
+	if len(replicas) == 0 {
+		mock.ExpectQuery(regexp.QuoteMeta(binlogDumpQuery)).WillReturnRows(sqlmock.NewRows([]string{"HOST"}))
+	}
+}
+
+func TestCrawlTopology(t *testing.T) {
+	const uuidC = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	dbs := map[string]*sql.DB{}
+	mocks := map[string]sqlmock.Sqlmock{}
+	for _, host := range []string{"primary", "relay", "leaf1", "leaf2"} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+		dbs[host], mocks[host] = db, mock
+	}
+
+	// relay has an errant transaction (uuidB:1), which leaf1 replicated;
+	// leaf1 has not applied primary's last three transactions yet; leaf2
+	// lacks two that primary already purged.
+	expectTopologyNode(mocks["primary"], uuidA, uuidA+":1-100", uuidA+":1-50",
+		Replica{Host: "relay", Port: "3306", UUID: uuidB},
+		Replica{Host: "leaf2", Port: "3306", UUID: uuidC},
+		Replica{Host: "down", Port: "3306"})
+	// primary commits 101 after it is read and relay applies it before it
+	// is read: primary's second read keeps 101 from looking errant.
+	expectTopologyNode(mocks["relay"], uuidB, uuidA+":1-101,"+uuidB+":1", "",
+		Replica{Host: "leaf1", Port: "", UUID: uuidC})
+	expectTopologyNode(mocks["leaf1"], uuidC, uuidA+":1-97,"+uuidB+":1", "")
+	// Each source is read again to compare each of its replicas with it.
+	mocks["relay"].ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(uuidA + ":1-101," + uuidB + ":1"))
+	mocks["primary"].ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(uuidA + ":1-101"))
+	expectTopologyNode(mocks["leaf2"], "4c1b6e4e-9bd7-11ee-8c90-0242ac120002", uuidA+":1-3:6-100", "")
+	mocks["primary"].ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(uuidA + ":1-101"))
+
+	var connected []string
+	connect := func(ctx context.Context, host, port string) (*sql.DB, error) {
+		connected = append(connected, host+":"+port)
+		if db, ok := dbs[host]; ok {
+			return db, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+	root := CrawlTopology(context.Background(), "primary", "3306", "3307", connect)
+
+	if got, expected := strings.Join(connected, " "), "primary:3306 relay:3306 leaf1:3307 leaf2:3306 down:3306"; got != expected {
+		t.Errorf("connected to %q, expected %q", got, expected)
+	}
+	if root.Err != nil || len(root.Replicas) != 3 {
+		t.Fatalf("unexpected root %+v", root)
+	}
+	relay, leaf2, down := root.Replicas[0], root.Replicas[1], root.Replicas[2]
+	if len(relay.Replicas) != 1 {
+		t.Fatalf("expected relay to have one replica, got %+v", relay.Replicas)
+	}
+	leaf1 := relay.Replicas[0]
+	for _, tt := range []struct {
+		node                     *TopologyNode
+		errant, lagging, missing string
+		outcome                  Outcome
+	}{
+		{relay, uuidB + ":1", "", "", Unresolved},
+		{leaf1, "", uuidA + ":98-101", "", InSync},
+		{leaf2, "", "", uuidA + ":4-5", Unresolved},
+	} {
+		if tt.node.Err != nil {
+			t.Errorf("%s: unexpected error %v", tt.node.Label(), tt.node.Err)
+			continue
+		}
+		if tt.node.Errant.String() != tt.errant || tt.node.Lagging.String() != tt.lagging || tt.node.Missing.String() != tt.missing {
+			t.Errorf("%s: errant %q, lagging %q, missing %q; expected %q, %q, %q", tt.node.Label(),
+				tt.node.Errant, tt.node.Lagging, tt.node.Missing, tt.errant, tt.lagging, tt.missing)
+		}
+		if got := tt.node.Outcome(); got != tt.outcome {
+			t.Errorf("%s: outcome %v, expected %v", tt.node.Label(), got, tt.outcome)
+		}
+	}
+	if down.Err == nil {
+		t.Error("expected an error for the unreachable replica")
+	}
+	if outcome, failed := root.Worst(); outcome != Unresolved || !failed {
+		t.Errorf("Worst() = %v, %v; expected %v, true", outcome, failed, Unresolved)
+	}
+
+	var out bytes.Buffer
+	RenderTopology(&out, root)
+	for _, expected := range []string{
+		"primary:3306  top of the tree",
+		"├── relay:3306  ",
+		"│   └── leaf1:3307  ",
+		"├── leaf2:3306  ",
+		"└── down:3306  ",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
+	if strings.Count(out.String(), uuidB+":1") != 1 {
+		t.Errorf("expected relay's errant transaction to be reported once:\n%s", out.String())
+	}
+
+	for host, mock := range mocks {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: unmet expectations: %v", host, err)
+		}
+	}
+}
+
+func TestCrawlTopology_Cycle(t *testing.T) {
+	db1, mock1, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db1.Close()
+	db2, mock2, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db2.Close()
+
+	// a and b replicate from each other.
+	expectTopologyNode(mock1, uuidA, uuidA+":1-10,"+uuidB+":1-5", "", Replica{Host: "b", Port: "3306"})
+	expectTopologyNode(mock2, uuidB, uuidA+":1-10,"+uuidB+":1-5", "", Replica{Host: "a", Port: "3306"})
+	// a is read again to compare b with it, then once more as b's replica.
+	mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(uuidA + ":1-10," + uuidB + ":1-5"))
+	mock1.ExpectQuery("SELECT VERSION").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.36"))
+	mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@server_uuid")).WillReturnRows(sqlmock.NewRows([]string{"uuid"}).AddRow(uuidA))
+	mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_EXECUTED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(uuidA + ":1-10," + uuidB + ":1-5"))
+	mock1.ExpectQuery(regexp.QuoteMeta("SELECT @@GLOBAL.GTID_PURGED")).WillReturnRows(sqlmock.NewRows([]string{"gtid"}).AddRow(""))
+
+	connect := func(ctx context.Context, host, port string) (*sql.DB, error) {
+		if host == "a" {
+			return db1, nil
+		}
+		return db2, nil
+	}
+	root := CrawlTopology(context.Background(), "a", "3306", "3306", connect)
+
+	if len(root.Replicas) != 1 || len(root.Replicas[0].Replicas) != 1 {
+		t.Fatalf("unexpected tree %+v", root)
+	}
+	b := root.Replicas[0]
+	if b.Outcome() != InSync || b.Err != nil {
+		t.Errorf("expected b in sync, got %v (%v)", b.Outcome(), b.Err)
+	}
+	if again := b.Replicas[0]; again.Duplicate != "a:3306" || len(again.Replicas) != 0 {
+		t.Errorf("expected a to be marked as already in the tree, got %+v", again)
+	}
+	for _, mock := range []sqlmock.Sqlmock{mock1, mock2} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	}
+}